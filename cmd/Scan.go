@@ -0,0 +1,62 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package cmd implements the command-line actions for the application.
+package cmd
+
+import (
+	"fmt"
+	. "github.com/abitofhelp/go-helpers/error"
+	"gopkg.in/urfave/cli.v2"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Variable Scan is a command that counts the files and bytes in scope for the start command, without processing them.
+// It accepts the same options as the start command, and applies the same steps that drop files, such as the dedupe,
+// geofence and limits steps, so the counts match the run.
+var Scan = cli.Command{
+	Name:   "scan",
+	Usage:  "Counts the files and bytes that the start command would process, using the same options",
+	Action: scan,
+	Flags:  Start.Flags,
+}
+
+// Function scan is an internal function that validates the scan command's
+// parameters and reports the totals of the files in scope.
+// Returns nil is there are no errors.
+func scan(c *cli.Context) (err error) {
+
+	// Validate that the command line is correct for processing.
+	err = validateCommandLine(c)
+	if IsError(err, nil) {
+		return err
+	}
+
+	pipeline, err := createPipeline(c)
+	if IsError(err, nil) {
+		return err
+	}
+
+	// Stop scanning when the application is interrupted.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; ok {
+			pipeline.Stop()
+		}
+	}()
+
+	totals, err := pipeline.Scan()
+	if IsError(err, nil) {
+		return err
+	}
+
+	fmt.Printf("Files: %d\nBytes: %d\n", totals.Files, totals.Bytes)
+
+	return nil
+}
@@ -10,11 +10,20 @@ import (
 	"errors"
 	"fmt"
 	. "github.com/abitofhelp/go-helpers/error"
+	"github.com/abitofhelp/pipeline/image"
 	. "github.com/abitofhelp/pipeline/pipeline"
 	"gopkg.in/urfave/cli.v2"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 )
 
 const (
+	// The default path to the directory containing files to process, when neither a path nor a manifest is provided.
+	kDefaultPath = "/tmp"
+
 	// The default number of bytes for a reusable buffer that is used when scanning for files.
 	kDefaultScannerBufferSize = 64 * 1024
 
@@ -24,6 +33,9 @@ const (
 	// The default number of goroutines that will consume the paths channel.
 	kDefaultPathConsumerCount = 20
 
+	// The default number of goroutines that will walk the directories.
+	kDefaultWalkerCount = 1
+
 	// The maximum scanner buffer size is 1GB.
 	kMaxScannerBufferSize = 1000 * 1024
 
@@ -32,6 +44,9 @@ const (
 
 	// The maximum number of goroutine consumers on the paths channel.
 	kMaxPathConsumerCount = 50
+
+	// The maximum number of goroutines that will walk the directories.
+	kMaxWalkerCount = 64
 )
 
 // Variable Start is a command that defines the command line for starting the pipeline's processing.
@@ -41,10 +56,21 @@ var (
 		Usage:  "Starts the pipeline's processing",
 		Action: start,
 		Flags: []cli.Flag{
-			&cli.StringFlag{
+			&cli.StringSliceFlag{
 				Name:  "path",
-				Usage: "path to the directory containing files to process",
-				Value: "/tmp",
+				Usage: "path to a directory containing files to process, which may be repeated (default: " + kDefaultPath + " when there is no manifest)",
+			},
+			&cli.StringFlag{
+				Name:  "manifest",
+				Usage: "path to a file listing the files to process, or - to read the list from stdin",
+			},
+			&cli.StringSliceFlag{
+				Name:  "url",
+				Usage: "URL of an HTTP listing of files to process, either a directory index page or a list of URLs, which may be repeated",
+			},
+			&cli.BoolFlag{
+				Name:  "null",
+				Usage: "the manifest's entries are separated by NUL rather than newline characters, as produced by find -print0",
 			},
 			&cli.Uint64Flag{
 				Name:  "sbs",
@@ -61,6 +87,112 @@ var (
 				Usage: "(pathConsumerCount) is the number of concurrent and parallel goroutines that will consume paths from a channel in the pipeline",
 				Value: kDefaultPathConsumerCount,
 			},
+			&cli.Uint64Flag{
+				Name:  "wc",
+				Usage: "(walkerCount) is the number of concurrent and parallel goroutines that will walk the directories, each with its own scanner buffer",
+				Value: kDefaultWalkerCount,
+			},
+			&cli.StringFlag{
+				Name:  "sort",
+				Usage: "is the order in which the entries of each directory are traversed: none, lexical, or natural (numeric-aware), so runs are reproducible",
+				Value: "none",
+			},
+			&cli.StringFlag{
+				Name:  "dedupe",
+				Usage: "processes each unique image once, recognizing duplicates by: none, inode (hard links), or content (SHA-256 hash)",
+				Value: "none",
+			},
+			&cli.StringFlag{
+				Name:  "canonical",
+				Usage: "is the path of each duplicate group that is processed: first (discovered), shortest, or lexical",
+				Value: "first",
+			},
+			&cli.BoolFlag{
+				Name:  "skip-hidden-dirs",
+				Usage: "does not descend into directories whose names begin with a dot",
+			},
+			&cli.BoolFlag{
+				Name:  "skip-hidden-files",
+				Usage: "does not process files whose names begin with a dot",
+			},
+			&cli.BoolFlag{
+				Name:  "xdev",
+				Usage: "stays on the file system of each path, not descending into mount points such as network mounts",
+			},
+			&cli.BoolFlag{
+				Name:  "sidecars",
+				Usage: "groups sidecar files, such as IMG_0001.XMP and IMG_0001.json, with their image as one work item",
+			},
+			&cli.BoolFlag{
+				Name:  "archives",
+				Usage: "descends into .zip, .tar, .tar.gz and .tgz files, processing their members as virtual paths such as batch.zip!/dir/img.png",
+			},
+			&cli.StringFlag{
+				Name:  "state",
+				Usage: "path to a state file that makes the run incremental, processing only the files that are new or changed since the last run",
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "directory that receives a copy of each JPEG and PNG image with its metadata injected, mirroring the paths of the images",
+			},
+			&cli.BoolFlag{
+				Name:  "state-hash",
+				Usage: "incremental runs also compare the hash of each file's contents, so files that were only touched are not processed again",
+			},
+			&cli.StringFlag{
+				Name:  "deleted",
+				Usage: "is what an incremental run does with files deleted since the last run: ignore, report (and prune), or prune from the state file",
+				Value: "ignore",
+			},
+			&cli.StringFlag{
+				Name:  "on-walk-error",
+				Usage: "is what discovery does on permission, vanished-file or i/o errors: skip the node, or halt the run",
+				Value: "skip",
+			},
+			&cli.StringFlag{
+				Name:  "shard",
+				Usage: "takes only shard i of n (such as 2/4) of the files, by the hash of their relative paths, to split a tree across processes",
+			},
+			&cli.StringFlag{
+				Name:  "geofence",
+				Usage: "takes only the images taken within a region: bbox:south,west,north,east, circle:latitude,longitude,meters, or polygon:lat,lng;lat,lng;lat,lng...",
+			},
+			&cli.BoolFlag{
+				Name:  "geofence-exclude",
+				Usage: "takes only the images taken outside of the geofence's region, rather than within it",
+			},
+			&cli.BoolFlag{
+				Name:  "geofence-keep-unlocated",
+				Usage: "takes the images without a location, which the geofence otherwise drops",
+			},
+			&cli.Uint64Flag{
+				Name:  "max-width",
+				Usage: "skips the images wider than this many pixels, as declared in their headers, or 0 for no limit",
+				Value: image.DefaultLimits.MaxWidth,
+			},
+			&cli.Uint64Flag{
+				Name:  "max-height",
+				Usage: "skips the images taller than this many pixels, as declared in their headers, or 0 for no limit",
+				Value: image.DefaultLimits.MaxHeight,
+			},
+			&cli.Uint64Flag{
+				Name:  "max-pixels",
+				Usage: "skips the images with more than this many pixels (width times height), which protects against decompression bombs, or 0 for no limit",
+				Value: image.DefaultLimits.MaxPixels,
+			},
+			&cli.Uint64Flag{
+				Name:  "max-decoded-bytes",
+				Usage: "skips the images whose decoded pixels would need more than this many bytes of memory, or 0 for no limit",
+				Value: image.DefaultLimits.MaxBytes,
+			},
+			&cli.BoolFlag{
+				Name:  "prescan",
+				Usage: "counts the files and bytes in scope before processing, so the progress reports the percent complete and ETA",
+			},
+			&cli.BoolFlag{
+				Name:  "watch",
+				Usage: "keeps running after the initial walk, processing files as they are written or moved anywhere under the path (Linux only)",
+			},
 		},
 	}
 
@@ -86,11 +218,27 @@ func start(c *cli.Context) (err error) {
 		return err
 	}
 
+	// Stop loading paths when the application is interrupted, so the queued paths can finish processing.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; ok {
+			APipeline.Stop()
+		}
+	}()
+
 	// Start the pipeline...
 	err = APipeline.Start()
+
+	// Summarize the outcomes of the run, which also explains a run that was halted...
+	reportErr := APipeline.Report().Write(os.Stdout)
 	if IsError(err, nil) {
 		return err
 	}
+	if IsError(reportErr, nil) {
+		return reportErr
+	}
 
 	return nil
 }
@@ -99,14 +247,182 @@ func start(c *cli.Context) (err error) {
 // configured pipeline.
 func createPipeline(c *cli.Context) (IPipeline, error) {
 	var (
-		path              = c.String("path")
+		paths             = c.StringSlice("path")
+		manifest          = c.String("manifest")
+		urls              = c.StringSlice("url")
+		nullDelimited     = c.Bool("null")
 		scannerBufferSize = c.Uint64("sbs")
 		pathChanSize      = c.Uint64("pcs")
 		pathConsumerCount = c.Uint64("pcc")
+		walkerCount       = c.Uint64("wc")
+		skipHiddenDirs    = c.Bool("skip-hidden-dirs")
+		skipHiddenFiles   = c.Bool("skip-hidden-files")
+		oneFileSystem     = c.Bool("xdev")
+		sidecars          = c.Bool("sidecars")
+		archives          = c.Bool("archives")
+		statePath         = c.String("state")
+		outputPath        = c.String("output")
+		stateHash         = c.Bool("state-hash")
+		preScan           = c.Bool("prescan")
+		watch             = c.Bool("watch")
 	)
 
+	sortOrder, err := ParseSortOrder(c.String("sort"))
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	dedupeMode, err := ParseDedupeMode(c.String("dedupe"))
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	canonicalPolicy, err := ParseCanonicalPolicy(c.String("canonical"))
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	deletedPolicy, err := ParseDeletedPolicy(c.String("deleted"))
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	walkErrorPolicy, err := ParseWalkErrorPolicy(c.String("on-walk-error"))
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	shard, err := ParseShard(c.String("shard"))
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	geofence, err := ParseGeofence(c.String("geofence"))
+	if IsError(err, nil) {
+		return nil, err
+	}
+	geofence.Exclude = c.Bool("geofence-exclude")
+	geofence.KeepUnlocated = c.Bool("geofence-keep-unlocated")
+
+	limits := image.Limits{
+		MaxWidth:  c.Uint64("max-width"),
+		MaxHeight: c.Uint64("max-height"),
+		MaxPixels: c.Uint64("max-pixels"),
+		MaxBytes:  c.Uint64("max-decoded-bytes"),
+	}
+
+	if len(paths) == 0 && manifest == "" && len(urls) == 0 {
+		paths = []string{kDefaultPath}
+	}
+
+	// The sources are loaded in turn: the manifest, then the directories, which are watched in watch mode, then the listings.
+	var sources []Source
+	if manifest != "" {
+		sources = append(sources, ManifestSource{Path: manifest, NullDelimited: nullDelimited})
+	}
+	if watch && len(paths) > 0 {
+		sources = append(sources, WatchSource{Paths: paths})
+	} else {
+		for _, path := range paths {
+			sources = append(sources, DirectorySource{Path: path})
+		}
+	}
+	for _, url := range urls {
+		sources = append(sources, HTTPListingSource{URL: url})
+	}
+
 	// Create an instance of the pipeline using our command-line options.
-	pipeline, err := New(path, scannerBufferSize, pathChanSize, pathConsumerCount)
+	pipeline, err := New(sources, scannerBufferSize, pathChanSize, pathConsumerCount)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetWalkerCount(walkerCount)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetSortOrder(sortOrder)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetDedupeMode(dedupeMode)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetCanonicalPolicy(canonicalPolicy)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetSkipHiddenDirectories(skipHiddenDirs)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetSkipHiddenFiles(skipHiddenFiles)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetOneFileSystem(oneFileSystem)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetSidecars(sidecars)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetArchives(archives)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetStatePath(statePath)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetOutputPath(outputPath)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetStateHash(stateHash)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetDeletedPolicy(deletedPolicy)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetWalkErrorPolicy(walkErrorPolicy)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetShard(shard)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetGeofence(geofence)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetLimits(limits)
+	if IsError(err, nil) {
+		return nil, err
+	}
+
+	err = pipeline.SetPreScan(preScan)
 	if IsError(err, nil) {
 		return nil, err
 	}
@@ -118,18 +434,88 @@ func createPipeline(c *cli.Context) (IPipeline, error) {
 // the command line parameters to ensure that they are correct.
 func validateCommandLine(c *cli.Context) (err error) {
 	var (
-		path              = c.String("path")
+		paths             = c.StringSlice("path")
+		manifest          = c.String("manifest")
 		scannerBufferSize = c.Uint64("sbs")
 		pathChanSize      = c.Uint64("pcs")
 		pathConsumerCount = c.Uint64("pcc")
+		walkerCount       = c.Uint64("wc")
+		watch             = c.Bool("watch")
 	)
 
 	err = nil
 
+	for _, path := range paths {
+		if path == "" {
+			return errors.New("there must be a path to the directory containing files to process")
+		}
+	}
+
+	// The images that are written to the output directory must not be discovered and processed again,
+	// including by the default path, so both are compared as absolute paths.
+	if output := c.String("output"); output != "" {
+		roots := paths
+		if len(roots) == 0 && manifest == "" && len(c.StringSlice("url")) == 0 {
+			roots = []string{kDefaultPath}
+		}
+
+		absoluteOutput, absErr := filepath.Abs(output)
+		if absErr != nil {
+			return errors.New(fmt.Sprintf("%s%v", "the output directory is not valid: ", absErr))
+		}
+		for _, path := range roots {
+			absolutePath, absErr := filepath.Abs(path)
+			if absErr != nil {
+				return errors.New(fmt.Sprintf("%s%v", "the path is not valid: ", absErr))
+			}
+			if isWithin(absolutePath, absoluteOutput) {
+				return errors.New("the output directory cannot be within a path that is processed")
+			}
+		}
+	}
+
+	sortOrder, err := ParseSortOrder(c.String("sort"))
+	if IsError(err, nil) {
+		return err
+	}
+
+	_, err = ParseDedupeMode(c.String("dedupe"))
+	if IsError(err, nil) {
+		return err
+	}
+
+	canonicalPolicy, err := ParseCanonicalPolicy(c.String("canonical"))
+	if IsError(err, nil) {
+		return err
+	}
+
+	deletedPolicy, err := ParseDeletedPolicy(c.String("deleted"))
+	if IsError(err, nil) {
+		return err
+	}
+
+	_, err = ParseWalkErrorPolicy(c.String("on-walk-error"))
+	if IsError(err, nil) {
+		return err
+	}
+
+	_, err = ParseShard(c.String("shard"))
+	if IsError(err, nil) {
+		return err
+	}
+
+	geofence, err := ParseGeofence(c.String("geofence"))
+	if IsError(err, nil) {
+		return err
+	}
+
 	switch {
 
-	case path == "":
-		err = errors.New("there must be a path to the directory containing files to process")
+	case c.Bool("null") && manifest == "":
+		err = errors.New("the null option requires a manifest")
+
+	case watch && len(paths) == 0 && (manifest != "" || len(c.StringSlice("url")) > 0):
+		err = errors.New("the watch option requires at least one path to a directory")
 
 	case scannerBufferSize > kMaxScannerBufferSize:
 		err = errors.New(fmt.Sprintf("%s%d bytes", "the maximum scanner buffer size cannot exceed", kMaxScannerBufferSize))
@@ -139,7 +525,33 @@ func validateCommandLine(c *cli.Context) (err error) {
 
 	case pathConsumerCount > kMaxPathConsumerCount:
 		err = errors.New(fmt.Sprintf("%s%d bytes", "the maximum number of goroutine consumers on the paths channel cannot exceed", kMaxPathConsumerCount))
+
+	case walkerCount > kMaxWalkerCount:
+		err = errors.New(fmt.Sprintf("%s%d", "the maximum number of goroutines walking the directories cannot exceed ", kMaxWalkerCount))
+
+	case (c.Bool("state-hash") || deletedPolicy != IgnoreDeleted) && c.String("state") == "":
+		err = errors.New("the state-hash and deleted options require a state file")
+
+	case c.Bool("prescan") && (manifest == "-" || len(c.StringSlice("url")) > 0):
+		err = errors.New("the prescan option cannot be used with a manifest read from stdin, which can only be read once, or with a url, which would be fetched twice")
+
+	case watch && canonicalPolicy != FirstCanonical:
+		err = errors.New("the watch option requires the first canonical policy, because the discovery never finishes")
+
+	case walkerCount > 1 && sortOrder != Unsorted:
+		err = errors.New("a sorted traversal requires a single walker, because parallel walkers interleave their subtrees")
+
+	case (c.Bool("geofence-exclude") || c.Bool("geofence-keep-unlocated")) && !geofence.IsGeofenced():
+		err = errors.New("the geofence-exclude and geofence-keep-unlocated options require a geofence")
 	}
 
 	return err
 }
+
+// Function isWithin determines whether a path is a directory or within it.
+// Parameter directory is the absolute path to the directory.
+// Parameter path is the absolute path.
+func isWithin(directory string, path string) bool {
+	relative, err := filepath.Rel(directory, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
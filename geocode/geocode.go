@@ -0,0 +1,185 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package geocode contains methods to name the places near locations on the Earth without network access.
+package geocode
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/abitofhelp/pipeline/geodesy"
+	"github.com/abitofhelp/pipeline/timezone"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Variable citiesText is the populated places, one tab-separated place per line.
+//
+//go:embed cities.tsv
+var citiesText string
+
+// Variable countriesText is the names of the countries by their codes, one tab-separated country per line.
+//
+//go:embed countries.tsv
+var countriesText string
+
+// The precision of the time zones' boundaries, in meters. A place within this distance of a location may be
+// in the location's country even when the location's zone does not include it, because the location may be
+// on the far side of the border.
+const kBorderPrecision = 50000
+
+// Variable ErrNoPlace indicates that there is no place near a location, such as at sea.
+var ErrNoPlace = errors.New("there is no place near the location")
+
+// Type Place is a populated place, such as a city.
+type Place struct {
+	// Field Name is the place's name, such as "San Francisco".
+	Name string
+
+	// Field Region is the name of the place's first-order administrative division, such as "California".
+	Region string
+
+	// Field Country is the name of the place's country, such as "United States".
+	Country string
+
+	// Field CountryCode is the ISO 3166-1 alpha-2 code of the place's country, such as "US".
+	CountryCode string
+
+	// Field Location is the latitude and longitude of the place's center, in degrees.
+	Location latlng.LatLng
+
+	// Field Distance is the great-circle distance from the location that was looked up to the place, in meters.
+	Distance float64
+}
+
+var (
+	// Variable loadOnce parses the places and indexes them the first time that they are needed.
+	loadOnce sync.Once
+
+	// Variable places are the parsed places, by their indexes in the tree.
+	places []Place
+
+	// Variable tree is the spatial index of the places.
+	tree *kdTree
+
+	// Variable loadErr is the error that was encountered while parsing the places.
+	loadErr error
+)
+
+// Function Nearest finds the populated place nearest to a location in the embedded places, within the location's country.
+// The country is determined from the location's time zone, because the nearest place may be across a border.
+// The zones' boundaries are coarse, so a location within about 50 km of a border may be assigned to its neighbor,
+// and a place in another country is also found when it is within that distance of the location.
+// A location outside of every zone's boundaries, such as at sea, is assigned the nearest place in any country.
+// Parameter location is the latitude and longitude, in degrees.
+// Parameter maxDistance is the greatest distance to the place, in meters, or zero when the distance is not limited.
+// Returns the place, ErrNoPlace when there is no place within the distance, or error.
+func Nearest(location latlng.LatLng, maxDistance float64) (*Place, error) {
+	err := geodesy.ValidateLatLng(location)
+	if err != nil {
+		return nil, err
+	}
+	if maxDistance < 0 {
+		return nil, errors.New("the maximum distance cannot be negative")
+	}
+
+	loadOnce.Do(func() {
+		places, tree, loadErr = parsePlaces(citiesText, countriesText)
+	})
+	if loadErr != nil {
+		return nil, loadErr
+	}
+
+	zone, err := timezone.Lookup(location)
+	if err != nil {
+		return nil, err
+	}
+
+	var accept func(int) bool
+	if codes := timezone.Countries(zone); len(codes) > 0 {
+		accept = func(index int) bool {
+			for _, code := range codes {
+				if places[index].CountryCode == code {
+					return true
+				}
+			}
+			return geodesy.Distance(location, places[index].Location) <= kBorderPrecision
+		}
+	}
+
+	index := tree.nearest(newPoint(location), accept)
+	if index < 0 {
+		return nil, ErrNoPlace
+	}
+
+	place := places[index]
+	place.Distance = geodesy.Distance(location, place.Location)
+	if maxDistance > 0 && place.Distance > maxDistance {
+		return nil, ErrNoPlace
+	}
+	return &place, nil
+}
+
+// Function parsePlaces converts the places' and countries' text to the places and their spatial index.
+// Each line of the places that is not blank or a comment is a place's name, region, country code, latitude and longitude.
+// Each line of the countries that is not blank or a comment is a country's code and name.
+// Parameter cities is the places' text.
+// Parameter countries is the countries' text.
+// Returns the places, their spatial index, or error.
+func parsePlaces(cities string, countries string) ([]Place, *kdTree, error) {
+	names := make(map[string]string)
+	for number, line := range strings.Split(countries, "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) != 2 {
+			return nil, nil, errors.New(fmt.Sprintf("the country on line %d must have a code and a name", number+1))
+		}
+		names[fields[0]] = fields[1]
+	}
+
+	var (
+		parsed []Place
+		points []point
+	)
+	for number, line := range strings.Split(cities, "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) != 5 {
+			return nil, nil, errors.New(fmt.Sprintf("the place on line %d must have a name, region, country code, latitude and longitude", number+1))
+		}
+
+		country, ok := names[fields[2]]
+		if !ok {
+			return nil, nil, errors.New(fmt.Sprintf("the place on line %d has an unknown country code: %s", number+1, fields[2]))
+		}
+
+		latitude, latErr := strconv.ParseFloat(fields[3], 64)
+		longitude, lngErr := strconv.ParseFloat(fields[4], 64)
+		location := latlng.LatLng{Latitude: latitude, Longitude: longitude}
+		if latErr != nil || lngErr != nil || geodesy.ValidateLatLng(location) != nil {
+			return nil, nil, errors.New(fmt.Sprintf("the place on line %d has an invalid location: %s,%s", number+1, fields[3], fields[4]))
+		}
+
+		parsed = append(parsed, Place{
+			Name:        fields[0],
+			Region:      fields[1],
+			Country:     country,
+			CountryCode: fields[2],
+			Location:    location,
+		})
+		points = append(points, newPoint(location))
+	}
+
+	return parsed, newKdTree(points), nil
+}
@@ -0,0 +1,133 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package geocode contains methods to name the places near locations on the Earth without network access.
+package geocode
+
+import (
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"math/rand"
+	"testing"
+)
+
+// Function TestKdTreeNearest verifies that the tree finds the same place as a search of every place,
+// with and without a filter of the places that are accepted.
+func TestKdTreeNearest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomPoint := func() point {
+		return newPoint(latlng.LatLng{Latitude: random.Float64()*180 - 90, Longitude: random.Float64()*360 - 180})
+	}
+
+	points := make([]point, 1000)
+	for i := range points {
+		points[i] = randomPoint()
+	}
+	tree := newKdTree(points)
+
+	tests := []struct {
+		name   string
+		accept func(int) bool
+	}{
+		{"every place", nil},
+		{"the even places", func(index int) bool { return index%2 == 0 }},
+		{"one place", func(index int) bool { return index == 500 }},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 2000; i++ {
+			target := randomPoint()
+
+			want, wantDistance2 := -1, 0.0
+			for index, candidate := range points {
+				if test.accept != nil && !test.accept(index) {
+					continue
+				}
+				if distance2 := candidate.distance2(target); want < 0 || distance2 < wantDistance2 {
+					want, wantDistance2 = index, distance2
+				}
+			}
+
+			if got := tree.nearest(target, test.accept); got != want && points[got].distance2(target) != wantDistance2 {
+				t.Fatalf("the nearest of %s to %v is %d, want %d", test.name, target, got, want)
+			}
+		}
+	}
+
+	if index := newKdTree(nil).nearest(randomPoint(), nil); index != -1 {
+		t.Errorf("the nearest place in an empty tree is %d, want -1", index)
+	}
+	if index := tree.nearest(randomPoint(), func(int) bool { return false }); index != -1 {
+		t.Errorf("the nearest place that is not accepted is %d, want -1", index)
+	}
+}
+
+// Function TestNearest verifies the places and countries of some locations, including near borders,
+// across the antimeridian, and at sea.
+func TestNearest(t *testing.T) {
+	tests := []struct {
+		latitude    float64
+		longitude   float64
+		maxDistance float64
+		name        string
+		countryCode string
+		fails       bool
+	}{
+		{37.78, -122.42, 200000, "San Francisco", "US", false},
+		{46.21, 6.15, 200000, "Geneva", "CH", false},
+		{13.0, -7.5, 200000, "Bamako", "ML", false},
+		{-36.85, 174.76, 200000, "Auckland", "NZ", false},
+		{64.0, -179.9, 0, "", "", false},
+		{0, -150, 200000, "", "", true},
+		{91, 0, 200000, "", "", true},
+		{0, 0, -1, "", "", true},
+	}
+
+	for _, test := range tests {
+		place, err := Nearest(latlng.LatLng{Latitude: test.latitude, Longitude: test.longitude}, test.maxDistance)
+		if (err != nil) != test.fails {
+			t.Errorf("Nearest(%v, %v) = %v", test.latitude, test.longitude, err)
+			continue
+		}
+		if err != nil || test.name == "" {
+			continue
+		}
+		if place.Name != test.name || place.CountryCode != test.countryCode {
+			t.Errorf("Nearest(%v, %v) = %s, %s, want %s, %s", test.latitude, test.longitude, place.Name, place.CountryCode, test.name, test.countryCode)
+		}
+		if place.Distance > test.maxDistance {
+			t.Errorf("Nearest(%v, %v) = %s at %.0f m, beyond %.0f m", test.latitude, test.longitude, place.Name, place.Distance, test.maxDistance)
+		}
+	}
+
+	if _, err := Nearest(latlng.LatLng{Latitude: 0, Longitude: -150}, 200000); err != ErrNoPlace {
+		t.Errorf("the place at sea is %v, want ErrNoPlace", err)
+	}
+}
+
+// Function TestNearestCountry verifies that a location is named after a city in its own country,
+// rather than a nearer city across the border.
+func TestNearestCountry(t *testing.T) {
+	tests := []struct {
+		latitude    float64
+		longitude   float64
+		countryCode string
+	}{
+		// Northern New York, which is nearer to Ottawa than to any city in the United States.
+		{44.5, -75.5, "US"},
+		// Central Switzerland, whose nearest city is Swiss.
+		{47.3, 8.6, "CH"},
+	}
+
+	for _, test := range tests {
+		place, err := Nearest(latlng.LatLng{Latitude: test.latitude, Longitude: test.longitude}, 0)
+		if err != nil {
+			t.Errorf("Nearest(%v, %v) = %v", test.latitude, test.longitude, err)
+			continue
+		}
+		if place.CountryCode != test.countryCode {
+			t.Errorf("Nearest(%v, %v) = %s, %s, want a place in %s", test.latitude, test.longitude, place.Name, place.CountryCode, test.countryCode)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package geocode contains methods to name the places near locations on the Earth without network access.
+package geocode
+
+import (
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"math"
+	"sort"
+)
+
+// Type point is a location on the unit sphere, in Earth-centered Cartesian coordinates.
+// The straight-line (chord) distance between two points increases with their great-circle distance,
+// so the nearest point by chord distance is the nearest place on the Earth, even across the antimeridian and the poles.
+type point [3]float64
+
+// Function newPoint converts a latitude and longitude to a point on the unit sphere.
+// Parameter location is the latitude and longitude, in degrees.
+func newPoint(location latlng.LatLng) point {
+	latitude, longitude := location.Latitude*math.Pi/180, location.Longitude*math.Pi/180
+	return point{
+		math.Cos(latitude) * math.Cos(longitude),
+		math.Cos(latitude) * math.Sin(longitude),
+		math.Sin(latitude),
+	}
+}
+
+// Method distance2 gets the squared chord distance to another point.
+// Parameter other is the other point.
+func (p point) distance2(other point) float64 {
+	dx, dy, dz := p[0]-other[0], p[1]-other[1], p[2]-other[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// Type kdNode is a node of the k-d tree, which splits the places on one axis at the node's place.
+type kdNode struct {
+	// Field place is the index of the node's place.
+	place int
+
+	// Field axis is the axis of the split, from 0 to 2.
+	axis int
+
+	// Field left is the subtree of the places whose coordinates on the axis are less than or equal to the node's.
+	left *kdNode
+
+	// Field right is the subtree of the places whose coordinates on the axis are greater than or equal to the node's.
+	right *kdNode
+}
+
+// Type kdTree is a balanced k-d tree of the places' points, which finds the nearest place in logarithmic time.
+type kdTree struct {
+	// Field points are the places' points, by the places' indexes.
+	points []point
+
+	// Field root is the root of the tree, or nil when there are no places.
+	root *kdNode
+}
+
+// Function newKdTree is a factory that creates a balanced k-d tree.
+// Parameter points are the places' points, by the places' indexes.
+// Returns an initialized instance.
+func newKdTree(points []point) *kdTree {
+	indexes := make([]int, len(points))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	tree := &kdTree{points: points}
+	tree.root = tree.build(indexes, 0)
+	return tree
+}
+
+// Method build creates the subtree of some places, split at the median on the axis for its depth.
+// Parameter indexes are the indexes of the places, which are reordered.
+// Parameter depth is the depth of the subtree's root.
+// Returns the subtree, or nil when there are no places.
+func (t *kdTree) build(indexes []int, depth int) *kdNode {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	axis := depth % 3
+	sort.Slice(indexes, func(a, b int) bool {
+		return t.points[indexes[a]][axis] < t.points[indexes[b]][axis]
+	})
+
+	median := len(indexes) / 2
+	return &kdNode{
+		place: indexes[median],
+		axis:  axis,
+		left:  t.build(indexes[:median], depth+1),
+		right: t.build(indexes[median+1:], depth+1),
+	}
+}
+
+// Method nearest finds the place nearest to a point among the places that are accepted.
+// Parameter target is the point.
+// Parameter accept reports whether a place, by its index, can be found, or nil when every place can be found.
+// Returns the index of the nearest place, or -1 when there are no places.
+func (t *kdTree) nearest(target point, accept func(int) bool) int {
+	best, bestDistance2 := -1, math.Inf(1)
+	t.search(t.root, target, accept, &best, &bestDistance2)
+	return best
+}
+
+// Method search finds the place in a subtree that is nearer to a point than the best place found so far.
+// The far side of a split is searched only when the splitting plane is nearer than the best place.
+// A place that is not accepted still splits the subtree, but it is never the best place.
+// Parameter node is the subtree's root.
+// Parameter target is the point.
+// Parameter accept reports whether a place, by its index, can be found, or nil when every place can be found.
+// Parameter best is the index of the best place found so far, which is updated.
+// Parameter bestDistance2 is the squared chord distance to the best place found so far, which is updated.
+func (t *kdTree) search(node *kdNode, target point, accept func(int) bool, best *int, bestDistance2 *float64) {
+	if node == nil {
+		return
+	}
+
+	distance2 := t.points[node.place].distance2(target)
+	if distance2 < *bestDistance2 && (accept == nil || accept(node.place)) {
+		*best, *bestDistance2 = node.place, distance2
+	}
+
+	delta := target[node.axis] - t.points[node.place][node.axis]
+	near, far := node.left, node.right
+	if delta > 0 {
+		near, far = far, near
+	}
+
+	t.search(near, target, accept, best, bestDistance2)
+	if delta*delta < *bestDistance2 {
+		t.search(far, target, accept, best, bestDistance2)
+	}
+}
@@ -0,0 +1,179 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package geodesy contains methods to measure and compare locations on the Earth.
+package geodesy
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"math"
+)
+
+// The mean radius of the Earth in meters, as defined by the IUGG.
+const kEarthRadiusMeters = 6371008.8
+
+// Type Region is an interface that requires implementations of an area on the Earth, such as a geofence.
+type Region interface {
+
+	// Function Contains determines whether a location is within the region.
+	// Parameter location is the latitude and longitude, in degrees.
+	Contains(location latlng.LatLng) bool
+}
+
+// Function ValidateLatLng determines whether a latitude and longitude are within their ranges:
+// the latitude from -90 to 90 degrees, and the longitude from -180 to 180 degrees.
+// Parameter location is the latitude and longitude, in degrees.
+// Returns nil if the location is valid, otherwise error.
+func ValidateLatLng(location latlng.LatLng) error {
+	if math.IsNaN(location.Latitude) || location.Latitude < -90 || location.Latitude > 90 {
+		return errors.New(fmt.Sprintf("%s%v", "the latitude must be from -90 to 90 degrees, not ", location.Latitude))
+	}
+	if math.IsNaN(location.Longitude) || location.Longitude < -180 || location.Longitude > 180 {
+		return errors.New(fmt.Sprintf("%s%v", "the longitude must be from -180 to 180 degrees, not ", location.Longitude))
+	}
+	return nil
+}
+
+// Function Distance measures the great-circle distance between two locations with the haversine formula,
+// which treats the Earth as a sphere, so it is within about 0.5% of the distance on the ellipsoid.
+// Parameter from is the first location, in degrees.
+// Parameter to is the second location, in degrees.
+// Returns the distance in meters.
+func Distance(from latlng.LatLng, to latlng.LatLng) float64 {
+	fromLatitude, toLatitude := radians(from.Latitude), radians(to.Latitude)
+	deltaLatitude := toLatitude - fromLatitude
+	deltaLongitude := radians(to.Longitude - from.Longitude)
+
+	a := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(fromLatitude)*math.Cos(toLatitude)*math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+
+	return 2 * kEarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Function radians converts an angle in degrees to radians.
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Type BoundingBox is the region between two latitudes and two longitudes.
+// When West is greater than East, the box crosses the antimeridian, such as from 170 to -170 degrees.
+type BoundingBox struct {
+	// Field South is the southern latitude, in degrees.
+	South float64
+
+	// Field West is the western longitude, in degrees.
+	West float64
+
+	// Field North is the northern latitude, in degrees.
+	North float64
+
+	// Field East is the eastern longitude, in degrees.
+	East float64
+}
+
+// Function NewBoundingBox is a factory that creates a validated BoundingBox.
+// Parameter south is the southern latitude, in degrees.
+// Parameter west is the western longitude, in degrees.
+// Parameter north is the northern latitude, in degrees.
+// Parameter east is the eastern longitude, in degrees.
+// Returns an initialized instance or error.
+func NewBoundingBox(south float64, west float64, north float64, east float64) (*BoundingBox, error) {
+	for _, corner := range []latlng.LatLng{{Latitude: south, Longitude: west}, {Latitude: north, Longitude: east}} {
+		err := ValidateLatLng(corner)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if south > north {
+		return nil, errors.New("the bounding box's southern latitude cannot be north of its northern latitude")
+	}
+
+	return &BoundingBox{South: south, West: west, North: north, East: east}, nil
+}
+
+// Method Contains determines whether a location is within the bounding box, including its edges.
+func (b BoundingBox) Contains(location latlng.LatLng) bool {
+	if location.Latitude < b.South || location.Latitude > b.North {
+		return false
+	}
+	if b.West <= b.East {
+		return location.Longitude >= b.West && location.Longitude <= b.East
+	}
+	return location.Longitude >= b.West || location.Longitude <= b.East
+}
+
+// Type Circle is the region within a distance of a location.
+type Circle struct {
+	// Field Center is the location at the center of the circle, in degrees.
+	Center latlng.LatLng
+
+	// Field Radius is the distance from the center to the edge of the circle, in meters.
+	Radius float64
+}
+
+// Function NewCircle is a factory that creates a validated Circle.
+// Parameter center is the location at the center of the circle, in degrees.
+// Parameter radius is the distance from the center to the edge of the circle, in meters.
+// Returns an initialized instance or error.
+func NewCircle(center latlng.LatLng, radius float64) (*Circle, error) {
+	err := ValidateLatLng(center)
+	if err != nil {
+		return nil, err
+	}
+
+	if math.IsNaN(radius) || radius <= 0 {
+		return nil, errors.New("the circle's radius must be greater than zero")
+	}
+
+	return &Circle{Center: center, Radius: radius}, nil
+}
+
+// Method Contains determines whether a location is within the circle, including its edge.
+func (c Circle) Contains(location latlng.LatLng) bool {
+	return Distance(c.Center, location) <= c.Radius
+}
+
+// Type Polygon is the region within a closed ring of locations. The edges are straight lines between the latitudes
+// and longitudes, which is accurate for regions the size of a city or a country, but not for one that crosses
+// the antimeridian or contains a pole.
+type Polygon []latlng.LatLng
+
+// Function NewPolygon is a factory that creates a validated Polygon.
+// Parameter vertices are the locations of the polygon's vertices in order, in degrees.
+// The ring is closed, so the last vertex does not need to repeat the first.
+// Returns an initialized instance or error.
+func NewPolygon(vertices []latlng.LatLng) (Polygon, error) {
+	if len(vertices) < 3 {
+		return nil, errors.New("the polygon must have at least three vertices")
+	}
+
+	for _, vertex := range vertices {
+		err := ValidateLatLng(vertex)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return Polygon(append([]latlng.LatLng(nil), vertices...)), nil
+}
+
+// Method Contains determines whether a location is within the polygon, by counting the edges that a ray
+// from the location crosses. A location on an edge may be within or outside of the polygon.
+func (p Polygon) Contains(location latlng.LatLng) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.Latitude > location.Latitude) != (b.Latitude > location.Latitude) {
+			crossing := a.Longitude + (location.Latitude-a.Latitude)*(b.Longitude-a.Longitude)/(b.Latitude-a.Latitude)
+			if location.Longitude < crossing {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
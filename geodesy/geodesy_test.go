@@ -0,0 +1,160 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package geodesy contains methods to measure and compare locations on the Earth.
+package geodesy
+
+import (
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"math"
+	"testing"
+)
+
+// Function TestValidateLatLng verifies the ranges of the latitude and longitude.
+func TestValidateLatLng(t *testing.T) {
+	tests := []struct {
+		latitude  float64
+		longitude float64
+		fails     bool
+	}{
+		{0, 0, false},
+		{90, 180, false},
+		{-90, -180, false},
+		{90.0001, 0, true},
+		{-90.0001, 0, true},
+		{0, 180.0001, true},
+		{0, -180.0001, true},
+		{math.NaN(), 0, true},
+		{0, math.Inf(1), true},
+	}
+
+	for _, test := range tests {
+		err := ValidateLatLng(latlng.LatLng{Latitude: test.latitude, Longitude: test.longitude})
+		if (err != nil) != test.fails {
+			t.Errorf("ValidateLatLng(%v, %v) = %v", test.latitude, test.longitude, err)
+		}
+	}
+}
+
+// Function TestDistance verifies the great-circle distances between some locations, including across the antimeridian.
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		from     latlng.LatLng
+		to       latlng.LatLng
+		distance float64
+	}{
+		{latlng.LatLng{Latitude: 37.7749, Longitude: -122.4194}, latlng.LatLng{Latitude: 37.7749, Longitude: -122.4194}, 0},
+		{latlng.LatLng{Latitude: 37.7749, Longitude: -122.4194}, latlng.LatLng{Latitude: 34.0522, Longitude: -118.2437}, 559120},
+		{latlng.LatLng{Latitude: 0, Longitude: 179.5}, latlng.LatLng{Latitude: 0, Longitude: -179.5}, 111195},
+		{latlng.LatLng{Latitude: 0, Longitude: 0}, latlng.LatLng{Latitude: 0, Longitude: 180}, math.Pi * kEarthRadiusMeters},
+		{latlng.LatLng{Latitude: 90, Longitude: 0}, latlng.LatLng{Latitude: -90, Longitude: 0}, math.Pi * kEarthRadiusMeters},
+		{latlng.LatLng{Latitude: 89.9, Longitude: 0}, latlng.LatLng{Latitude: 89.9, Longitude: 180}, 22239},
+	}
+
+	for _, test := range tests {
+		distance := Distance(test.from, test.to)
+		if math.Abs(distance-test.distance) > 0.001*test.distance+1 {
+			t.Errorf("Distance(%v, %v) = %.0f, want %.0f", test.from, test.to, distance, test.distance)
+		}
+		if reverse := Distance(test.to, test.from); math.Abs(reverse-distance) > 1e-6 {
+			t.Errorf("Distance(%v, %v) = %.0f, but the reverse is %.0f", test.from, test.to, distance, reverse)
+		}
+	}
+}
+
+// Function TestBoundingBoxContains verifies the boxes' edges, including a box that crosses the antimeridian.
+func TestBoundingBoxContains(t *testing.T) {
+	tests := []struct {
+		box      [4]float64
+		location latlng.LatLng
+		contains bool
+	}{
+		{[4]float64{37, -123, 38, -122}, latlng.LatLng{Latitude: 37.5, Longitude: -122.5}, true},
+		{[4]float64{37, -123, 38, -122}, latlng.LatLng{Latitude: 37, Longitude: -123}, true},
+		{[4]float64{37, -123, 38, -122}, latlng.LatLng{Latitude: 38.1, Longitude: -122.5}, false},
+		{[4]float64{37, -123, 38, -122}, latlng.LatLng{Latitude: 37.5, Longitude: -121.9}, false},
+		{[4]float64{-20, 170, -10, -170}, latlng.LatLng{Latitude: -15, Longitude: 175}, true},
+		{[4]float64{-20, 170, -10, -170}, latlng.LatLng{Latitude: -15, Longitude: -175}, true},
+		{[4]float64{-20, 170, -10, -170}, latlng.LatLng{Latitude: -15, Longitude: 180}, true},
+		{[4]float64{-20, 170, -10, -170}, latlng.LatLng{Latitude: -15, Longitude: 0}, false},
+		{[4]float64{-20, 170, -10, -170}, latlng.LatLng{Latitude: -21, Longitude: 175}, false},
+	}
+
+	for _, test := range tests {
+		box, err := NewBoundingBox(test.box[0], test.box[1], test.box[2], test.box[3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contains := box.Contains(test.location); contains != test.contains {
+			t.Errorf("%v.Contains(%v) = %v, want %v", *box, test.location, contains, test.contains)
+		}
+	}
+
+	if _, err := NewBoundingBox(38, -123, 37, -122); err == nil {
+		t.Error("a bounding box whose south is north of its north must be rejected")
+	}
+}
+
+// Function TestCircleContains verifies the circles' edges, including a circle that crosses the antimeridian.
+func TestCircleContains(t *testing.T) {
+	tests := []struct {
+		center   latlng.LatLng
+		radius   float64
+		location latlng.LatLng
+		contains bool
+	}{
+		{latlng.LatLng{Latitude: 0, Longitude: 179.9}, 50000, latlng.LatLng{Latitude: 0, Longitude: -179.9}, true},
+		{latlng.LatLng{Latitude: 0, Longitude: 179.9}, 50000, latlng.LatLng{Latitude: 0, Longitude: 179.4}, false},
+		{latlng.LatLng{Latitude: 37.7749, Longitude: -122.4194}, 600000, latlng.LatLng{Latitude: 34.0522, Longitude: -118.2437}, true},
+		{latlng.LatLng{Latitude: 37.7749, Longitude: -122.4194}, 500000, latlng.LatLng{Latitude: 34.0522, Longitude: -118.2437}, false},
+	}
+
+	for _, test := range tests {
+		circle, err := NewCircle(test.center, test.radius)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contains := circle.Contains(test.location); contains != test.contains {
+			t.Errorf("%v.Contains(%v) = %v, want %v", *circle, test.location, contains, test.contains)
+		}
+	}
+
+	if _, err := NewCircle(latlng.LatLng{}, 0); err == nil {
+		t.Error("a circle without a radius must be rejected")
+	}
+}
+
+// Function TestPolygonContains verifies a convex and a concave polygon.
+func TestPolygonContains(t *testing.T) {
+	square := []latlng.LatLng{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 10}, {Latitude: 10, Longitude: 10}, {Latitude: 10, Longitude: 0}}
+	notch := []latlng.LatLng{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 10}, {Latitude: 10, Longitude: 10}, {Latitude: 5, Longitude: 5}, {Latitude: 10, Longitude: 0}}
+
+	tests := []struct {
+		vertices []latlng.LatLng
+		location latlng.LatLng
+		contains bool
+	}{
+		{square, latlng.LatLng{Latitude: 5, Longitude: 5}, true},
+		{square, latlng.LatLng{Latitude: -1, Longitude: 5}, false},
+		{square, latlng.LatLng{Latitude: 5, Longitude: 11}, false},
+		{notch, latlng.LatLng{Latitude: 2, Longitude: 5}, true},
+		{notch, latlng.LatLng{Latitude: 8, Longitude: 5}, false},
+		{notch, latlng.LatLng{Latitude: 8, Longitude: 9}, true},
+	}
+
+	for _, test := range tests {
+		polygon, err := NewPolygon(test.vertices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contains := polygon.Contains(test.location); contains != test.contains {
+			t.Errorf("the polygon %v contains %v = %v, want %v", test.vertices, test.location, contains, test.contains)
+		}
+	}
+
+	if _, err := NewPolygon(square[:2]); err == nil {
+		t.Error("a polygon with two vertices must be rejected")
+	}
+}
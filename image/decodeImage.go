@@ -0,0 +1,264 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	stdimage "image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Function Load is a factory that creates an Image by reading and decoding an image file into a pixel buffer.
+// The formats are PNG, JPEG and GIF. The EXIF and XMP metadata of a JPEG or PNG image are also read, and its location is named after the nearest city.
+// Parameter path is the path and filename to an image file.
+// Returns an initialized instance or error.
+func Load(path string) (*Image, error) {
+	image, err := NewImageFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = image.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = image.LoadExif()
+	if err != nil && err != ErrNoExif {
+		return nil, err
+	}
+
+	// The XMP packet is read after the EXIF data, because it is where asset management tools record their changes.
+	err = image.LoadXmp()
+	if err != nil && err != ErrNoXmp {
+		return nil, err
+	}
+
+	err = image.Geocode()
+	if err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// Function LoadConfig is a factory that creates an Image by reading only the header of an image file,
+// which records its dimensions, color model and format without decoding its pixels, and its EXIF and XMP metadata, naming its location after the nearest city.
+// Parameter path is the path and filename to an image file.
+// Returns an initialized instance or error.
+func LoadConfig(path string) (*Image, error) {
+	image, err := NewImageFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = image.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	err = image.LoadExif()
+	if err != nil && err != ErrNoExif {
+		return nil, err
+	}
+
+	// The XMP packet is read after the EXIF data, because it is where asset management tools record their changes.
+	err = image.LoadXmp()
+	if err != nil && err != ErrNoXmp {
+		return nil, err
+	}
+
+	err = image.Geocode()
+	if err != nil {
+		return nil, err
+	}
+
+	return image, nil
+}
+
+// Method Load reads and decodes the image file into a pixel buffer.
+// Returns nil if there are no errors.
+func (i *Image) Load() error {
+	file, err := os.Open(i.fullPath())
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to open the image: ", err))
+	}
+	defer file.Close()
+
+	return i.Decode(file)
+}
+
+// Method LoadConfig reads only the header of the image file, recording its dimensions, color model and format.
+// Returns nil if there are no errors.
+func (i *Image) LoadConfig() error {
+	file, err := os.Open(i.fullPath())
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to open the image: ", err))
+	}
+	defer file.Close()
+
+	return i.DecodeConfig(file)
+}
+
+// Method Decode decodes an image from a stream into a pixel buffer, recording its dimensions, color model and format.
+// It reads any stream, such as a member within an archive. The header is decoded first, so an image that is larger
+// than the instance's limits is rejected with a *LimitError before its pixel buffer is allocated.
+// Parameter reader is the stream containing the image.
+// Returns nil if there are no errors.
+func (i *Image) Decode(reader io.Reader) error {
+
+	// The header that is read while it is checked is replayed to the decoder, so the stream is read only once.
+	var header bytes.Buffer
+	err := i.Limits().CheckHeader(io.TeeReader(reader, &header))
+	if err != nil {
+		return err
+	}
+
+	pixels, format, err := stdimage.Decode(io.MultiReader(&header, reader))
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to decode the image: ", err))
+	}
+
+	bounds := pixels.Bounds()
+	i.pixels = pixels
+	i.width = bounds.Dx()
+	i.height = bounds.Dy()
+	i.colorModel = pixels.ColorModel()
+	i.format = format
+
+	return nil
+}
+
+// Method DecodeConfig decodes only the header of an image from a stream, recording its dimensions, color model and format,
+// which is a cheap way to inspect an image without decoding its pixels.
+// Parameter reader is the stream containing the image.
+// Returns nil if there are no errors.
+func (i *Image) DecodeConfig(reader io.Reader) error {
+	config, format, err := stdimage.DecodeConfig(reader)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to decode the image's header: ", err))
+	}
+
+	i.pixels = nil
+	i.width = config.Width
+	i.height = config.Height
+	i.colorModel = config.ColorModel
+	i.format = format
+
+	return nil
+}
+
+// Method fullPath gets the path and filename to the image file.
+func (i Image) fullPath() string {
+	return filepath.Join(i.path, i.filename)
+}
+
+// Method Limits gets the largest image that is decoded into a pixel buffer, which are the DefaultLimits until they are set.
+func (i Image) Limits() Limits {
+	if i.limits == nil {
+		return DefaultLimits
+	}
+	return *i.limits
+}
+
+// Method SetLimits sets the largest image that is decoded into a pixel buffer, where a zero limit is not enforced.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetLimits(limits Limits) error {
+	i.limits = &limits
+	return nil
+}
+
+// Method Pixels gets the decoded pixel buffer, or nil when only the header has been decoded.
+func (i Image) Pixels() stdimage.Image {
+	return i.pixels
+}
+
+// Method IsDecoded determines whether the pixels have been decoded.
+func (i Image) IsDecoded() bool {
+	return i.pixels != nil
+}
+
+// Method Width gets the width of the image in pixels.
+func (i Image) Width() int {
+	return i.width
+}
+
+// Method Height gets the height of the image in pixels.
+func (i Image) Height() int {
+	return i.height
+}
+
+// Method ColorModel gets the color model of the image's pixels.
+func (i Image) ColorModel() color.Model {
+	return i.colorModel
+}
+
+// Variable colorModels are the names of the standard color models.
+var colorModels = []struct {
+	name  string
+	model color.Model
+}{
+	{"RGBA", color.RGBAModel},
+	{"RGBA64", color.RGBA64Model},
+	{"NRGBA", color.NRGBAModel},
+	{"NRGBA64", color.NRGBA64Model},
+	{"Alpha", color.AlphaModel},
+	{"Alpha16", color.Alpha16Model},
+	{"Gray", color.GrayModel},
+	{"Gray16", color.Gray16Model},
+	{"YCbCr", color.YCbCrModel},
+	{"NYCbCrA", color.NYCbCrAModel},
+	{"CMYK", color.CMYKModel},
+}
+
+// Method ColorModelName gets the name of the color model of the image's pixels, such as "RGBA" or "YCbCr".
+func (i Image) ColorModelName() string {
+	if i.colorModel == nil {
+		return ""
+	}
+
+	for _, named := range colorModels {
+		if i.colorModel == named.model {
+			return named.name
+		}
+	}
+
+	if _, ok := i.colorModel.(color.Palette); ok {
+		return "Paletted"
+	}
+	return "Other"
+}
+
+// Function colorModelNamed gets the standard color model with a name. A paletted or other color model
+// cannot be recreated from its name, so it is unknown until the image is decoded again.
+// Parameter name is the name of the color model, such as "RGBA" or "YCbCr".
+// Returns the color model, which is nil when it cannot be recreated, or error when the name is not valid.
+func colorModelNamed(name string) (color.Model, error) {
+	for _, named := range colorModels {
+		if name == named.name {
+			return named.model, nil
+		}
+	}
+
+	if name == "Paletted" || name == "Other" {
+		return nil, nil
+	}
+	return nil, errors.New("the image's color model is not known: " + name)
+}
+
+// Method Format gets the name of the image's source format, such as "png", "jpeg" or "gif".
+func (i Image) Format() string {
+	return i.format
+}
@@ -0,0 +1,145 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"bytes"
+	"errors"
+	stdimage "image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Function TestDecode verifies that each format is decoded into a pixel buffer, or only its header is decoded,
+// recording the dimensions, color model and format.
+func TestDecode(t *testing.T) {
+	var encodedPNG, encodedJPEG, encodedGIF bytes.Buffer
+	if err := png.Encode(&encodedPNG, stdimage.NewNRGBA(stdimage.Rect(0, 0, 7, 3))); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&encodedJPEG, stdimage.NewRGBA(stdimage.Rect(0, 0, 16, 9)), &jpeg.Options{Quality: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.Encode(&encodedGIF, stdimage.NewPaletted(stdimage.Rect(0, 0, 2, 5), palette.Plan9), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		stream     []byte
+		width      int
+		height     int
+		format     string
+		colorModel string
+	}{
+		{"png", encodedPNG.Bytes(), 7, 3, "png", "NRGBA"},
+		{"jpeg", encodedJPEG.Bytes(), 16, 9, "jpeg", "YCbCr"},
+		{"gif", encodedGIF.Bytes(), 2, 5, "gif", "Paletted"},
+	}
+
+	for _, test := range tests {
+		for _, configOnly := range []bool{false, true} {
+			img, err := NewImageFromPath("/photos/" + test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if configOnly {
+				err = img.DecodeConfig(bytes.NewReader(test.stream))
+			} else {
+				err = img.Decode(bytes.NewReader(test.stream))
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			if img.Width() != test.width || img.Height() != test.height || img.Format() != test.format ||
+				img.ColorModelName() != test.colorModel || img.IsDecoded() == configOnly {
+				t.Errorf("%s (header only %v): decoded %dx%d %s %s, decoded %v", test.name, configOnly,
+					img.Width(), img.Height(), img.Format(), img.ColorModelName(), img.IsDecoded())
+			}
+			if !configOnly && img.Pixels().Bounds().Dx() != test.width {
+				t.Errorf("%s: the pixel buffer's bounds are %v", test.name, img.Pixels().Bounds())
+			}
+		}
+	}
+}
+
+// Function TestDecodeLimits verifies that an image larger than the limits is rejected before it is decoded,
+// that a zero limit is not enforced, and that a stream which is not an image is rejected.
+func TestDecodeLimits(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, stdimage.NewGray16(stdimage.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := NewImageFromPath("/photos/wide.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = img.SetLimits(Limits{MaxWidth: 39}); err != nil {
+		t.Fatal(err)
+	}
+	err = img.Decode(bytes.NewReader(encoded.Bytes()))
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || img.IsDecoded() {
+		t.Errorf("the image wider than the limit was decoded: %v", err)
+	}
+
+	if err = img.SetLimits(Limits{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = img.Decode(bytes.NewReader(encoded.Bytes())); err != nil || img.ColorModel() != color.Gray16Model {
+		t.Errorf("the image without limits was not decoded: %v", err)
+	}
+
+	if err = img.Decode(bytes.NewReader([]byte("BM not really a bitmap"))); err == nil {
+		t.Error("a stream that is not an image was decoded")
+	}
+	if err = img.DecodeConfig(bytes.NewReader(encoded.Bytes()[:20])); err == nil {
+		t.Error("a truncated header was decoded")
+	}
+}
+
+// Function TestLoad verifies that an image file is read from its path, and that a missing file is an error.
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tile.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = png.Encode(file, stdimage.NewGray(stdimage.Rect(0, 0, 12, 12)))
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := NewImageFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = img.LoadConfig(); err != nil || img.IsDecoded() || img.Width() != 12 {
+		t.Errorf("the header was loaded as %dx%d, decoded %v: %v", img.Width(), img.Height(), img.IsDecoded(), err)
+	}
+	if err = img.Load(); err != nil || !img.IsDecoded() || img.ColorModelName() != "Gray" {
+		t.Errorf("the image was loaded as %s, decoded %v: %v", img.ColorModelName(), img.IsDecoded(), err)
+	}
+
+	missing, err := NewImageFromPath(filepath.Join(filepath.Dir(path), "missing.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = missing.Load(); err == nil {
+		t.Error("a missing image was loaded")
+	}
+}
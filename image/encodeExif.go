@@ -0,0 +1,367 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// The EXIF tag of the GPS IFD's version, which is required when the GPS IFD is created.
+	kTagGPSVersionID = 0x0000
+
+	// The TIFF types of the values that are written.
+	kTiffByte     = 1
+	kTiffASCII    = 2
+	kTiffLong     = 4
+	kTiffRational = 5
+
+	// The denominator of the seconds of the GPS coordinates, which keeps them to within a few millimeters.
+	kGPSSecondsDenominator = 10000
+)
+
+// Type tiffField is an entry of an image file directory that is being written.
+type tiffField struct {
+	// Field tag identifies the entry.
+	tag uint16
+
+	// Field kind is the type of the entry's values.
+	kind uint16
+
+	// Field count is the number of values.
+	count uint32
+
+	// Field position is the offset of the entry from the beginning of the TIFF header, or 0 when it is new.
+	position int
+
+	// Field inline is the entry's last four bytes, as they were read: the values, or the offset to the values,
+	// which remains valid because the original data is kept in place.
+	inline []byte
+
+	// Field values are the bytes of the entry's new values, which are stored in the entry or at an offset.
+	values []byte
+}
+
+// Method EncodeExif updates EXIF data with when and where the image was taken: the DateTimeOriginal in the Exif IFD,
+// as the local time in the image's time zone with its offset in the OffsetTimeOriginal, and the coordinates in the
+// GPS IFD. Every other value is preserved, including those that are referenced by offsets, such as a maker note or
+// a thumbnail, because the original data is kept in place. A value whose size is unchanged is overwritten where it is,
+// and only a directory that gains an entry, or whose entry changes size, is appended after the data, so encoding the
+// same values again does not change the data.
+// Parameter data is the EXIF data, beginning with its TIFF header, or nil to create new EXIF data.
+// Returns the updated EXIF data, which is unchanged when the image's date/time and location are unknown or are
+// already recorded, or error.
+func (i Image) EncodeExif(data []byte) ([]byte, error) {
+	writeTime := !i.createdUtc.IsZero()
+	writeLocation := i.hasLocation
+	if !writeTime && !writeLocation {
+		return data, nil
+	}
+
+	if data == nil {
+		// A big-endian TIFF header, followed by an empty IFD0.
+		data = []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 0, 0, 0, 0, 0}
+	}
+	if len(data) < 8 {
+		return nil, errors.New("the EXIF data is corrupt")
+	}
+
+	reader := &tiffReader{data: append([]byte(nil), data...)}
+	switch string(data[:2]) {
+	case "II":
+		reader.order = binary.LittleEndian
+	case "MM":
+		reader.order = binary.BigEndian
+	default:
+		return nil, errors.New("the EXIF data is corrupt")
+	}
+
+	ifd0, next, err := reader.readFields(reader.order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	ifd0Changed := false
+
+	if writeTime {
+		exifIFD, err := reader.subFields(ifd0, kTagExifIFD)
+		if err != nil {
+			return nil, err
+		}
+
+		taken, err := i.localCreated()
+		if err != nil {
+			return nil, err
+		}
+
+		var changed bool
+		exifIFD, changed = reader.updateFields(exifIFD,
+			tiffField{tag: kTagDateTimeOriginal, kind: kTiffASCII, values: asciiValue(taken.Format(kExifTimeLayout))},
+			tiffField{tag: kTagOffsetTimeOriginal, kind: kTiffASCII, values: asciiValue(taken.Format("-07:00"))})
+		if changed {
+			var pointerChanged bool
+			ifd0, pointerChanged = reader.updateFields(ifd0, reader.longField(kTagExifIFD, reader.writeIFD(exifIFD, 0)))
+			ifd0Changed = ifd0Changed || pointerChanged
+		}
+	}
+
+	if writeLocation {
+		gpsIFD, err := reader.subFields(ifd0, kTagGPSIFD)
+		if err != nil {
+			return nil, err
+		}
+
+		updates := []tiffField{
+			{tag: kTagGPSLatitudeRef, kind: kTiffASCII, values: asciiValue(hemisphere(i.latlng.Latitude, "N", "S"))},
+			reader.coordinateField(kTagGPSLatitude, i.latlng.Latitude),
+			{tag: kTagGPSLongitudeRef, kind: kTiffASCII, values: asciiValue(hemisphere(i.latlng.Longitude, "E", "W"))},
+			reader.coordinateField(kTagGPSLongitude, i.latlng.Longitude),
+		}
+		if findField(gpsIFD, kTagGPSVersionID) < 0 {
+			updates = append(updates, tiffField{tag: kTagGPSVersionID, kind: kTiffByte, values: []byte{2, 3, 0, 0}})
+		}
+
+		var changed bool
+		gpsIFD, changed = reader.updateFields(gpsIFD, updates...)
+		if changed {
+			var pointerChanged bool
+			ifd0, pointerChanged = reader.updateFields(ifd0, reader.longField(kTagGPSIFD, reader.writeIFD(gpsIFD, 0)))
+			ifd0Changed = ifd0Changed || pointerChanged
+		}
+	}
+
+	if ifd0Changed {
+		// The directory is written before the header is updated, because appending it may move the data.
+		offset := reader.writeIFD(ifd0, next)
+		reader.order.PutUint32(reader.data[4:8], offset)
+	}
+
+	if bytes.Equal(reader.data, data) {
+		return data, nil
+	}
+	return reader.data, nil
+}
+
+// Method localCreated gets when the image was taken as the local time in its time zone,
+// or in UTC when the time zone is unknown.
+// Returns the date/time or error.
+func (i Image) localCreated() (time.Time, error) {
+	if i.timeZone == "" {
+		return i.createdUtc.UTC(), nil
+	}
+
+	zone, err := time.LoadLocation(i.timeZone)
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("%s%v", "failed to load the time zone: ", err))
+	}
+	return i.createdUtc.In(zone), nil
+}
+
+// Method updateFields sets the values of a directory's entries. An existing entry whose type and size are unchanged
+// is overwritten where it is stored, so the directory does not need to be written again.
+// Parameter fields are the directory's entries.
+// Parameter updates are the entries to set.
+// Returns the entries, and whether the directory must be written again because an entry was added or changed size.
+func (r *tiffReader) updateFields(fields []tiffField, updates ...tiffField) ([]tiffField, bool) {
+	changed := false
+	for _, update := range updates {
+		index := findField(fields, update.tag)
+		if index < 0 || !r.overwrite(&fields[index], update.kind, update.values) {
+			fields = setField(fields, update)
+			changed = true
+		}
+	}
+	return fields, changed
+}
+
+// Method overwrite replaces an existing entry's values where they are stored, when their type and size are unchanged.
+// Parameter field is the entry, whose inline bytes are updated.
+// Parameter kind is the type of the new values.
+// Parameter values are the bytes of the new values.
+// Returns whether the values were replaced.
+func (r *tiffReader) overwrite(field *tiffField, kind uint16, values []byte) bool {
+	size := tiffTypeSize(kind)
+	if field.position == 0 || field.values != nil || field.kind != kind || size == 0 ||
+		uint64(field.count)*uint64(size) != uint64(len(values)) {
+		return false
+	}
+
+	if len(values) <= 4 {
+		inline := make([]byte, 4)
+		copy(inline, values)
+		copy(r.data[field.position+8:field.position+12], inline)
+		field.inline = inline
+		return true
+	}
+
+	offset := uint64(r.order.Uint32(field.inline))
+	if offset+uint64(len(values)) > uint64(len(r.data)) {
+		return false
+	}
+	copy(r.data[offset:], values)
+	return true
+}
+
+// Method readFields reads the entries of an image file directory as they are stored, so they can be written again.
+// Parameter offset is the directory's offset from the beginning of the TIFF header.
+// Returns the entries, the offset of the next directory, or error.
+func (r *tiffReader) readFields(offset uint32) ([]tiffField, uint32, error) {
+	if uint64(offset)+2 > uint64(len(r.data)) {
+		return nil, 0, errors.New("the EXIF data is corrupt")
+	}
+
+	count := int(r.order.Uint16(r.data[offset:]))
+	start := int(offset) + 2
+	if start+12*count+4 > len(r.data) {
+		return nil, 0, errors.New("the EXIF data is corrupt")
+	}
+
+	fields := make([]tiffField, 0, count)
+	for n := 0; n < count; n++ {
+		raw := r.data[start+12*n : start+12*n+12]
+		fields = append(fields, tiffField{
+			tag:      r.order.Uint16(raw[0:2]),
+			kind:     r.order.Uint16(raw[2:4]),
+			count:    r.order.Uint32(raw[4:8]),
+			position: start + 12*n,
+			inline:   append([]byte(nil), raw[8:12]...),
+		})
+	}
+
+	return fields, r.order.Uint32(r.data[start+12*count:]), nil
+}
+
+// Method subFields reads the entries of a directory that is referenced by an entry of IFD0, such as the GPS IFD.
+// Parameter ifd0 is the entries of IFD0.
+// Parameter tag is the tag of the entry referencing the directory.
+// Returns the entries, which are empty when the directory does not exist, or error.
+func (r *tiffReader) subFields(ifd0 []tiffField, tag uint16) ([]tiffField, error) {
+	index := findField(ifd0, tag)
+	if index < 0 {
+		return nil, nil
+	}
+
+	fields, _, err := r.readFields(r.order.Uint32(ifd0[index].inline))
+	return fields, err
+}
+
+// Method writeIFD appends an image file directory to the EXIF data, followed by the values that do not fit in
+// its entries. The entries are written in ascending order of their tags, as TIFF requires.
+// Parameter fields are the directory's entries.
+// Parameter next is the offset of the next directory, or 0 when there is none.
+// Returns the offset of the directory.
+func (r *tiffReader) writeIFD(fields []tiffField, next uint32) uint32 {
+	sort.SliceStable(fields, func(a, b int) bool { return fields[a].tag < fields[b].tag })
+
+	// Directories and values begin on a word boundary.
+	if len(r.data)%2 != 0 {
+		r.data = append(r.data, 0)
+	}
+
+	offset := uint32(len(r.data))
+	directory := make([]byte, 2+12*len(fields)+4)
+	values := uint32(len(r.data) + len(directory))
+
+	r.order.PutUint16(directory, uint16(len(fields)))
+	var overflow []byte
+	for n, field := range fields {
+		raw := directory[2+12*n : 2+12*n+12]
+		r.order.PutUint16(raw[0:2], field.tag)
+		r.order.PutUint16(raw[2:4], field.kind)
+
+		switch {
+		case field.values == nil:
+			r.order.PutUint32(raw[4:8], field.count)
+			copy(raw[8:12], field.inline)
+		case len(field.values) <= 4:
+			r.order.PutUint32(raw[4:8], uint32(len(field.values)/tiffTypeSize(field.kind)))
+			copy(raw[8:12], field.values)
+		default:
+			r.order.PutUint32(raw[4:8], uint32(len(field.values)/tiffTypeSize(field.kind)))
+			r.order.PutUint32(raw[8:12], values+uint32(len(overflow)))
+			overflow = append(overflow, field.values...)
+			if len(overflow)%2 != 0 {
+				overflow = append(overflow, 0)
+			}
+		}
+	}
+	r.order.PutUint32(directory[len(directory)-4:], next)
+
+	r.data = append(r.data, directory...)
+	r.data = append(r.data, overflow...)
+
+	return offset
+}
+
+// Method longField creates an entry with a single unsigned integer value, such as the offset of a directory.
+func (r *tiffReader) longField(tag uint16, value uint32) tiffField {
+	values := make([]byte, 4)
+	r.order.PutUint32(values, value)
+	return tiffField{tag: tag, kind: kTiffLong, values: values}
+}
+
+// Method coordinateField creates a GPS latitude or longitude entry, as rational degrees, minutes and seconds.
+// Parameter tag is the tag of the entry.
+// Parameter coordinate is the latitude or longitude, in degrees, whose sign is recorded by its reference entry.
+func (r *tiffReader) coordinateField(tag uint16, coordinate float64) tiffField {
+	total := uint64(math.Round(math.Abs(coordinate) * 3600 * kGPSSecondsDenominator))
+	degrees := total / (3600 * kGPSSecondsDenominator)
+	minutes := total / (60 * kGPSSecondsDenominator) % 60
+	seconds := total % (60 * kGPSSecondsDenominator)
+
+	values := make([]byte, 24)
+	r.order.PutUint32(values[0:], uint32(degrees))
+	r.order.PutUint32(values[4:], 1)
+	r.order.PutUint32(values[8:], uint32(minutes))
+	r.order.PutUint32(values[12:], 1)
+	r.order.PutUint32(values[16:], uint32(seconds))
+	r.order.PutUint32(values[20:], kGPSSecondsDenominator)
+
+	return tiffField{tag: tag, kind: kTiffRational, values: values}
+}
+
+// Function findField gets the position of the entry with a tag, or -1 when there is none.
+func findField(fields []tiffField, tag uint16) int {
+	for index, field := range fields {
+		if field.tag == tag {
+			return index
+		}
+	}
+	return -1
+}
+
+// Function setField replaces the entry with the same tag, or adds the entry when there is none.
+// Returns the entries.
+func setField(fields []tiffField, field tiffField) []tiffField {
+	if index := findField(fields, field.tag); index >= 0 {
+		fields[index] = field
+		return fields
+	}
+	return append(fields, field)
+}
+
+// Function asciiValue encodes text as a TIFF ASCII value, which is terminated by a null byte.
+func asciiValue(text string) []byte {
+	return append([]byte(text), 0)
+}
+
+// Function hemisphere gets the GPS reference of a coordinate.
+// Parameter coordinate is the latitude or longitude, in degrees.
+// Parameter positive is the reference of a positive coordinate, "N" or "E".
+// Parameter negative is the reference of a negative coordinate, "S" or "W".
+func hemisphere(coordinate float64, positive string, negative string) string {
+	if coordinate < 0 {
+		return negative
+	}
+	return positive
+}
@@ -0,0 +1,119 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"bytes"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"math"
+	"testing"
+	"time"
+)
+
+// Variable littleEndianExif is little-endian EXIF data whose IFD0 records only the camera's make, "Canon",
+// which is stored at an offset, so it must survive an update in place.
+var littleEndianExif = []byte{
+	'I', 'I', 42, 0, 8, 0, 0, 0,
+	1, 0,
+	0x0f, 0x01, 2, 0, 6, 0, 0, 0, 26, 0, 0, 0,
+	0, 0, 0, 0,
+	'C', 'a', 'n', 'o', 'n', 0,
+}
+
+// Function TestEncodeExifRoundTrip verifies that the encoded date/time and location are parsed as they were encoded,
+// that the other values are preserved, and that encoding the same values again does not change the data.
+func TestEncodeExifRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		created   time.Time
+		timeZone  string
+		latitude  float64
+		longitude float64
+		make      string
+	}{
+		{"new data", nil, time.Date(2018, 7, 4, 19, 30, 15, 0, time.UTC), "America/Los_Angeles", 37.7749, -122.4194, ""},
+		{"little-endian data", littleEndianExif, time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC), "Asia/Kolkata", -33.8688, 151.2093, "Canon"},
+		{"no time zone", nil, time.Date(2018, 12, 31, 23, 59, 59, 0, time.UTC), "", 0.5, -0.5, ""},
+	}
+
+	for _, test := range tests {
+		img := &Image{}
+		if err := img.SetCreatedUtc(test.created); err != nil {
+			t.Fatal(err)
+		}
+		if err := img.SetLatLng(latlng.LatLng{Latitude: test.latitude, Longitude: test.longitude}); err != nil {
+			t.Fatal(err)
+		}
+		if err := img.SetTimeZone(test.timeZone); err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := img.EncodeExif(test.data)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		exif, err := parseExif(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !exif.DateTimeOriginal.Equal(test.created) || exif.LocalTime {
+			t.Errorf("%s: the date/time is %v (local %v), want %v", test.name, exif.DateTimeOriginal, exif.LocalTime, test.created)
+		}
+		if !exif.HasLocation || math.Abs(exif.Latitude-test.latitude) > 1e-6 || math.Abs(exif.Longitude-test.longitude) > 1e-6 {
+			t.Errorf("%s: the location is %v,%v, want %v,%v", test.name, exif.Latitude, exif.Longitude, test.latitude, test.longitude)
+		}
+		if exif.Make != test.make {
+			t.Errorf("%s: the make is %q, want %q", test.name, exif.Make, test.make)
+		}
+
+		again, err := img.EncodeExif(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !bytes.Equal(again, encoded) {
+			t.Errorf("%s: encoding the same values again changed the data from %d to %d bytes", test.name, len(encoded), len(again))
+		}
+
+		// Values of the same size are overwritten in place, so the data does not grow.
+		if err := img.SetCreatedUtc(test.created.Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if err := img.SetLatLng(latlng.LatLng{Latitude: test.latitude / 2, Longitude: test.longitude / 2}); err != nil {
+			t.Fatal(err)
+		}
+		changed, err := img.EncodeExif(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(changed) != len(encoded) {
+			t.Errorf("%s: updating the values changed the data from %d to %d bytes", test.name, len(encoded), len(changed))
+		}
+		if exif, err = parseExif(changed); err != nil || !exif.DateTimeOriginal.Equal(test.created.Add(time.Hour)) {
+			t.Errorf("%s: the updated date/time is %v, %v", test.name, exif, err)
+		}
+	}
+}
+
+// Function TestEncodeExifUnknown verifies that the data is unchanged when the date/time and location are unknown,
+// and that corrupt data is rejected.
+func TestEncodeExifUnknown(t *testing.T) {
+	img := &Image{}
+	if data, err := img.EncodeExif(littleEndianExif); err != nil || !bytes.Equal(data, littleEndianExif) {
+		t.Errorf("the data without a date/time or location = %v, %v", data, err)
+	}
+
+	if err := img.SetCreatedUtc(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	for _, corrupt := range [][]byte{{'I', 'I'}, []byte("XX*\x00\x08\x00\x00\x00"), {'M', 'M', 0, 42, 0xff, 0xff, 0xff, 0xff}} {
+		if _, err := img.EncodeExif(corrupt); err == nil {
+			t.Errorf("the corrupt data %q was accepted", corrupt)
+		}
+	}
+}
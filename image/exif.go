@@ -0,0 +1,577 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/abitofhelp/pipeline/timezone"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// Constants for the containers of the metadata, which are shared with the steps that inject the metadata into images.
+const (
+	// The signature at the beginning of a PNG image.
+	PNGSignature = "\x89PNG\r\n\x1a\n"
+
+	// The identifier at the beginning of a JPEG APP1 segment containing EXIF data.
+	ExifIdentifier = "Exif\x00\x00"
+
+	// The maximum size of the metadata in a PNG chunk, which protects against a corrupt chunk length.
+	MaxMetadataSize = 16 * 1024 * 1024
+)
+
+const (
+	// The layout of the EXIF date/time values.
+	kExifTimeLayout = "2006:01:02 15:04:05"
+
+	// The EXIF tags that are read from IFD0.
+	kTagMake        = 0x010F
+	kTagModel       = 0x0110
+	kTagOrientation = 0x0112
+	kTagExifIFD     = 0x8769
+	kTagGPSIFD      = 0x8825
+
+	// The EXIF tags that are read from the Exif IFD.
+	kTagDateTimeOriginal   = 0x9003
+	kTagOffsetTimeOriginal = 0x9011
+
+	// The EXIF tags that are read from the GPS IFD.
+	kTagGPSLatitudeRef  = 0x0001
+	kTagGPSLatitude     = 0x0002
+	kTagGPSLongitudeRef = 0x0003
+	kTagGPSLongitude    = 0x0004
+	kTagGPSTimeStamp    = 0x0007
+	kTagGPSDateStamp    = 0x001D
+)
+
+// Variable ErrNoExif is the error when an image does not contain any EXIF metadata.
+var ErrNoExif = errors.New("the image does not contain EXIF metadata")
+
+// Type Exif is the metadata that is read from an image's EXIF data.
+type Exif struct {
+	// Field Make is the manufacturer of the camera.
+	Make string
+
+	// Field Model is the model of the camera.
+	Model string
+
+	// Field Orientation is how the image must be rotated or flipped to be displayed upright, from 1 to 8, or 0 when it is unknown.
+	Orientation int
+
+	// Field DateTimeOriginal is the date/time when the image was taken, in UTC, or zero when it is unknown.
+	// When the camera did not record its time zone, the GPS time is used, otherwise the camera's clock is assumed to be UTC.
+	DateTimeOriginal time.Time
+
+	// Field LocalTime indicates whether DateTimeOriginal is the camera's clock, which was assumed to be UTC,
+	// because the camera recorded neither its time zone nor the GPS time.
+	LocalTime bool
+
+	// Field Latitude is the latitude where the image was taken, in degrees, which is valid when HasLocation is true.
+	Latitude float64
+
+	// Field Longitude is the longitude where the image was taken, in degrees, which is valid when HasLocation is true.
+	Longitude float64
+
+	// Field HasLocation indicates whether the image contains the location where it was taken.
+	HasLocation bool
+}
+
+// Function ReadExif reads the EXIF metadata from a JPEG image's APP1 segment or a PNG image's eXIf chunk.
+// Only the beginning of a JPEG image is read, while a PNG image is read until its eXIf chunk is found.
+// Parameter reader is the stream containing the image.
+// Returns the metadata, ErrNoExif when there is none, or error.
+func ReadExif(reader io.Reader) (*Exif, error) {
+	buffered := bufio.NewReader(reader)
+
+	signature, err := buffered.Peek(8)
+	if err != nil && len(signature) < 2 {
+		return nil, errors.New(fmt.Sprintf("%s%v", "failed to read the image: ", err))
+	}
+
+	var tiff []byte
+	switch {
+	case bytes.HasPrefix(signature, []byte{0xFF, 0xD8}):
+		tiff, err = findJPEGExif(buffered)
+	case bytes.Equal(signature, []byte(PNGSignature)):
+		tiff, err = findPNGExif(buffered)
+	default:
+		return nil, errors.New("the EXIF metadata can only be read from JPEG and PNG images")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseExif(tiff)
+}
+
+// Variable errNotFound is the error when an image does not contain the segment or chunk being searched for.
+var errNotFound = errors.New("the image does not contain the metadata")
+
+// Function findJPEGExif finds the EXIF data in the APP1 segment of a JPEG image.
+// Parameter reader is the stream containing the image.
+// Returns the EXIF data, beginning with its TIFF header, ErrNoExif, or error.
+func findJPEGExif(reader *bufio.Reader) ([]byte, error) {
+	data, err := findJPEGSegment(reader, 0xE1, ExifIdentifier)
+	if err == errNotFound {
+		return nil, ErrNoExif
+	}
+	return data, err
+}
+
+// Function findPNGExif finds the EXIF data in the eXIf chunk of a PNG image.
+// Parameter reader is the stream containing the image.
+// Returns the EXIF data, beginning with its TIFF header, ErrNoExif, or error.
+func findPNGExif(reader *bufio.Reader) ([]byte, error) {
+	data, err := findPNGChunk(reader, "eXIf", "")
+	if err == errNotFound {
+		return nil, ErrNoExif
+	}
+	return data, err
+}
+
+// Function findJPEGSegment finds the first segment of a JPEG image that has a marker and begins with an identifier.
+// Parameter reader is the stream containing the image.
+// Parameter marker is the segment's marker, such as 0xE1 for APP1.
+// Parameter identifier is the beginning of the segment's data, such as "Exif\x00\x00".
+// Returns the segment's data after the identifier, errNotFound, or error.
+func findJPEGSegment(reader *bufio.Reader, marker byte, identifier string) ([]byte, error) {
+	_, err := reader.Discard(2)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var header [2]byte
+		_, err := io.ReadFull(reader, header[:])
+		if err != nil {
+			return nil, errNotFound
+		}
+		if header[0] != 0xFF {
+			return nil, errors.New("the JPEG image is corrupt")
+		}
+
+		// The metadata segments precede the image data, so the search ends at the start of the scan.
+		switch header[1] {
+		case 0xD9, 0xDA:
+			return nil, errNotFound
+		case 0xFF:
+			reader.UnreadByte()
+			continue
+		case 0x01, 0xD0, 0xD1, 0xD2, 0xD3, 0xD4, 0xD5, 0xD6, 0xD7:
+			continue
+		}
+
+		var length uint16
+		err = binary.Read(reader, binary.BigEndian, &length)
+		if err != nil || length < 2 {
+			return nil, errors.New("the JPEG image is corrupt")
+		}
+
+		segment := make([]byte, length-2)
+		_, err = io.ReadFull(reader, segment)
+		if err != nil {
+			return nil, errors.New("the JPEG image is corrupt")
+		}
+
+		if header[1] == marker && bytes.HasPrefix(segment, []byte(identifier)) {
+			return segment[len(identifier):], nil
+		}
+	}
+}
+
+// Function findPNGChunk finds the first chunk of a PNG image that has a type and, for a text chunk, a keyword.
+// Parameter reader is the stream containing the image.
+// Parameter kind is the chunk's type, such as "eXIf".
+// Parameter keyword is the keyword at the beginning of the chunk's data, or empty to match any chunk of the type.
+// Returns the chunk's data after the keyword and its null terminator, errNotFound, or error.
+func findPNGChunk(reader *bufio.Reader, kind string, keyword string) ([]byte, error) {
+	_, err := reader.Discard(len(PNGSignature))
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var header [8]byte
+		_, err := io.ReadFull(reader, header[:])
+		if err != nil {
+			return nil, errNotFound
+		}
+
+		length := binary.BigEndian.Uint32(header[:4])
+		chunkKind := string(header[4:])
+
+		if chunkKind == "IEND" {
+			return nil, errNotFound
+		}
+
+		if chunkKind == kind {
+			if length > MaxMetadataSize {
+				return nil, errors.New("the PNG image's " + kind + " chunk is too large")
+			}
+			data := make([]byte, length)
+			_, err = io.ReadFull(reader, data)
+			if err != nil {
+				return nil, errors.New("the PNG image is corrupt")
+			}
+			if keyword == "" {
+				return data, nil
+			}
+			if bytes.HasPrefix(data, append([]byte(keyword), 0)) {
+				return data[len(keyword)+1:], nil
+			}
+
+			// Skip the chunk's CRC.
+			_, err = reader.Discard(4)
+			if err != nil {
+				return nil, errNotFound
+			}
+			continue
+		}
+
+		// Skip the chunk's data and its CRC.
+		_, err = io.CopyN(io.Discard, reader, int64(length)+4)
+		if err != nil {
+			return nil, errNotFound
+		}
+	}
+}
+
+// Type tiffReader reads the values of the entries in the image file directories (IFD) of EXIF data.
+type tiffReader struct {
+	// Field data is the EXIF data, beginning with its TIFF header.
+	data []byte
+
+	// Field order is the byte order of the data.
+	order binary.ByteOrder
+}
+
+// Type tiffEntry is an entry in an image file directory.
+type tiffEntry struct {
+	// Field kind is the type of the entry's values.
+	kind uint16
+
+	// Field count is the number of values.
+	count uint32
+
+	// Field values are the bytes of the values.
+	values []byte
+}
+
+// Function parseExif reads the metadata from EXIF data.
+// Parameter data is the EXIF data, beginning with its TIFF header.
+// Returns the metadata or error.
+func parseExif(data []byte) (*Exif, error) {
+	if len(data) < 8 {
+		return nil, errors.New("the EXIF data is corrupt")
+	}
+
+	reader := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		reader.order = binary.LittleEndian
+	case "MM":
+		reader.order = binary.BigEndian
+	default:
+		return nil, errors.New("the EXIF data is corrupt")
+	}
+
+	if reader.order.Uint16(data[2:4]) != 42 {
+		return nil, errors.New("the EXIF data is corrupt")
+	}
+
+	ifd0, err := reader.readIFD(reader.order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	exif := &Exif{
+		Make:  reader.ascii(ifd0[kTagMake]),
+		Model: reader.ascii(ifd0[kTagModel]),
+	}
+
+	if orientation, ok := reader.uint(ifd0[kTagOrientation]); ok && orientation >= 1 && orientation <= 8 {
+		exif.Orientation = int(orientation)
+	}
+
+	var exifIFD, gpsIFD map[uint16]tiffEntry
+	if offset, ok := reader.uint(ifd0[kTagExifIFD]); ok {
+		exifIFD, _ = reader.readIFD(offset)
+	}
+	if offset, ok := reader.uint(ifd0[kTagGPSIFD]); ok {
+		gpsIFD, _ = reader.readIFD(offset)
+	}
+
+	latitude, latOk := reader.coordinate(gpsIFD[kTagGPSLatitude], gpsIFD[kTagGPSLatitudeRef], "S")
+	longitude, lonOk := reader.coordinate(gpsIFD[kTagGPSLongitude], gpsIFD[kTagGPSLongitudeRef], "W")
+	if latOk && lonOk && math.Abs(latitude) <= 90 && math.Abs(longitude) <= 180 {
+		exif.Latitude, exif.Longitude, exif.HasLocation = latitude, longitude, true
+	}
+
+	exif.DateTimeOriginal, exif.LocalTime = reader.dateTimeOriginal(exifIFD, gpsIFD)
+
+	return exif, nil
+}
+
+// Method readIFD reads the entries of an image file directory.
+// Parameter offset is the directory's offset from the beginning of the TIFF header.
+// Returns the entries by tag, or error.
+func (r *tiffReader) readIFD(offset uint32) (map[uint16]tiffEntry, error) {
+	if uint64(offset)+2 > uint64(len(r.data)) {
+		return nil, errors.New("the EXIF data is corrupt")
+	}
+
+	count := int(r.order.Uint16(r.data[offset:]))
+	start := int(offset) + 2
+	if start+12*count > len(r.data) {
+		return nil, errors.New("the EXIF data is corrupt")
+	}
+
+	entries := make(map[uint16]tiffEntry, count)
+	for n := 0; n < count; n++ {
+		raw := r.data[start+12*n : start+12*n+12]
+		entry := tiffEntry{
+			kind:  r.order.Uint16(raw[2:4]),
+			count: r.order.Uint32(raw[4:8]),
+		}
+
+		size := uint64(tiffTypeSize(entry.kind)) * uint64(entry.count)
+		if size == 0 {
+			continue
+		}
+
+		// Values of up to four bytes are stored in the entry, and larger values at an offset.
+		if size <= 4 {
+			entry.values = raw[8 : 8+size]
+		} else {
+			valuesOffset := uint64(r.order.Uint32(raw[8:12]))
+			if valuesOffset+size > uint64(len(r.data)) {
+				continue
+			}
+			entry.values = r.data[valuesOffset : valuesOffset+size]
+		}
+
+		entries[r.order.Uint16(raw[0:2])] = entry
+	}
+
+	return entries, nil
+}
+
+// Function tiffTypeSize gets the size in bytes of a value of a TIFF type, or 0 for an unsupported type.
+func tiffTypeSize(kind uint16) int {
+	switch kind {
+	case 1, 2, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case 4, 9:
+		return 4
+	case 5, 10:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// Method ascii gets an entry's text, or empty when the entry is missing or is not text.
+func (r *tiffReader) ascii(entry tiffEntry) string {
+	if entry.kind != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(entry.values), "\x00"))
+}
+
+// Method uint gets an entry's first unsigned integer value.
+// Returns the value, or false when the entry is missing or is not an unsigned integer.
+func (r *tiffReader) uint(entry tiffEntry) (uint32, bool) {
+	switch {
+	case entry.kind == 3 && len(entry.values) >= 2:
+		return uint32(r.order.Uint16(entry.values)), true
+	case entry.kind == 4 && len(entry.values) >= 4:
+		return r.order.Uint32(entry.values), true
+	default:
+		return 0, false
+	}
+}
+
+// Method rational gets one of an entry's unsigned rational values.
+// Parameter entry is the entry.
+// Parameter index is the position of the value.
+// Returns the value, or false when the entry is missing, is not a rational, or its denominator is zero.
+func (r *tiffReader) rational(entry tiffEntry, index int) (float64, bool) {
+	if entry.kind != 5 || len(entry.values) < 8*(index+1) {
+		return 0, false
+	}
+
+	numerator := r.order.Uint32(entry.values[8*index:])
+	denominator := r.order.Uint32(entry.values[8*index+4:])
+	if denominator == 0 {
+		return 0, false
+	}
+
+	return float64(numerator) / float64(denominator), true
+}
+
+// Method coordinate gets a GPS latitude or longitude in degrees, from its degrees, minutes and seconds.
+// Parameter entry is the entry containing the degrees, minutes and seconds.
+// Parameter reference is the entry containing the hemisphere.
+// Parameter negative is the hemisphere whose coordinates are negative, "S" or "W".
+// Returns the coordinate, or false when it is missing.
+func (r *tiffReader) coordinate(entry tiffEntry, reference tiffEntry, negative string) (float64, bool) {
+	degrees, ok := r.rational(entry, 0)
+	minutes, minutesOk := r.rational(entry, 1)
+	seconds, secondsOk := r.rational(entry, 2)
+	if !ok || !minutesOk || !secondsOk {
+		return 0, false
+	}
+
+	value := degrees + minutes/60 + seconds/3600
+	if strings.EqualFold(r.ascii(reference), negative) {
+		value = -value
+	}
+
+	return value, true
+}
+
+// Method dateTimeOriginal gets the date/time when the image was taken, in UTC.
+// The camera's local time is converted using its recorded offset, or when there is no offset, the GPS time is used,
+// which is always in UTC. Otherwise, the camera's local time is assumed to be UTC.
+// Parameter exifIFD is the Exif IFD's entries.
+// Parameter gpsIFD is the GPS IFD's entries.
+// Returns the date/time, or zero when it is unknown, and whether it is the camera's local time that was assumed to be UTC.
+func (r *tiffReader) dateTimeOriginal(exifIFD map[uint16]tiffEntry, gpsIFD map[uint16]tiffEntry) (time.Time, bool) {
+	local := r.ascii(exifIFD[kTagDateTimeOriginal])
+
+	if offset := r.ascii(exifIFD[kTagOffsetTimeOriginal]); local != "" && offset != "" {
+		taken, err := time.Parse(kExifTimeLayout+"-07:00", local+offset)
+		if err == nil {
+			return taken.UTC(), false
+		}
+	}
+
+	if date := r.ascii(gpsIFD[kTagGPSDateStamp]); date != "" {
+		hours, hoursOk := r.rational(gpsIFD[kTagGPSTimeStamp], 0)
+		minutes, minutesOk := r.rational(gpsIFD[kTagGPSTimeStamp], 1)
+		seconds, secondsOk := r.rational(gpsIFD[kTagGPSTimeStamp], 2)
+		day, err := time.Parse("2006:01:02", date)
+		if err == nil && hoursOk && minutesOk && secondsOk {
+			return day.Add(time.Duration((hours*3600 + minutes*60 + seconds) * float64(time.Second))).UTC(), false
+		}
+	}
+
+	if local != "" {
+		taken, err := time.Parse(kExifTimeLayout, local)
+		if err == nil {
+			return taken.UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Method LoadExif reads the EXIF metadata from the image file, and records it in the instance.
+// Returns nil if there are no errors, or ErrNoExif when the image does not contain EXIF metadata.
+func (i *Image) LoadExif() error {
+	file, err := os.Open(i.fullPath())
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to open the image: ", err))
+	}
+	defer file.Close()
+
+	return i.ReadExif(file)
+}
+
+// Method ReadExif reads the EXIF metadata from a stream containing the image, and records it in the instance.
+// Parameter reader is the stream containing the image.
+// Returns nil if there are no errors, or ErrNoExif when the image does not contain EXIF metadata.
+func (i *Image) ReadExif(reader io.Reader) error {
+	exif, err := ReadExif(reader)
+	if err != nil {
+		return err
+	}
+
+	return i.SetExif(exif)
+}
+
+// Method locate records where the image was taken, and the time zone that is inferred from the location.
+// Parameter location is the latitude and longitude, in degrees.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) locate(location latlng.LatLng) error {
+	err := i.SetLatLng(location)
+	if err != nil {
+		return err
+	}
+
+	zone, err := timezone.Lookup(i.latlng)
+	if err != nil {
+		return err
+	}
+
+	return i.SetTimeZone(zone)
+}
+
+// Method localToUtc converts a date/time that was recorded without a time zone, such as a camera's clock,
+// from the time zone where the image was taken to UTC. It is unchanged when the time zone is unknown.
+// Parameter wallClock is the date/time as it was recorded.
+// Returns the date/time in UTC or error.
+func (i Image) localToUtc(wallClock time.Time) (time.Time, error) {
+	if i.timeZone == "" {
+		return wallClock, nil
+	}
+	return timezone.ToUtc(wallClock, i.timeZone)
+}
+
+// Method SetExif records the EXIF metadata in the instance: when the image was taken, where, and by which camera.
+// When the metadata contains the location, its time zone is inferred and recorded, and when the camera recorded
+// only its local time, the time is converted from that time zone to UTC.
+// The values that the metadata does not contain are left unchanged.
+// Parameter exif is the metadata.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetExif(exif *Exif) error {
+	if exif.HasLocation {
+		err := i.locate(latlng.LatLng{Latitude: exif.Latitude, Longitude: exif.Longitude})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !exif.DateTimeOriginal.IsZero() {
+		created := exif.DateTimeOriginal
+		if exif.LocalTime {
+			var err error
+			created, err = i.localToUtc(created)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := i.SetCreatedUtc(created)
+		if err != nil {
+			return err
+		}
+	}
+
+	if exif.Make != "" {
+		i.SetMake(exif.Make)
+	}
+	if exif.Model != "" {
+		i.SetModel(exif.Model)
+	}
+	if exif.Orientation != 0 {
+		i.SetOrientation(exif.Orientation)
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// Type testTiffEntry is an entry of an image file directory that is written by newTestTiff.
+type testTiffEntry struct {
+	tag    uint16
+	kind   uint16
+	count  uint32
+	values []byte
+}
+
+// Function testASCII creates an entry of text.
+func testASCII(tag uint16, text string) testTiffEntry {
+	return testTiffEntry{tag: tag, kind: 2, count: uint32(len(text) + 1), values: append([]byte(text), 0)}
+}
+
+// Function testShort creates an entry of an unsigned 16-bit integer.
+func testShort(order binary.ByteOrder, tag uint16, value uint16) testTiffEntry {
+	values := make([]byte, 2)
+	order.PutUint16(values, value)
+	return testTiffEntry{tag: tag, kind: 3, count: 1, values: values}
+}
+
+// Function testRationals creates an entry of unsigned rationals, from pairs of numerators and denominators.
+func testRationals(order binary.ByteOrder, tag uint16, fractions ...uint32) testTiffEntry {
+	values := make([]byte, 4*len(fractions))
+	for index, value := range fractions {
+		order.PutUint32(values[4*index:], value)
+	}
+	return testTiffEntry{tag: tag, kind: 5, count: uint32(len(fractions) / 2), values: values}
+}
+
+// Function newTestTiff lays out EXIF data with IFD0 and, when they have entries, the Exif and GPS IFDs,
+// which IFD0 points to. The values larger than four bytes follow the directories.
+func newTestTiff(order binary.ByteOrder, ifd0 []testTiffEntry, exifIFD []testTiffEntry, gpsIFD []testTiffEntry) []byte {
+	ifds := [][]testTiffEntry{append([]testTiffEntry{}, ifd0...)}
+	for _, sub := range []struct {
+		tag     uint16
+		entries []testTiffEntry
+	}{{kTagExifIFD, exifIFD}, {kTagGPSIFD, gpsIFD}} {
+		if len(sub.entries) > 0 {
+			ifds[0] = append(ifds[0], testTiffEntry{tag: sub.tag, kind: 4, count: 1, values: make([]byte, 4)})
+			ifds = append(ifds, sub.entries)
+		}
+	}
+
+	// The directories follow the header, and each sub IFD's pointer is the offset of its directory.
+	offsets := make([]uint32, len(ifds))
+	next := uint32(8)
+	for index, entries := range ifds {
+		offsets[index] = next
+		next += uint32(2 + 12*len(entries) + 4)
+	}
+	for index := range ifds[1:] {
+		order.PutUint32(ifds[0][len(ifd0)+index].values, offsets[index+1])
+	}
+
+	var data bytes.Buffer
+	if order == binary.ByteOrder(binary.LittleEndian) {
+		data.WriteString("II")
+	} else {
+		data.WriteString("MM")
+	}
+	binary.Write(&data, order, uint16(42))
+	binary.Write(&data, order, uint32(8))
+
+	var values []byte
+	for _, entries := range ifds {
+		binary.Write(&data, order, uint16(len(entries)))
+		for _, entry := range entries {
+			binary.Write(&data, order, entry.tag)
+			binary.Write(&data, order, entry.kind)
+			binary.Write(&data, order, entry.count)
+			if len(entry.values) <= 4 {
+				data.Write(entry.values)
+				data.Write(make([]byte, 4-len(entry.values)))
+			} else {
+				binary.Write(&data, order, next+uint32(len(values)))
+				values = append(values, entry.values...)
+			}
+		}
+		binary.Write(&data, order, uint32(0))
+	}
+	data.Write(values)
+	return data.Bytes()
+}
+
+// Function newTestJPEGWithExif wraps EXIF data in the APP1 segment of a JPEG stream, after its APP0 segment.
+func newTestJPEGWithExif(tiff []byte) []byte {
+	var stream bytes.Buffer
+	stream.Write([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10})
+	stream.WriteString("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+	if tiff != nil {
+		stream.Write([]byte{0xFF, 0xE1})
+		binary.Write(&stream, binary.BigEndian, uint16(2+len(ExifIdentifier)+len(tiff)))
+		stream.WriteString(ExifIdentifier)
+		stream.Write(tiff)
+	}
+	stream.Write([]byte{0xFF, 0xDA, 0x00, 0x02, 0x12, 0x34})
+	return stream.Bytes()
+}
+
+// Function newTestPNGWithExif places EXIF data in the eXIf chunk of a PNG stream, after its IHDR and IDAT chunks.
+// The CRCs are not checked when the metadata is read, so they are left zero.
+func newTestPNGWithExif(tiff []byte) []byte {
+	var stream bytes.Buffer
+	stream.WriteString(PNGSignature)
+	chunk := func(kind string, data []byte) {
+		binary.Write(&stream, binary.BigEndian, uint32(len(data)))
+		stream.WriteString(kind)
+		stream.Write(data)
+		stream.Write(make([]byte, 4))
+	}
+	chunk("IHDR", make([]byte, 13))
+	chunk("IDAT", []byte{0x78, 0x9C})
+	if tiff != nil {
+		chunk("eXIf", tiff)
+	}
+	chunk("IEND", nil)
+	return stream.Bytes()
+}
+
+// Function TestReadExif verifies that the camera, orientation, location and date/time are read from JPEG and PNG
+// images in either byte order, and how the date/time is converted to UTC.
+func TestReadExif(t *testing.T) {
+	big, little := binary.ByteOrder(binary.BigEndian), binary.ByteOrder(binary.LittleEndian)
+
+	tests := []struct {
+		name   string
+		stream []byte
+		want   Exif
+	}{
+		{
+			"JPEG with an offset",
+			newTestJPEGWithExif(newTestTiff(big,
+				[]testTiffEntry{testASCII(kTagMake, "NIKON CORPORATION"), testASCII(kTagModel, "NIKON D850 "), testShort(big, kTagOrientation, 6)},
+				[]testTiffEntry{testASCII(kTagDateTimeOriginal, "2018:05:20 08:15:00"), testASCII(kTagOffsetTimeOriginal, "+09:00")},
+				[]testTiffEntry{
+					testASCII(kTagGPSLatitudeRef, "N"), testRationals(big, kTagGPSLatitude, 35, 1, 39, 1, 36, 1),
+					testASCII(kTagGPSLongitudeRef, "E"), testRationals(big, kTagGPSLongitude, 139, 1, 42, 1, 0, 1),
+				})),
+			Exif{Make: "NIKON CORPORATION", Model: "NIKON D850", Orientation: 6, DateTimeOriginal: time.Date(2018, 5, 19, 23, 15, 0, 0, time.UTC),
+				Latitude: 35.66, Longitude: 139.7, HasLocation: true},
+		},
+		{
+			"PNG with the GPS time",
+			newTestPNGWithExif(newTestTiff(little,
+				[]testTiffEntry{testASCII(kTagModel, "Pixel 3")},
+				[]testTiffEntry{testASCII(kTagDateTimeOriginal, "2018:11:03 07:05:30")},
+				[]testTiffEntry{
+					testASCII(kTagGPSLatitudeRef, "S"), testRationals(little, kTagGPSLatitude, 3375, 100, 0, 1, 0, 1),
+					testASCII(kTagGPSLongitudeRef, "W"), testRationals(little, kTagGPSLongitude, 7050, 100, 0, 1, 0, 1),
+					testASCII(kTagGPSDateStamp, "2018:11:03"), testRationals(little, kTagGPSTimeStamp, 14, 1, 5, 1, 3050, 100),
+				})),
+			Exif{Model: "Pixel 3", DateTimeOriginal: time.Date(2018, 11, 3, 14, 5, 30, 500000000, time.UTC),
+				Latitude: -33.75, Longitude: -70.5, HasLocation: true},
+		},
+		{
+			"invalid dates",
+			newTestJPEGWithExif(newTestTiff(little, []testTiffEntry{testShort(little, kTagOrientation, 1)},
+				[]testTiffEntry{testASCII(kTagDateTimeOriginal, "2018:02:29 12:00:00"), testASCII(kTagOffsetTimeOriginal, "+01:00")},
+				[]testTiffEntry{testASCII(kTagGPSDateStamp, "2018:02:28")})),
+			Exif{Orientation: 1},
+		},
+		{
+			"values out of range",
+			newTestPNGWithExif(newTestTiff(big, []testTiffEntry{testShort(big, kTagOrientation, 9)},
+				[]testTiffEntry{testASCII(kTagDateTimeOriginal, "2018:01:01 00:00:00")},
+				[]testTiffEntry{
+					testRationals(big, kTagGPSLatitude, 91, 1, 0, 1, 0, 1), testRationals(big, kTagGPSLongitude, 1, 1, 0, 1, 0, 1),
+				})),
+			Exif{DateTimeOriginal: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), LocalTime: true},
+		},
+		{
+			"zero denominator",
+			newTestJPEGWithExif(newTestTiff(big, []testTiffEntry{testASCII(kTagMake, "Canon")}, nil,
+				[]testTiffEntry{
+					testRationals(big, kTagGPSLatitude, 10, 0, 0, 1, 0, 1), testRationals(big, kTagGPSLongitude, 1, 1, 0, 1, 0, 1),
+				})),
+			Exif{Make: "Canon"},
+		},
+	}
+
+	for _, test := range tests {
+		exif, err := ReadExif(bytes.NewReader(test.stream))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if math.Abs(exif.Latitude-test.want.Latitude) > 1e-9 || math.Abs(exif.Longitude-test.want.Longitude) > 1e-9 {
+			t.Errorf("%s: the location is %v,%v, want %v,%v", test.name, exif.Latitude, exif.Longitude, test.want.Latitude, test.want.Longitude)
+		}
+		exif.Latitude, exif.Longitude = test.want.Latitude, test.want.Longitude
+		if *exif != test.want {
+			t.Errorf("%s: read %+v, want %+v", test.name, *exif, test.want)
+		}
+	}
+}
+
+// Function TestReadExifWithout verifies that an image without EXIF metadata is reported as ErrNoExif,
+// and that a corrupt image or metadata, or another format, is an error.
+func TestReadExifWithout(t *testing.T) {
+	valid := newTestTiff(binary.LittleEndian, []testTiffEntry{testASCII(kTagMake, "Sony")}, nil, nil)
+	xmpOnly := newTestJPEGWithExif(nil)
+	xmpOnly = append(xmpOnly[:len(xmpOnly)-6], 0xFF, 0xE1, 0x00, byte(2+len(XmpIdentifier)+2))
+	xmpOnly = append(append(xmpOnly, XmpIdentifier...), "<>"...)
+	xmpOnly = append(xmpOnly, 0xFF, 0xDA, 0x00, 0x02)
+
+	tooLarge := newTestPNGWithExif(valid)
+	binary.BigEndian.PutUint32(tooLarge[8+12+13+12+2:], MaxMetadataSize+1)
+
+	tests := []struct {
+		name   string
+		stream []byte
+		noExif bool
+	}{
+		{"JPEG without APP1", newTestJPEGWithExif(nil), true},
+		{"JPEG with only XMP", xmpOnly, true},
+		{"PNG without eXIf", newTestPNGWithExif(nil), true},
+		{"GIF", []byte("GIF89a\x01\x00\x01\x00"), false},
+		{"empty", nil, false},
+		{"JPEG without a marker", append(newTestJPEGWithExif(nil)[:20], 0x00, 0xE1), false},
+		{"truncated TIFF", newTestJPEGWithExif(valid[:6]), false},
+		{"unknown byte order", newTestPNGWithExif(append([]byte("XX"), valid[2:]...)), false},
+		{"IFD beyond the data", newTestJPEGWithExif(append(valid[:4:4], 0, 1, 0, 0)), false},
+		{"eXIf too large", tooLarge, false},
+	}
+
+	for _, test := range tests {
+		exif, err := ReadExif(bytes.NewReader(test.stream))
+		if err == nil || (err == ErrNoExif) != test.noExif {
+			t.Errorf("%s: read %v, %v", test.name, exif, err)
+		}
+	}
+
+	if exif, err := ReadExif(bytes.NewReader(newTestPNGWithExif(valid))); err != nil || exif.Make != "Sony" {
+		t.Errorf("the valid eXIf chunk was read as %v, %v", exif, err)
+	}
+}
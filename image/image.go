@@ -8,15 +8,22 @@ package image
 
 import (
 	"errors"
-	"strings"
-	"time"
-
+	"fmt"
 	. "github.com/abitofhelp/go-helpers/string"
 	. "github.com/abitofhelp/go-helpers/time"
+	"github.com/abitofhelp/pipeline/geocode"
+	"github.com/abitofhelp/pipeline/geodesy"
 	"google.golang.org/genproto/googleapis/type/latlng"
+	stdimage "image"
+	"image/color"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
+// The greatest distance from where an image was taken to the city that it is named after, in meters.
+const kMaxPlaceDistance = 200000
+
 // Type Image contains metadata to associate with the image when it is persisted.
 type Image struct {
 	// The path of the image file.
@@ -25,11 +32,68 @@ type Image struct {
 	// Field filename is the filename without any path information.
 	filename string
 
-	// Field latlng is the latitude and longitude where the image was taken.
+	// Field latlng is the latitude and longitude where the image was taken, which is valid when hasLocation is true.
 	latlng latlng.LatLng
 
+	// Field hasLocation indicates whether the location where the image was taken is known,
+	// which distinguishes a location of 0,0 from an unknown location.
+	hasLocation bool
+
 	// Field createdUtc is the date/time when the image was taken, in UTC.
 	createdUtc time.Time
+
+	// Field timeZone is the IANA name of the time zone where the image was taken, such as "America/Los_Angeles",
+	// or empty when it is unknown.
+	timeZone string
+
+	// Field city is the name of the populated place nearest to where the image was taken, or empty when it is unknown.
+	city string
+
+	// Field region is the name of the first-order administrative division of the city, such as a state, or empty when it is unknown.
+	region string
+
+	// Field country is the name of the country of the city, or empty when it is unknown.
+	country string
+
+	// Field make is the manufacturer of the camera that took the image.
+	make string
+
+	// Field model is the model of the camera that took the image.
+	model string
+
+	// Field orientation is how the image must be rotated or flipped to be displayed upright, from 1 to 8, or 0 when it is unknown.
+	orientation int
+
+	// Field title is the image's title.
+	title string
+
+	// Field description is the description of the image's content.
+	description string
+
+	// Field subjects are the keywords describing the image's content.
+	subjects []string
+
+	// Field properties are the values that the pipeline's steps record about the image, by name.
+	properties map[string]string
+
+	// Field pixels is the decoded pixel buffer, or nil when the pixels have not been decoded.
+	pixels stdimage.Image
+
+	// Field width is the width of the image in pixels.
+	width int
+
+	// Field height is the height of the image in pixels.
+	height int
+
+	// Field colorModel is the color model of the image's pixels.
+	colorModel color.Model
+
+	// Field format is the name of the image's source format, such as "png", "jpeg" or "gif".
+	format string
+
+	// Field limits is the largest image that is decoded into a pixel buffer, or nil for the DefaultLimits,
+	// so an Image that was not created by a factory is still protected.
+	limits *Limits
 }
 
 // Function New is a factory that creates an initialized Image.
@@ -51,10 +115,8 @@ func NewImageFromPath(path string) (*Image, error) {
 
 	pipeline.SetFileName(filename)
 
-	err = pipeline.SetCreatedUtc(Zero())
-	if err != nil {
-		return nil, err
-	}
+	// The date/time when the image was taken is unknown until it is read from the image's metadata.
+	pipeline.createdUtc = Zero()
 
 	return pipeline, nil
 }
@@ -76,10 +138,8 @@ func New(path string, filename string) (*Image, error) {
 
 	pipeline.SetFileName(filename)
 
-	err = pipeline.SetCreatedUtc(Zero())
-	if err != nil {
-		return nil, err
-	}
+	// The date/time when the image was taken is unknown until it is read from the image's metadata.
+	pipeline.createdUtc = Zero()
 
 	return pipeline, nil
 }
@@ -125,14 +185,31 @@ func (i Image) LatLng() latlng.LatLng {
 	return i.latlng
 }
 
-// Method SetLatLng sets latitude and longitude for where the image was created.
+// Method SetLatLng sets latitude and longitude for where the image was created, which makes the location known.
 // If there is an error, an error is returned, otherwise nil.
 func (i *Image) SetLatLng(latLong latlng.LatLng) error {
-	i.latlng = latLong
+	err := geodesy.ValidateLatLng(latLong)
+	if err != nil {
+		return err
+	}
+
+	i.latlng = latlng.LatLng{Latitude: latLong.Latitude, Longitude: latLong.Longitude}
+	i.hasLocation = true
 
 	return nil
 }
 
+// Method HasLocation determines whether the latitude and longitude for where the image was created are known.
+func (i Image) HasLocation() bool {
+	return i.hasLocation
+}
+
+// Method ClearLatLng forgets the latitude and longitude for where the image was created, so the location is unknown.
+func (i *Image) ClearLatLng() {
+	i.latlng = latlng.LatLng{}
+	i.hasLocation = false
+}
+
 // Method Created gets the UTC date/time when the instance was created.
 func (i Image) CreatedUtc() time.Time {
 	return i.createdUtc
@@ -154,3 +231,189 @@ func (i *Image) SetCreatedUtc(createdUtc time.Time) error {
 
 	return nil
 }
+
+// Method TimeZone gets the IANA name of the time zone where the image was taken, or empty when it is unknown.
+func (i Image) TimeZone() string {
+	return i.timeZone
+}
+
+// Method SetTimeZone sets the IANA name of the time zone where the image was taken, such as "America/Los_Angeles",
+// or empty when it is unknown.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetTimeZone(timeZone string) error {
+	if timeZone != "" {
+		_, err := time.LoadLocation(timeZone)
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s%v", "the time zone is not valid: ", err))
+		}
+	}
+
+	i.timeZone = timeZone
+
+	return nil
+}
+
+// Method City gets the name of the populated place nearest to where the image was taken, or empty when it is unknown.
+func (i Image) City() string {
+	return i.city
+}
+
+// Method SetCity sets the name of the populated place nearest to where the image was taken.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetCity(city string) error {
+	i.city = city
+	return nil
+}
+
+// Method Region gets the name of the first-order administrative division of the city, such as a state, or empty when it is unknown.
+func (i Image) Region() string {
+	return i.region
+}
+
+// Method SetRegion sets the name of the first-order administrative division of the city, such as a state.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetRegion(region string) error {
+	i.region = region
+	return nil
+}
+
+// Method Country gets the name of the country of the city, or empty when it is unknown.
+func (i Image) Country() string {
+	return i.country
+}
+
+// Method SetCountry sets the name of the country of the city.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetCountry(country string) error {
+	i.country = country
+	return nil
+}
+
+// Method Geocode names the city, region and country nearest to where the image was taken, from an embedded dataset,
+// so no network access is needed. The names are cleared when the location is unknown or there is no city nearby.
+// Returns nil if there are no errors.
+func (i *Image) Geocode() error {
+	i.city, i.region, i.country = "", "", ""
+	if !i.hasLocation {
+		return nil
+	}
+
+	place, err := geocode.Nearest(i.latlng, kMaxPlaceDistance)
+	if err == geocode.ErrNoPlace {
+		return nil
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to geocode the image's location: ", err))
+	}
+
+	i.city, i.region, i.country = place.Name, place.Region, place.Country
+	return nil
+}
+
+// Method Make gets the manufacturer of the camera that took the image.
+func (i Image) Make() string {
+	return i.make
+}
+
+// Method SetMake sets the manufacturer of the camera that took the image.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetMake(make string) error {
+	i.make = make
+	return nil
+}
+
+// Method Model gets the model of the camera that took the image.
+func (i Image) Model() string {
+	return i.model
+}
+
+// Method SetModel sets the model of the camera that took the image.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetModel(model string) error {
+	i.model = model
+	return nil
+}
+
+// Method Orientation gets how the image must be rotated or flipped to be displayed upright, from 1 to 8, or 0 when it is unknown.
+func (i Image) Orientation() int {
+	return i.orientation
+}
+
+// Method SetOrientation sets how the image must be rotated or flipped to be displayed upright, using the EXIF values.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetOrientation(orientation int) error {
+	if orientation < 0 || orientation > 8 {
+		return errors.New("the orientation must be from 1 to 8, or 0 when it is unknown")
+	}
+	i.orientation = orientation
+	return nil
+}
+
+// Method Title gets the image's title.
+func (i Image) Title() string {
+	return i.title
+}
+
+// Method SetTitle sets the image's title.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetTitle(title string) error {
+	i.title = title
+	return nil
+}
+
+// Method Description gets the description of the image's content.
+func (i Image) Description() string {
+	return i.description
+}
+
+// Method SetDescription sets the description of the image's content.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetDescription(description string) error {
+	i.description = description
+	return nil
+}
+
+// Method Subjects gets the keywords describing the image's content.
+func (i Image) Subjects() []string {
+	return i.subjects
+}
+
+// Method SetSubjects sets the keywords describing the image's content.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetSubjects(subjects []string) error {
+	for _, subject := range subjects {
+		if strings.TrimSpace(subject) == "" {
+			return errors.New("a subject cannot be empty")
+		}
+	}
+	i.subjects = append([]string(nil), subjects...)
+	return nil
+}
+
+// Method Properties gets the values that the pipeline's steps recorded about the image, by name.
+func (i Image) Properties() map[string]string {
+	return i.properties
+}
+
+// Method Property gets a value that a pipeline's step recorded about the image.
+// Parameter name is the name of the value.
+// Returns the value, or false if it was not recorded.
+func (i Image) Property(name string) (string, bool) {
+	value, ok := i.properties[name]
+	return value, ok
+}
+
+// Method SetProperty records a value about the image, which is persisted in the pipeline's XMP namespace.
+// Parameter name is the name of the value, which must be a valid XML name without a prefix.
+// Parameter value is the value.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetProperty(name string, value string) error {
+	if !isXmlName(name) {
+		return errors.New("the property's name must be a valid XML name without a prefix: " + name)
+	}
+	if i.properties == nil {
+		i.properties = make(map[string]string)
+	}
+	i.properties[name] = value
+	return nil
+}
@@ -0,0 +1,326 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: image.proto
+
+package image
+
+import (
+	latlng "google.golang.org/genproto/googleapis/type/latlng"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ImageMetadata is the metadata of an image, so it can travel over gRPC or be stored in manifests.
+// It does not carry the image's pixels.
+type ImageMetadata struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The path of the image file.
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// The filename without any path information.
+	Filename string `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	// The latitude and longitude where the image was taken.
+	Latlng *latlng.LatLng `protobuf:"bytes,3,opt,name=latlng,proto3" json:"latlng,omitempty"`
+	// The date/time when the image was taken, in UTC, which is unset when it is unknown.
+	CreatedUtc *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_utc,json=createdUtc,proto3" json:"created_utc,omitempty"`
+	// The manufacturer of the camera that took the image.
+	Make string `protobuf:"bytes,5,opt,name=make,proto3" json:"make,omitempty"`
+	// The model of the camera that took the image.
+	Model string `protobuf:"bytes,6,opt,name=model,proto3" json:"model,omitempty"`
+	// How the image must be rotated or flipped to be displayed upright, from 1 to 8, or 0 when it is unknown.
+	Orientation int32 `protobuf:"varint,7,opt,name=orientation,proto3" json:"orientation,omitempty"`
+	// The image's title.
+	Title string `protobuf:"bytes,8,opt,name=title,proto3" json:"title,omitempty"`
+	// The description of the image's content.
+	Description string `protobuf:"bytes,9,opt,name=description,proto3" json:"description,omitempty"`
+	// The keywords describing the image's content.
+	Subjects []string `protobuf:"bytes,10,rep,name=subjects,proto3" json:"subjects,omitempty"`
+	// The values that the pipeline's steps recorded about the image, by name.
+	Properties map[string]string `protobuf:"bytes,11,rep,name=properties,proto3" json:"properties,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The width of the image in pixels.
+	Width int32 `protobuf:"varint,12,opt,name=width,proto3" json:"width,omitempty"`
+	// The height of the image in pixels.
+	Height int32 `protobuf:"varint,13,opt,name=height,proto3" json:"height,omitempty"`
+	// The name of the image's source format, such as "png", "jpeg" or "gif".
+	Format string `protobuf:"bytes,14,opt,name=format,proto3" json:"format,omitempty"`
+	// The name of the color model of the image's pixels, such as "RGBA" or "YCbCr".
+	ColorModel string `protobuf:"bytes,15,opt,name=color_model,json=colorModel,proto3" json:"color_model,omitempty"`
+	// The IANA name of the time zone where the image was taken, such as "America/Los_Angeles", or empty when it is unknown.
+	TimeZone string `protobuf:"bytes,16,opt,name=time_zone,json=timeZone,proto3" json:"time_zone,omitempty"`
+	// The name of the populated place nearest to where the image was taken, or empty when it is unknown.
+	City string `protobuf:"bytes,17,opt,name=city,proto3" json:"city,omitempty"`
+	// The name of the first-order administrative division of the city, such as a state, or empty when it is unknown.
+	Region string `protobuf:"bytes,18,opt,name=region,proto3" json:"region,omitempty"`
+	// The name of the country of the city, or empty when it is unknown.
+	Country       string `protobuf:"bytes,19,opt,name=country,proto3" json:"country,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImageMetadata) Reset() {
+	*x = ImageMetadata{}
+	mi := &file_image_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImageMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageMetadata) ProtoMessage() {}
+
+func (x *ImageMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_image_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageMetadata.ProtoReflect.Descriptor instead.
+func (*ImageMetadata) Descriptor() ([]byte, []int) {
+	return file_image_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ImageMetadata) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetLatlng() *latlng.LatLng {
+	if x != nil {
+		return x.Latlng
+	}
+	return nil
+}
+
+func (x *ImageMetadata) GetCreatedUtc() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedUtc
+	}
+	return nil
+}
+
+func (x *ImageMetadata) GetMake() string {
+	if x != nil {
+		return x.Make
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetOrientation() int32 {
+	if x != nil {
+		return x.Orientation
+	}
+	return 0
+}
+
+func (x *ImageMetadata) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetSubjects() []string {
+	if x != nil {
+		return x.Subjects
+	}
+	return nil
+}
+
+func (x *ImageMetadata) GetProperties() map[string]string {
+	if x != nil {
+		return x.Properties
+	}
+	return nil
+}
+
+func (x *ImageMetadata) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *ImageMetadata) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *ImageMetadata) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetColorModel() string {
+	if x != nil {
+		return x.ColorModel
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetTimeZone() string {
+	if x != nil {
+		return x.TimeZone
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+var File_image_proto protoreflect.FileDescriptor
+
+const file_image_proto_rawDesc = "" +
+	"\n" +
+	"\vimage.proto\x12\x19abitofhelp.pipeline.image\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x18google/type/latlng.proto\"\xac\x05\n" +
+	"\rImageMetadata\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12+\n" +
+	"\x06latlng\x18\x03 \x01(\v2\x13.google.type.LatLngR\x06latlng\x12;\n" +
+	"\vcreated_utc\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"createdUtc\x12\x12\n" +
+	"\x04make\x18\x05 \x01(\tR\x04make\x12\x14\n" +
+	"\x05model\x18\x06 \x01(\tR\x05model\x12 \n" +
+	"\vorientation\x18\a \x01(\x05R\vorientation\x12\x14\n" +
+	"\x05title\x18\b \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\t \x01(\tR\vdescription\x12\x1a\n" +
+	"\bsubjects\x18\n" +
+	" \x03(\tR\bsubjects\x12X\n" +
+	"\n" +
+	"properties\x18\v \x03(\v28.abitofhelp.pipeline.image.ImageMetadata.PropertiesEntryR\n" +
+	"properties\x12\x14\n" +
+	"\x05width\x18\f \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\r \x01(\x05R\x06height\x12\x16\n" +
+	"\x06format\x18\x0e \x01(\tR\x06format\x12\x1f\n" +
+	"\vcolor_model\x18\x0f \x01(\tR\n" +
+	"colorModel\x12\x1b\n" +
+	"\ttime_zone\x18\x10 \x01(\tR\btimeZone\x12\x12\n" +
+	"\x04city\x18\x11 \x01(\tR\x04city\x12\x16\n" +
+	"\x06region\x18\x12 \x01(\tR\x06region\x12\x18\n" +
+	"\acountry\x18\x13 \x01(\tR\acountry\x1a=\n" +
+	"\x0fPropertiesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B&Z$github.com/abitofhelp/pipeline/imageb\x06proto3"
+
+var (
+	file_image_proto_rawDescOnce sync.Once
+	file_image_proto_rawDescData []byte
+)
+
+func file_image_proto_rawDescGZIP() []byte {
+	file_image_proto_rawDescOnce.Do(func() {
+		file_image_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_image_proto_rawDesc), len(file_image_proto_rawDesc)))
+	})
+	return file_image_proto_rawDescData
+}
+
+var file_image_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_image_proto_goTypes = []any{
+	(*ImageMetadata)(nil),         // 0: abitofhelp.pipeline.image.ImageMetadata
+	nil,                           // 1: abitofhelp.pipeline.image.ImageMetadata.PropertiesEntry
+	(*latlng.LatLng)(nil),         // 2: google.type.LatLng
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+}
+var file_image_proto_depIdxs = []int32{
+	2, // 0: abitofhelp.pipeline.image.ImageMetadata.latlng:type_name -> google.type.LatLng
+	3, // 1: abitofhelp.pipeline.image.ImageMetadata.created_utc:type_name -> google.protobuf.Timestamp
+	1, // 2: abitofhelp.pipeline.image.ImageMetadata.properties:type_name -> abitofhelp.pipeline.image.ImageMetadata.PropertiesEntry
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_image_proto_init() }
+func file_image_proto_init() {
+	if File_image_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_image_proto_rawDesc), len(file_image_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_image_proto_goTypes,
+		DependencyIndexes: file_image_proto_depIdxs,
+		MessageInfos:      file_image_proto_msgTypes,
+	}.Build()
+	File_image_proto = out.File
+	file_image_proto_goTypes = nil
+	file_image_proto_depIdxs = nil
+}
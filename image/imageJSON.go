@@ -0,0 +1,179 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"time"
+)
+
+// Type imageFields is the serialized form of an Image's metadata, which does not include its pixels.
+type imageFields struct {
+	Path        string            `json:"path"`
+	Filename    string            `json:"filename"`
+	LatLng      *latLngFields     `json:"latlng,omitempty"`
+	CreatedUtc  *time.Time        `json:"createdUtc,omitempty"`
+	TimeZone    string            `json:"timeZone,omitempty"`
+	City        string            `json:"city,omitempty"`
+	Region      string            `json:"region,omitempty"`
+	Country     string            `json:"country,omitempty"`
+	Make        string            `json:"make,omitempty"`
+	Model       string            `json:"model,omitempty"`
+	Orientation int               `json:"orientation,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Subjects    []string          `json:"subjects,omitempty"`
+	Properties  map[string]string `json:"properties,omitempty"`
+	Width       int               `json:"width,omitempty"`
+	Height      int               `json:"height,omitempty"`
+	Format      string            `json:"format,omitempty"`
+	ColorModel  string            `json:"colorModel,omitempty"`
+}
+
+// Type latLngFields is the serialized form of a latitude and longitude.
+type latLngFields struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Method MarshalJSON converts the image's metadata to JSON, which does not include its pixels.
+// Returns the JSON or error.
+func (i Image) MarshalJSON() ([]byte, error) {
+	fields := imageFields{
+		Path:        i.path,
+		Filename:    i.filename,
+		TimeZone:    i.timeZone,
+		City:        i.city,
+		Region:      i.region,
+		Country:     i.country,
+		Make:        i.make,
+		Model:       i.model,
+		Orientation: i.orientation,
+		Title:       i.title,
+		Description: i.description,
+		Subjects:    i.subjects,
+		Properties:  i.properties,
+		Width:       i.width,
+		Height:      i.height,
+		Format:      i.format,
+		ColorModel:  i.ColorModelName(),
+	}
+
+	if i.hasLocation {
+		fields.LatLng = &latLngFields{Latitude: i.latlng.Latitude, Longitude: i.latlng.Longitude}
+	}
+
+	if !i.createdUtc.IsZero() {
+		created := i.createdUtc
+		fields.CreatedUtc = &created
+	}
+
+	return json.Marshal(fields)
+}
+
+// Method UnmarshalJSON replaces the image's metadata with the metadata in JSON, validating its values as the setters do.
+// A field that an Image does not have is an error. The pixels are discarded, since JSON does not include them.
+// Parameter data is the JSON.
+// Returns nil if there are no errors.
+func (i *Image) UnmarshalJSON(data []byte) error {
+	var fields imageFields
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&fields)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "the image's JSON is not valid: ", err))
+	}
+
+	image, err := fields.image()
+	if err != nil {
+		return err
+	}
+
+	// The limits are not metadata, so they are kept.
+	image.limits = i.limits
+	*i = *image
+	return nil
+}
+
+// Method image creates an Image from its serialized metadata, validating the values as the setters do.
+// Returns an initialized instance or error.
+func (f imageFields) image() (*Image, error) {
+	if f.Filename == "" {
+		return nil, errors.New("the file name cannot be empty")
+	}
+
+	image, err := New(f.Path, f.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.LatLng != nil {
+		err = image.SetLatLng(latlng.LatLng{Latitude: f.LatLng.Latitude, Longitude: f.LatLng.Longitude})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if f.CreatedUtc != nil {
+		err = image.SetCreatedUtc(*f.CreatedUtc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = image.SetTimeZone(f.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	image.SetCity(f.City)
+	image.SetRegion(f.Region)
+	image.SetCountry(f.Country)
+	image.SetMake(f.Make)
+	image.SetModel(f.Model)
+	image.SetTitle(f.Title)
+	image.SetDescription(f.Description)
+
+	err = image.SetOrientation(f.Orientation)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(f.Subjects) > 0 {
+		err = image.SetSubjects(f.Subjects)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for name, value := range f.Properties {
+		err = image.SetProperty(name, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if f.Width < 0 || f.Height < 0 {
+		return nil, errors.New("the image's dimensions cannot be negative")
+	}
+	image.width, image.height, image.format = f.Width, f.Height, f.Format
+
+	if f.ColorModel != "" {
+		model, err := colorModelNamed(f.ColorModel)
+		if err != nil {
+			return nil, err
+		}
+		image.colorModel = model
+	}
+
+	return image, nil
+}
@@ -0,0 +1,104 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+)
+
+// The protobuf message of an image's metadata, ImageMetadata, is generated from image.proto into image.pb.go.
+//go:generate protoc --proto_path=. --go_out=. --go_opt=paths=source_relative image.proto
+
+// Method ToProto converts the image's metadata to its protobuf message.
+// Returns the message or error.
+func (i Image) ToProto() (*ImageMetadata, error) {
+	if i.width > math.MaxInt32 || i.height > math.MaxInt32 {
+		return nil, errors.New("the image's dimensions are too large for its protobuf message")
+	}
+
+	message := &ImageMetadata{
+		Path:        i.path,
+		Filename:    i.filename,
+		Make:        i.make,
+		Model:       i.model,
+		Orientation: int32(i.orientation),
+		Title:       i.title,
+		Description: i.description,
+		Subjects:    i.subjects,
+		Properties:  i.properties,
+		Width:       int32(i.width),
+		Height:      int32(i.height),
+		Format:      i.format,
+		ColorModel:  i.ColorModelName(),
+		TimeZone:    i.timeZone,
+		City:        i.city,
+		Region:      i.region,
+		Country:     i.country,
+	}
+
+	if i.hasLocation {
+		message.Latlng = &latlng.LatLng{Latitude: i.latlng.Latitude, Longitude: i.latlng.Longitude}
+	}
+
+	if !i.createdUtc.IsZero() {
+		created := timestamppb.New(i.createdUtc)
+		if err := created.CheckValid(); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s%v", "failed to convert the createdUtc: ", err))
+		}
+		message.CreatedUtc = created
+	}
+
+	return message, nil
+}
+
+// Function NewImageFromProto is a factory that creates an Image from its protobuf message,
+// validating the message's values as the setters do.
+// Parameter message is the protobuf message.
+// Returns an initialized instance or error.
+func NewImageFromProto(message *ImageMetadata) (*Image, error) {
+	if message == nil {
+		return nil, errors.New("the image's protobuf message cannot be nil")
+	}
+
+	fields := imageFields{
+		Path:        message.GetPath(),
+		Filename:    message.GetFilename(),
+		Make:        message.GetMake(),
+		Model:       message.GetModel(),
+		Orientation: int(message.GetOrientation()),
+		Title:       message.GetTitle(),
+		Description: message.GetDescription(),
+		Subjects:    message.GetSubjects(),
+		Properties:  message.GetProperties(),
+		Width:       int(message.GetWidth()),
+		Height:      int(message.GetHeight()),
+		Format:      message.GetFormat(),
+		ColorModel:  message.GetColorModel(),
+		TimeZone:    message.GetTimeZone(),
+		City:        message.GetCity(),
+		Region:      message.GetRegion(),
+		Country:     message.GetCountry(),
+	}
+
+	if location := message.GetLatlng(); location != nil {
+		fields.LatLng = &latLngFields{Latitude: location.GetLatitude(), Longitude: location.GetLongitude()}
+	}
+
+	if message.GetCreatedUtc() != nil {
+		if err := message.GetCreatedUtc().CheckValid(); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s%v", "the createdUtc is not valid: ", err))
+		}
+		created := message.GetCreatedUtc().AsTime()
+		fields.CreatedUtc = &created
+	}
+
+	return fields.image()
+}
@@ -0,0 +1,124 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"errors"
+	"fmt"
+	stdimage "image"
+	"image/color"
+	"io"
+	"math"
+)
+
+// Type Limits is the largest image that is decoded, which protects against a decompression bomb,
+// such as a tiny PNG file whose header declares 50000x50000 pixels. A zero limit is not enforced.
+type Limits struct {
+	// Field MaxWidth is the greatest width of an image, in pixels.
+	MaxWidth uint64
+
+	// Field MaxHeight is the greatest height of an image, in pixels.
+	MaxHeight uint64
+
+	// Field MaxPixels is the greatest number of pixels in an image, its width times its height.
+	MaxPixels uint64
+
+	// Field MaxBytes is the greatest size of an image's decoded pixel buffer, in bytes.
+	MaxBytes uint64
+}
+
+// Variable DefaultLimits are the limits of an Image whose limits have not been set,
+// which admit the images of current cameras with room to spare.
+var DefaultLimits = Limits{
+	MaxWidth:  32768,
+	MaxHeight: 32768,
+	MaxPixels: 256 * 1000 * 1000,
+	MaxBytes:  1024 * 1024 * 1024,
+}
+
+// Type LimitError indicates that an image is larger than one of its limits, so it is not decoded.
+type LimitError struct {
+	// Field Limit is the name of the limit that was exceeded: "width", "height", "pixels" or "bytes".
+	Limit string
+
+	// Field Value is the image's value for the limit.
+	Value uint64
+
+	// Field Max is the limit.
+	Max uint64
+}
+
+// Method Error gets the description of the error.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("the image is too large: %d %s exceeds the limit of %d", e.Value, e.Limit, e.Max)
+}
+
+// Method IsLimited determines whether any of the limits is enforced.
+func (l Limits) IsLimited() bool {
+	return l.MaxWidth > 0 || l.MaxHeight > 0 || l.MaxPixels > 0 || l.MaxBytes > 0
+}
+
+// Method Check determines whether an image is within the limits, from the dimensions and color model in its header.
+// Parameter width is the width of the image, in pixels.
+// Parameter height is the height of the image, in pixels.
+// Parameter model is the color model of the image's pixels, which determines the size of its decoded pixel buffer.
+// Returns nil when the image is within the limits, otherwise a *LimitError.
+func (l Limits) Check(width int, height int, model color.Model) error {
+	// The dimensions that are declared in a header are at most 32 bits each, so only the size in bytes can overflow.
+	pixels := uint64(width) * uint64(height)
+	bytes := uint64(math.MaxUint64)
+	if size := bytesPerPixel(model); pixels <= math.MaxUint64/size {
+		bytes = pixels * size
+	}
+
+	switch {
+	case l.MaxWidth > 0 && uint64(width) > l.MaxWidth:
+		return &LimitError{Limit: "width", Value: uint64(width), Max: l.MaxWidth}
+	case l.MaxHeight > 0 && uint64(height) > l.MaxHeight:
+		return &LimitError{Limit: "height", Value: uint64(height), Max: l.MaxHeight}
+	case l.MaxPixels > 0 && pixels > l.MaxPixels:
+		return &LimitError{Limit: "pixels", Value: pixels, Max: l.MaxPixels}
+	case l.MaxBytes > 0 && bytes > l.MaxBytes:
+		return &LimitError{Limit: "bytes", Value: bytes, Max: l.MaxBytes}
+	}
+	return nil
+}
+
+// Method CheckHeader determines whether an image is within the limits by decoding only its header,
+// so an oversized image is rejected without allocating its pixel buffer.
+// Parameter reader is the stream containing the image, which is read only as far as its header.
+// Returns nil when the image is within the limits, a *LimitError when it is not, or another error.
+func (l Limits) CheckHeader(reader io.Reader) error {
+	config, _, err := stdimage.DecodeConfig(reader)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to decode the image's header: ", err))
+	}
+	return l.Check(config.Width, config.Height, config.ColorModel)
+}
+
+// Function bytesPerPixel gets the number of bytes that the decoder allocates for each pixel of a color model.
+// A YCbCr image is counted as though its chroma were not subsampled, and an unknown model as 64-bit RGBA,
+// so the size is never underestimated.
+// Parameter model is the color model.
+func bytesPerPixel(model color.Model) uint64 {
+	if _, ok := model.(color.Palette); ok {
+		return 1
+	}
+
+	switch model {
+	case color.AlphaModel, color.GrayModel:
+		return 1
+	case color.Alpha16Model, color.Gray16Model:
+		return 2
+	case color.YCbCrModel:
+		return 3
+	case color.RGBAModel, color.NRGBAModel, color.NYCbCrAModel, color.CMYKModel:
+		return 4
+	default:
+		return 8
+	}
+}
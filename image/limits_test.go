@@ -0,0 +1,110 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// Function TestLimitsCheck verifies the boundaries of each limit, and that the size in bytes saturates
+// rather than overflows for the largest dimensions that a header can declare.
+func TestLimitsCheck(t *testing.T) {
+	// The largest dimension that an int holds on every platform, whose square of 8-byte pixels overflows 64 bits.
+	const maxDimension = math.MaxInt32
+
+	tests := []struct {
+		limits Limits
+		width  int
+		height int
+		model  color.Model
+		limit  string
+		value  uint64
+	}{
+		{Limits{}, maxDimension, maxDimension, color.RGBA64Model, "", 0},
+		{Limits{MaxWidth: 100}, 100, 1, color.RGBAModel, "", 0},
+		{Limits{MaxWidth: 100}, 101, 1, color.RGBAModel, "width", 101},
+		{Limits{MaxHeight: 100}, 1, 100, color.RGBAModel, "", 0},
+		{Limits{MaxHeight: 100}, 1, 101, color.RGBAModel, "height", 101},
+		{Limits{MaxPixels: 100}, 10, 10, color.RGBAModel, "", 0},
+		{Limits{MaxPixels: 100}, 101, 1, color.RGBAModel, "pixels", 101},
+		{Limits{MaxBytes: 400}, 10, 10, color.RGBAModel, "", 0},
+		{Limits{MaxBytes: 400}, 10, 10, color.RGBA64Model, "bytes", 800},
+		{Limits{MaxBytes: 100}, 10, 10, color.GrayModel, "", 0},
+		{Limits{MaxBytes: 100}, 10, 10, color.Gray16Model, "bytes", 200},
+		{Limits{MaxBytes: math.MaxUint64 - 1}, maxDimension, maxDimension, color.RGBA64Model, "bytes", math.MaxUint64},
+		{Limits{MaxPixels: math.MaxUint64 - 1}, maxDimension, maxDimension, color.RGBAModel, "", 0},
+		{DefaultLimits, 32768, 32768, color.GrayModel, "pixels", 32768 * 32768},
+		{DefaultLimits, 32769, 1, color.GrayModel, "width", 32769},
+		{DefaultLimits, 16000, 16000, color.RGBA64Model, "bytes", 16000 * 16000 * 8},
+	}
+
+	for _, test := range tests {
+		err := test.limits.Check(test.width, test.height, test.model)
+
+		var limitErr *LimitError
+		switch {
+		case test.limit == "" && err != nil:
+			t.Errorf("%+v.Check(%d, %d) = %v, want nil", test.limits, test.width, test.height, err)
+		case test.limit != "" && !errors.As(err, &limitErr):
+			t.Errorf("%+v.Check(%d, %d) = %v, want a *LimitError", test.limits, test.width, test.height, err)
+		case test.limit != "" && (limitErr.Limit != test.limit || limitErr.Value != test.value):
+			t.Errorf("%+v.Check(%d, %d) exceeds the %s at %d, want the %s at %d",
+				test.limits, test.width, test.height, limitErr.Limit, limitErr.Value, test.limit, test.value)
+		}
+	}
+}
+
+// Function TestLimitsCheckHeader verifies that an image is rejected from the dimensions in its header alone.
+func TestLimitsCheckHeader(t *testing.T) {
+	// Function pngHeader creates a PNG stream that ends after its IHDR chunk, which declares an 8-bit RGBA image.
+	pngHeader := func(width uint32, height uint32) []byte {
+		chunk := make([]byte, 17)
+		copy(chunk, "IHDR")
+		binary.BigEndian.PutUint32(chunk[4:], width)
+		binary.BigEndian.PutUint32(chunk[8:], height)
+		chunk[12], chunk[13] = 8, 6
+
+		var stream bytes.Buffer
+		stream.WriteString(PNGSignature)
+		binary.Write(&stream, binary.BigEndian, uint32(len(chunk)-4))
+		stream.Write(chunk)
+		binary.Write(&stream, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+		return stream.Bytes()
+	}
+
+	tests := []struct {
+		width  uint32
+		height uint32
+		limit  string
+	}{
+		{640, 480, ""},
+		{50000, 50000, "width"},
+		{20000, 20000, "pixels"},
+	}
+
+	for _, test := range tests {
+		err := DefaultLimits.CheckHeader(bytes.NewReader(pngHeader(test.width, test.height)))
+
+		var limitErr *LimitError
+		switch {
+		case test.limit == "" && err != nil:
+			t.Errorf("the header of %dx%d = %v, want nil", test.width, test.height, err)
+		case test.limit != "" && (!errors.As(err, &limitErr) || limitErr.Limit != test.limit):
+			t.Errorf("the header of %dx%d = %v, want the %s limit", test.width, test.height, err, test.limit)
+		}
+	}
+
+	if err := DefaultLimits.CheckHeader(bytes.NewReader([]byte("not an image"))); err == nil {
+		t.Error("a stream that is not an image must be rejected")
+	}
+}
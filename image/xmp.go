@@ -0,0 +1,828 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Constants for the containers of the XMP packet, which are shared with the steps that inject the metadata into images.
+const (
+	// The identifier at the beginning of a JPEG APP1 segment containing an XMP packet.
+	XmpIdentifier = "http://ns.adobe.com/xap/1.0/\x00"
+
+	// The keyword of the PNG iTXt chunk containing an XMP packet.
+	XmpKeyword = "XML:com.adobe.xmp"
+)
+
+const (
+	// The namespaces of the XMP properties.
+	kNamespaceMeta     = "adobe:ns:meta/"
+	kNamespaceRDF      = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	kNamespaceXML      = "http://www.w3.org/XML/1998/namespace"
+	kNamespaceDC       = "http://purl.org/dc/elements/1.1/"
+	kNamespaceXMP      = "http://ns.adobe.com/xap/1.0/"
+	kNamespaceExif     = "http://ns.adobe.com/exif/1.0/"
+	kNamespacePipeline = "http://ns.abitofhelp.com/pipeline/1.0/"
+
+	// The denominator of the minutes of the XMP GPS coordinates, which keeps them to within a few millimeters.
+	kXmpMinutesDenominator = 1000000
+)
+
+// Variable ErrNoXmp is the error when an image does not contain an XMP packet.
+var ErrNoXmp = errors.New("the image does not contain an XMP packet")
+
+// Variable xmpPrefixes are the prefixes that are always used for the namespaces that the pipeline writes.
+var xmpPrefixes = map[string]string{
+	kNamespaceMeta:     "x",
+	kNamespaceRDF:      "rdf",
+	kNamespaceXML:      "xml",
+	kNamespaceDC:       "dc",
+	kNamespaceXMP:      "xmp",
+	kNamespaceExif:     "exif",
+	kNamespacePipeline: "pipeline",
+}
+
+// Variable xmpDateLayouts are the layouts of the XMP date/time values, from the most to the least precise,
+// with the smallest unit that each records and whether it has a time zone.
+var xmpDateLayouts = []struct {
+	layout    string
+	precision time.Duration
+	zoned     bool
+}{
+	{time.RFC3339Nano, time.Second, true},
+	{"2006-01-02T15:04:05.999999999", time.Second, false},
+	{"2006-01-02T15:04Z07:00", time.Minute, true},
+	{"2006-01-02T15:04", time.Minute, false},
+	{"2006-01-02", 24 * time.Hour, false},
+	{"2006-01", 31 * 24 * time.Hour, false},
+	{"2006", 366 * 24 * time.Hour, false},
+}
+
+// Type Xmp is the metadata that is read from and written to an image's XMP packet.
+// The properties that it does not map are preserved when the packet is written again.
+type Xmp struct {
+	// Field Title is the image's title (dc:title), in its default language.
+	Title string
+
+	// Field Description is the description of the image's content (dc:description), in its default language.
+	Description string
+
+	// Field Subject are the keywords describing the image's content (dc:subject).
+	Subject []string
+
+	// Field CreateDate is the date/time when the image was created (xmp:CreateDate), in UTC, or zero when it is unknown.
+	CreateDate time.Time
+
+	// Field CreateDateLocal indicates whether CreateDate is a local time without a time zone, which was assumed to be UTC.
+	CreateDateLocal bool
+
+	// Field CreateDatePrecision is the smallest unit that CreateDate records, such as time.Second for a date and time,
+	// or 24 hours for a date alone. A zero precision is treated as time.Second.
+	CreateDatePrecision time.Duration
+
+	// Field Latitude is the latitude where the image was taken (exif:GPSLatitude), which is valid when HasLocation is true.
+	Latitude float64
+
+	// Field Longitude is the longitude where the image was taken (exif:GPSLongitude), which is valid when HasLocation is true.
+	Longitude float64
+
+	// Field HasLocation indicates whether the packet contains the location where the image was taken.
+	HasLocation bool
+
+	// Field Properties are the values that the pipeline's steps recorded about the image, in the pipeline's namespace.
+	Properties map[string]string
+
+	// Field others are the properties that are not mapped, which are written again unchanged.
+	others []*xmpNode
+
+	// Field prefixes are the prefixes that the packet used for its namespaces, by namespace.
+	prefixes map[string]string
+}
+
+// Type xmpNode is an element of an XMP packet.
+type xmpNode struct {
+	// Field name is the element's name, whose space is the namespace rather than the prefix.
+	name xml.Name
+
+	// Field attrs are the element's attributes, without its namespace declarations.
+	attrs []xml.Attr
+
+	// Field children are the element's child elements.
+	children []*xmpNode
+
+	// Field text is the element's text.
+	text string
+}
+
+// Function ReadXmp reads the XMP packet from a JPEG image's APP1 segment or a PNG image's iTXt chunk.
+// Parameter reader is the stream containing the image.
+// Returns the metadata, ErrNoXmp when there is none, or error.
+func ReadXmp(reader io.Reader) (*Xmp, error) {
+	buffered := bufio.NewReader(reader)
+
+	signature, err := buffered.Peek(8)
+	if err != nil && len(signature) < 2 {
+		return nil, errors.New(fmt.Sprintf("%s%v", "failed to read the image: ", err))
+	}
+
+	var packet []byte
+	switch {
+	case bytes.HasPrefix(signature, []byte{0xFF, 0xD8}):
+		packet, err = findJPEGSegment(buffered, 0xE1, XmpIdentifier)
+	case bytes.Equal(signature, []byte(PNGSignature)):
+		packet, err = findPNGChunk(buffered, "iTXt", XmpKeyword)
+		if err == nil {
+			packet, err = InternationalText(packet)
+		}
+	default:
+		return nil, errors.New("the XMP packet can only be read from JPEG and PNG images")
+	}
+	if err == errNotFound {
+		return nil, ErrNoXmp
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseXmp(packet)
+}
+
+// Function InternationalText gets the text of a PNG iTXt chunk, decompressing it when it is compressed.
+// Parameter data is the chunk's data after its keyword.
+// Returns the text or error.
+func InternationalText(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, errors.New("the PNG image's iTXt chunk is corrupt")
+	}
+	compressed := data[0] == 1
+
+	// Skip the compression flag and method, then the language tag and the translated keyword.
+	text := data[2:]
+	for n := 0; n < 2; n++ {
+		end := bytes.IndexByte(text, 0)
+		if end < 0 {
+			return nil, errors.New("the PNG image's iTXt chunk is corrupt")
+		}
+		text = text[end+1:]
+	}
+
+	if !compressed {
+		return text, nil
+	}
+
+	decompressor, err := zlib.NewReader(bytes.NewReader(text))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s%v", "the PNG image's iTXt chunk is corrupt: ", err))
+	}
+	defer decompressor.Close()
+
+	return io.ReadAll(io.LimitReader(decompressor, MaxMetadataSize))
+}
+
+// Function ParseXmp reads the metadata from an XMP packet.
+// Parameter packet is the XMP packet.
+// Returns the metadata or error.
+func ParseXmp(packet []byte) (*Xmp, error) {
+	root, prefixes, err := parseXmpTree(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	xmp := &Xmp{prefixes: prefixes}
+
+	var latitude, longitude *xmpNode
+	for _, description := range root.descriptions() {
+		for _, property := range description.properties() {
+			switch property.name {
+			case xml.Name{Space: kNamespaceExif, Local: "GPSLatitude"}:
+				latitude = property
+			case xml.Name{Space: kNamespaceExif, Local: "GPSLongitude"}:
+				longitude = property
+			default:
+				if !xmp.setProperty(property) {
+					xmp.others = append(xmp.others, property)
+				}
+			}
+		}
+	}
+
+	// A location is only mapped when both of its coordinates are valid, otherwise they are preserved.
+	lat, latErr := parseXmpCoordinate(latitude, "S", 90)
+	lng, lngErr := parseXmpCoordinate(longitude, "W", 180)
+	if latErr == nil && lngErr == nil {
+		xmp.Latitude, xmp.Longitude, xmp.HasLocation = lat, lng, true
+	} else {
+		for _, property := range []*xmpNode{latitude, longitude} {
+			if property != nil {
+				xmp.others = append(xmp.others, property)
+			}
+		}
+	}
+
+	return xmp, nil
+}
+
+// Function parseXmpTree reads the elements of an XMP packet.
+// Parameter packet is the XMP packet.
+// Returns the root of the elements, the prefixes that the packet declared by namespace, or error.
+func parseXmpTree(packet []byte) (*xmpNode, map[string]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	root := &xmpNode{}
+	stack := []*xmpNode{root}
+	prefixes := make(map[string]string)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("%s%v", "the XMP packet is corrupt: ", err))
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmpNode{name: t.Name}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					prefixes[attr.Value] = attr.Name.Local
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+				default:
+					node.attrs = append(node.attrs, attr)
+				}
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			stack[len(stack)-1].text += string(t)
+		}
+	}
+
+	if len(stack) != 1 {
+		return nil, nil, errors.New("the XMP packet is truncated")
+	}
+
+	return root, prefixes, nil
+}
+
+// Method descriptions gets the rdf:Description elements of the rdf:RDF elements among the element's descendants,
+// which contain the packet's properties.
+func (n *xmpNode) descriptions() []*xmpNode {
+	var found []*xmpNode
+	for _, child := range n.children {
+		if child.name != (xml.Name{Space: kNamespaceRDF, Local: "RDF"}) {
+			found = append(found, child.descriptions()...)
+			continue
+		}
+		for _, description := range child.children {
+			if description.name == (xml.Name{Space: kNamespaceRDF, Local: "Description"}) {
+				found = append(found, description)
+			}
+		}
+	}
+	return found
+}
+
+// Method properties gets the properties of an rdf:Description element, which are its child elements,
+// and its attributes other than those of RDF and XML, as simple properties.
+func (n *xmpNode) properties() []*xmpNode {
+	var properties []*xmpNode
+	for _, attr := range n.attrs {
+		if attr.Name.Space == kNamespaceRDF || attr.Name.Space == kNamespaceXML || attr.Name.Space == "" {
+			continue
+		}
+		properties = append(properties, &xmpNode{name: attr.Name, text: attr.Value})
+	}
+	return append(properties, n.children...)
+}
+
+// Method items gets the values of an array property, or the value of a simple property.
+func (n *xmpNode) items() []string {
+	if len(n.children) == 0 {
+		return []string{n.text}
+	}
+
+	var items []string
+	for _, array := range n.children {
+		for _, item := range array.children {
+			if item.name == (xml.Name{Space: kNamespaceRDF, Local: "li"}) {
+				items = append(items, item.text)
+			}
+		}
+	}
+	return items
+}
+
+// Method defaultText gets the value of a language alternative property in its default language,
+// or its first language when it does not have a default.
+func (n *xmpNode) defaultText() string {
+	if len(n.children) == 0 {
+		return n.text
+	}
+
+	first := ""
+	for _, array := range n.children {
+		for index, item := range array.children {
+			for _, attr := range item.attrs {
+				if attr.Name == (xml.Name{Space: kNamespaceXML, Local: "lang"}) && attr.Value == "x-default" {
+					return item.text
+				}
+			}
+			if index == 0 {
+				first = item.text
+			}
+		}
+	}
+	return first
+}
+
+// Method setProperty maps a property of the packet to the metadata.
+// Returns true if the property was mapped, or false if it is preserved unchanged.
+func (x *Xmp) setProperty(property *xmpNode) bool {
+	switch property.name.Space {
+	case kNamespaceDC:
+		switch property.name.Local {
+		case "title":
+			x.Title = property.defaultText()
+			return true
+		case "description":
+			x.Description = property.defaultText()
+			return true
+		case "subject":
+			x.Subject = property.items()
+			return true
+		}
+	case kNamespaceXMP:
+		if property.name.Local == "CreateDate" && len(property.children) == 0 {
+			created, precision, zoned, err := parseXmpDate(property.text)
+			if err == nil {
+				x.CreateDate, x.CreateDatePrecision, x.CreateDateLocal = created, precision, !zoned
+				return true
+			}
+		}
+	case kNamespacePipeline:
+		if len(property.children) == 0 {
+			if x.Properties == nil {
+				x.Properties = make(map[string]string)
+			}
+			x.Properties[property.name.Local] = property.text
+			return true
+		}
+	}
+	return false
+}
+
+// Function parseXmpDate converts an XMP date/time to UTC.
+// Parameter value is the date/time, which is assumed to be in UTC when it does not have a time zone.
+// Returns the date/time, the smallest unit that it records, whether it has a time zone, or error.
+func parseXmpDate(value string) (time.Time, time.Duration, bool, error) {
+	value = strings.TrimSpace(value)
+	for _, format := range xmpDateLayouts {
+		created, err := time.Parse(format.layout, value)
+		if err == nil {
+			return created.UTC(), format.precision, format.zoned, nil
+		}
+	}
+	return time.Time{}, 0, false, errors.New("the XMP date/time is not valid: " + value)
+}
+
+// Function parseXmpCoordinate converts an XMP GPS coordinate, such as "33,51.408S" or "151,12,55.08E", to degrees.
+// Parameter property is the coordinate's property, or nil when it is missing.
+// Parameter negative is the hemisphere whose coordinates are negative, "S" or "W".
+// Parameter limit is the largest magnitude of the coordinate, 90 or 180.
+// Returns the coordinate or error.
+func parseXmpCoordinate(property *xmpNode, negative string, limit float64) (float64, error) {
+	if property == nil || len(property.children) != 0 {
+		return 0, errors.New("the XMP coordinate is missing")
+	}
+
+	value := strings.ToUpper(strings.TrimSpace(property.text))
+	if len(value) < 2 || !strings.ContainsAny(value[len(value)-1:], "NSEW") {
+		return 0, errors.New("the XMP coordinate is not valid: " + property.text)
+	}
+	reference, parts := value[len(value)-1:], strings.Split(value[:len(value)-1], ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, errors.New("the XMP coordinate is not valid: " + property.text)
+	}
+
+	coordinate := 0.0
+	for index, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 {
+			return 0, errors.New("the XMP coordinate is not valid: " + property.text)
+		}
+		coordinate += number / math.Pow(60, float64(index))
+	}
+
+	if coordinate > limit {
+		return 0, errors.New("the XMP coordinate is out of range: " + property.text)
+	}
+	if reference == negative {
+		coordinate = -coordinate
+	}
+
+	return coordinate, nil
+}
+
+// Function formatXmpCoordinate converts a coordinate in degrees to an XMP GPS coordinate, such as "33,51.408000S".
+// Parameter coordinate is the latitude or longitude, in degrees.
+// Parameter positive is the hemisphere of a positive coordinate, "N" or "E".
+// Parameter negative is the hemisphere of a negative coordinate, "S" or "W".
+func formatXmpCoordinate(coordinate float64, positive string, negative string) string {
+	total := uint64(math.Round(math.Abs(coordinate) * 60 * kXmpMinutesDenominator))
+	degrees := total / (60 * kXmpMinutesDenominator)
+	minutes := total % (60 * kXmpMinutesDenominator)
+	return fmt.Sprintf("%d,%d.%06d%s", degrees, minutes/kXmpMinutesDenominator, minutes%kXmpMinutesDenominator,
+		hemisphere(coordinate, positive, negative))
+}
+
+// Method IsEmpty determines whether the metadata does not contain any values to write.
+func (x Xmp) IsEmpty() bool {
+	return x.Title == "" && x.Description == "" && len(x.Subject) == 0 && x.CreateDate.IsZero() &&
+		!x.HasLocation && len(x.Properties) == 0 && len(x.others) == 0
+}
+
+// Method Encode writes the metadata as an XMP packet, followed by the properties that were preserved
+// when the packet was read.
+// Returns the XMP packet or error.
+func (x Xmp) Encode() ([]byte, error) {
+	var properties []*xmpNode
+	if x.Title != "" {
+		properties = append(properties, xmpAlternative(kNamespaceDC, "title", x.Title))
+	}
+	if x.Description != "" {
+		properties = append(properties, xmpAlternative(kNamespaceDC, "description", x.Description))
+	}
+	if len(x.Subject) > 0 {
+		properties = append(properties, xmpArray(kNamespaceDC, "subject", "Bag", x.Subject))
+	}
+	if !x.CreateDate.IsZero() {
+		properties = append(properties, &xmpNode{name: xml.Name{Space: kNamespaceXMP, Local: "CreateDate"},
+			text: x.CreateDate.UTC().Format(time.RFC3339)})
+	}
+	if x.HasLocation {
+		if math.Abs(x.Latitude) > 90 || math.Abs(x.Longitude) > 180 {
+			return nil, errors.New("the XMP location is not a valid latitude and longitude")
+		}
+		properties = append(properties,
+			&xmpNode{name: xml.Name{Space: kNamespaceExif, Local: "GPSLatitude"}, text: formatXmpCoordinate(x.Latitude, "N", "S")},
+			&xmpNode{name: xml.Name{Space: kNamespaceExif, Local: "GPSLongitude"}, text: formatXmpCoordinate(x.Longitude, "E", "W")})
+	}
+
+	names := make([]string, 0, len(x.Properties))
+	for name := range x.Properties {
+		if !isXmlName(name) {
+			return nil, errors.New("the property's name must be a valid XML name without a prefix: " + name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		properties = append(properties, &xmpNode{name: xml.Name{Space: kNamespacePipeline, Local: name}, text: x.Properties[name]})
+	}
+
+	// A preserved property is replaced by a mapped property with the same name.
+	written := make(map[xml.Name]bool, len(properties))
+	for _, property := range properties {
+		written[property.name] = true
+	}
+	for _, property := range x.others {
+		if !written[property.name] {
+			properties = append(properties, property)
+		}
+	}
+
+	writer := &xmpWriter{prefixes: make(map[string]string), preferred: x.prefixes}
+	for _, property := range properties {
+		writer.declare(property)
+	}
+
+	writer.buffer.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	writer.buffer.WriteString("<x:xmpmeta xmlns:x=\"" + kNamespaceMeta + "\">\n")
+	writer.buffer.WriteString(" <rdf:RDF xmlns:rdf=\"" + kNamespaceRDF + "\">\n")
+	writer.buffer.WriteString("  <rdf:Description rdf:about=\"\"")
+	writer.writeDeclarations("\n    ")
+	writer.buffer.WriteString(">\n")
+	for _, property := range properties {
+		writer.writeNode(property, "   ")
+	}
+	writer.buffer.WriteString("  </rdf:Description>\n")
+	writer.buffer.WriteString(" </rdf:RDF>\n")
+	writer.buffer.WriteString("</x:xmpmeta>\n")
+	writer.buffer.WriteString("<?xpacket end=\"w\"?>")
+
+	return writer.buffer.Bytes(), nil
+}
+
+// Function xmpAlternative creates a language alternative property with a value in the default language.
+func xmpAlternative(namespace string, name string, value string) *xmpNode {
+	item := &xmpNode{
+		name:  xml.Name{Space: kNamespaceRDF, Local: "li"},
+		attrs: []xml.Attr{{Name: xml.Name{Space: kNamespaceXML, Local: "lang"}, Value: "x-default"}},
+		text:  value,
+	}
+	alternative := &xmpNode{name: xml.Name{Space: kNamespaceRDF, Local: "Alt"}, children: []*xmpNode{item}}
+	return &xmpNode{name: xml.Name{Space: namespace, Local: name}, children: []*xmpNode{alternative}}
+}
+
+// Function xmpArray creates an array property.
+// Parameter kind is the kind of array: "Bag" for an unordered array, or "Seq" for an ordered array.
+func xmpArray(namespace string, name string, kind string, values []string) *xmpNode {
+	array := &xmpNode{name: xml.Name{Space: kNamespaceRDF, Local: kind}}
+	for _, value := range values {
+		array.children = append(array.children, &xmpNode{name: xml.Name{Space: kNamespaceRDF, Local: "li"}, text: value})
+	}
+	return &xmpNode{name: xml.Name{Space: namespace, Local: name}, children: []*xmpNode{array}}
+}
+
+// Type xmpWriter writes the elements of an XMP packet, with a prefix for each of their namespaces.
+type xmpWriter struct {
+	// Field buffer receives the packet.
+	buffer bytes.Buffer
+
+	// Field prefixes are the prefixes of the namespaces that are declared, by namespace.
+	prefixes map[string]string
+
+	// Field preferred are the prefixes that the packet used when it was read, by namespace.
+	preferred map[string]string
+}
+
+// Method declare assigns a prefix to each namespace used by an element and its descendants.
+func (w *xmpWriter) declare(node *xmpNode) {
+	w.prefix(node.name.Space)
+	for _, attr := range node.attrs {
+		w.prefix(attr.Name.Space)
+	}
+	for _, child := range node.children {
+		w.declare(child)
+	}
+}
+
+// Method prefix gets the prefix of a namespace, assigning one when it does not have one: the pipeline's prefix
+// for a namespace that it writes, otherwise the prefix that the packet used, unless it is taken.
+func (w *xmpWriter) prefix(namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+	if prefix, ok := w.prefixes[namespace]; ok {
+		return prefix
+	}
+
+	prefix, ok := xmpPrefixes[namespace]
+	if !ok {
+		prefix = w.preferred[namespace]
+		for n := 1; prefix == "" || w.isPrefixTaken(prefix); n++ {
+			prefix = "ns" + strconv.Itoa(n)
+		}
+	}
+	w.prefixes[namespace] = prefix
+	return prefix
+}
+
+// Method isPrefixTaken determines whether a prefix is used for another namespace, or is reserved for a namespace
+// that the pipeline writes.
+func (w *xmpWriter) isPrefixTaken(prefix string) bool {
+	for _, reserved := range xmpPrefixes {
+		if reserved == prefix {
+			return true
+		}
+	}
+	for _, taken := range w.prefixes {
+		if taken == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// Method writeDeclarations writes the declarations of the namespaces, other than those of RDF and XML.
+// Parameter separator precedes each declaration.
+func (w *xmpWriter) writeDeclarations(separator string) {
+	namespaces := make([]string, 0, len(w.prefixes))
+	for namespace := range w.prefixes {
+		if namespace != kNamespaceRDF && namespace != kNamespaceXML {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Slice(namespaces, func(a, b int) bool { return w.prefixes[namespaces[a]] < w.prefixes[namespaces[b]] })
+
+	for _, namespace := range namespaces {
+		w.buffer.WriteString(separator + "xmlns:" + w.prefixes[namespace] + "=\"")
+		xml.EscapeText(&w.buffer, []byte(namespace))
+		w.buffer.WriteString("\"")
+	}
+}
+
+// Method writeNode writes an element and its descendants.
+// Parameter node is the element.
+// Parameter indent precedes the element.
+func (w *xmpWriter) writeNode(node *xmpNode, indent string) {
+	name := w.qualifiedName(node.name)
+
+	w.buffer.WriteString(indent + "<" + name)
+	for _, attr := range node.attrs {
+		w.buffer.WriteString(" " + w.qualifiedName(attr.Name) + "=\"")
+		xml.EscapeText(&w.buffer, []byte(attr.Value))
+		w.buffer.WriteString("\"")
+	}
+
+	if len(node.children) == 0 {
+		w.buffer.WriteString(">")
+		xml.EscapeText(&w.buffer, []byte(node.text))
+		w.buffer.WriteString("</" + name + ">\n")
+		return
+	}
+
+	w.buffer.WriteString(">\n")
+	for _, child := range node.children {
+		w.writeNode(child, indent+" ")
+	}
+	w.buffer.WriteString(indent + "</" + name + ">\n")
+}
+
+// Method qualifiedName gets the name of an element or attribute with the prefix of its namespace.
+func (w *xmpWriter) qualifiedName(name xml.Name) string {
+	if prefix := w.prefix(name.Space); prefix != "" {
+		return prefix + ":" + name.Local
+	}
+	return name.Local
+}
+
+// Function isXmlName determines whether a name is a valid XML name without a prefix,
+// so it can be the name of a property in the pipeline's XMP namespace.
+func isXmlName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for index, r := range name {
+		switch {
+		case unicode.IsLetter(r), r == '_':
+		case index > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Method Xmp gets the image's metadata that is written to its XMP packet.
+func (i Image) Xmp() *Xmp {
+	xmp := &Xmp{
+		Title:       i.title,
+		Description: i.description,
+		Subject:     i.subjects,
+		CreateDate:  i.createdUtc,
+		Properties:  i.properties,
+	}
+
+	if i.hasLocation {
+		xmp.Latitude, xmp.Longitude, xmp.HasLocation = i.latlng.Latitude, i.latlng.Longitude, true
+	}
+
+	return xmp
+}
+
+// Method EncodeXmp updates an XMP packet with the image's metadata. The values that the image does not have,
+// and the properties that are not mapped to the image, such as those of other applications, are preserved.
+// Parameter packet is the XMP packet, or nil to create a new XMP packet.
+// Returns the updated XMP packet, which is unchanged when the image does not have any metadata to write, or error.
+func (i Image) EncodeXmp(packet []byte) ([]byte, error) {
+	update := i.Xmp()
+	if update.IsEmpty() {
+		return packet, nil
+	}
+
+	xmp := &Xmp{}
+	if packet != nil {
+		var err error
+		xmp, err = ParseXmp(packet)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if update.Title != "" {
+		xmp.Title = update.Title
+	}
+	if update.Description != "" {
+		xmp.Description = update.Description
+	}
+	if len(update.Subject) > 0 {
+		xmp.Subject = update.Subject
+	}
+	if !update.CreateDate.IsZero() {
+		xmp.CreateDate = update.CreateDate
+	}
+	if update.HasLocation {
+		xmp.Latitude, xmp.Longitude, xmp.HasLocation = update.Latitude, update.Longitude, true
+	}
+	for name, value := range update.Properties {
+		if xmp.Properties == nil {
+			xmp.Properties = make(map[string]string)
+		}
+		xmp.Properties[name] = value
+	}
+
+	return xmp.Encode()
+}
+
+// Method LoadXmp reads the XMP packet from the image file, and records its metadata in the instance.
+// Returns nil if there are no errors, or ErrNoXmp when the image does not contain an XMP packet.
+func (i *Image) LoadXmp() error {
+	file, err := os.Open(i.fullPath())
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to open the image: ", err))
+	}
+	defer file.Close()
+
+	return i.ReadXmp(file)
+}
+
+// Method ReadXmp reads the XMP packet from a stream containing the image, and records its metadata in the instance.
+// Parameter reader is the stream containing the image.
+// Returns nil if there are no errors, or ErrNoXmp when the image does not contain an XMP packet.
+func (i *Image) ReadXmp(reader io.Reader) error {
+	xmp, err := ReadXmp(reader)
+	if err != nil {
+		return err
+	}
+
+	return i.SetXmp(xmp)
+}
+
+// Method SetXmp records the XMP metadata in the instance: its title, description, subjects, when and where
+// the image was taken, and the pipeline's properties. The values that the metadata does not contain are left unchanged.
+// A date/time without a time zone is converted from the time zone where the image was taken, as the EXIF time is,
+// and a date/time that is less precise than a second does not replace a known date/time, such as the EXIF time.
+// Parameter xmp is the metadata.
+// If there is an error, an error is returned, otherwise nil.
+func (i *Image) SetXmp(xmp *Xmp) error {
+	if xmp.Title != "" {
+		i.SetTitle(xmp.Title)
+	}
+	if xmp.Description != "" {
+		i.SetDescription(xmp.Description)
+	}
+	if len(xmp.Subject) > 0 {
+		err := i.SetSubjects(xmp.Subject)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The location is recorded first, because it determines the time zone of a local date/time.
+	if xmp.HasLocation {
+		err := i.locate(latlng.LatLng{Latitude: xmp.Latitude, Longitude: xmp.Longitude})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !xmp.CreateDate.IsZero() && (i.createdUtc.IsZero() || xmp.CreateDatePrecision <= time.Second) {
+		created := xmp.CreateDate
+		if xmp.CreateDateLocal {
+			var err error
+			created, err = i.localToUtc(created)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := i.SetCreatedUtc(created)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, value := range xmp.Properties {
+		err := i.SetProperty(name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
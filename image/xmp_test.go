@@ -0,0 +1,194 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package image contains methods to manipulate png image files.
+package image
+
+import (
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Constant kTestXmpPacket is an XMP packet with the mapped properties, and with properties of other applications
+// in attributes, in elements and in a structure, which must be preserved.
+const kTestXmpPacket = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    xmlns:Iptc4xmpCore="http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/"
+    xmlns:pipeline="http://ns.abitofhelp.com/pipeline/1.0/"
+    photoshop:City="Sydney"
+    xmp:CreatorTool="Camera Firmware 1.0">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Harbour</rdf:li></rdf:Alt></dc:title>
+   <dc:description><rdf:Alt><rdf:li xml:lang="x-default">The bridge at dusk</rdf:li></rdf:Alt></dc:description>
+   <dc:subject><rdf:Bag><rdf:li>bridge</rdf:li><rdf:li>harbour</rdf:li></rdf:Bag></dc:subject>
+   <xmp:CreateDate>2018-03-04T18:30:15+11:00</xmp:CreateDate>
+   <exif:GPSLatitude>33,51.408S</exif:GPSLatitude>
+   <exif:GPSLongitude>151,12.558E</exif:GPSLongitude>
+   <pipeline:camera>primary</pipeline:camera>
+   <photoshop:Headline>Harbour Bridge</photoshop:Headline>
+   <Iptc4xmpCore:CreatorContactInfo rdf:parseType="Resource">
+    <Iptc4xmpCore:CiEmailWork>photos@example.com</Iptc4xmpCore:CiEmailWork>
+   </Iptc4xmpCore:CreatorContactInfo>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+// Function TestXmpRoundTrip verifies that the mapped properties are parsed as they were written,
+// and that the properties that are not mapped survive being written again, including after an update.
+func TestXmpRoundTrip(t *testing.T) {
+	xmp, err := ParseXmp([]byte(kTestXmpPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, xmp *Xmp, title string) {
+		if xmp.Title != title || xmp.Description != "The bridge at dusk" {
+			t.Errorf("%s: the title and description are %q, %q", name, xmp.Title, xmp.Description)
+		}
+		if !reflect.DeepEqual(xmp.Subject, []string{"bridge", "harbour"}) {
+			t.Errorf("%s: the subjects are %v", name, xmp.Subject)
+		}
+		if want := time.Date(2018, 3, 4, 7, 30, 15, 0, time.UTC); !xmp.CreateDate.Equal(want) || xmp.CreateDateLocal {
+			t.Errorf("%s: the date/time is %v (local %v), want %v", name, xmp.CreateDate, xmp.CreateDateLocal, want)
+		}
+		if !xmp.HasLocation || math.Abs(xmp.Latitude+33.8568) > 1e-6 || math.Abs(xmp.Longitude-151.2093) > 1e-6 {
+			t.Errorf("%s: the location is %v,%v", name, xmp.Latitude, xmp.Longitude)
+		}
+		if xmp.Properties["camera"] != "primary" {
+			t.Errorf("%s: the properties are %v", name, xmp.Properties)
+		}
+	}
+	check("parsed", xmp, "Harbour")
+
+	unmapped := []string{"Sydney", "Camera Firmware 1.0", "Harbour Bridge", "photos@example.com"}
+
+	encoded, err := xmp.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range unmapped {
+		if !strings.Contains(string(encoded), value) {
+			t.Errorf("the encoded packet lost the unmapped value %q", value)
+		}
+	}
+	reparsed, err := ParseXmp(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("encoded", reparsed, "Harbour")
+
+	img := &Image{}
+	if err = img.SetTitle("Opera House"); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := img.EncodeXmp(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range unmapped {
+		if !strings.Contains(string(updated), value) {
+			t.Errorf("the updated packet lost the unmapped value %q", value)
+		}
+	}
+	reparsed, err = ParseXmp(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("updated", reparsed, "Opera House")
+
+	again, err := reparsed.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(updated) {
+		t.Error("encoding the parsed packet again changed it")
+	}
+}
+
+// Function TestParseXmpDate verifies the precision and time zone of each layout of an XMP date/time.
+func TestParseXmpDate(t *testing.T) {
+	tests := []struct {
+		value     string
+		utc       time.Time
+		precision time.Duration
+		zoned     bool
+		fails     bool
+	}{
+		{"2018-03-04T18:30:15.25+11:00", time.Date(2018, 3, 4, 7, 30, 15, 250000000, time.UTC), time.Second, true, false},
+		{"2018-03-04T18:30:15Z", time.Date(2018, 3, 4, 18, 30, 15, 0, time.UTC), time.Second, true, false},
+		{"2018-03-04T18:30:15", time.Date(2018, 3, 4, 18, 30, 15, 0, time.UTC), time.Second, false, false},
+		{"2018-03-04T18:30-08:00", time.Date(2018, 3, 5, 2, 30, 0, 0, time.UTC), time.Minute, true, false},
+		{"2018-03-04T18:30", time.Date(2018, 3, 4, 18, 30, 0, 0, time.UTC), time.Minute, false, false},
+		{" 2018-03-04 ", time.Date(2018, 3, 4, 0, 0, 0, 0, time.UTC), 24 * time.Hour, false, false},
+		{"2018-03", time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), 31 * 24 * time.Hour, false, false},
+		{"2018", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 366 * 24 * time.Hour, false, false},
+		{"March 4, 2018", time.Time{}, 0, false, true},
+		{"", time.Time{}, 0, false, true},
+	}
+
+	for _, test := range tests {
+		utc, precision, zoned, err := parseXmpDate(test.value)
+		if (err != nil) != test.fails || !utc.Equal(test.utc) || precision != test.precision || zoned != test.zoned {
+			t.Errorf("parseXmpDate(%q) = %v, %v, %v, %v", test.value, utc, precision, zoned, err)
+		}
+	}
+}
+
+// Function TestSetXmpCreateDate verifies that a date/time without a time zone is converted from the time zone
+// where the image was taken, and that a less precise date/time does not replace a known one.
+func TestSetXmpCreateDate(t *testing.T) {
+	known := time.Date(2018, 3, 4, 2, 30, 15, 0, time.UTC)
+	sanFrancisco := latlng.LatLng{Latitude: 37.7749, Longitude: -122.4194}
+
+	tests := []struct {
+		name    string
+		known   time.Time
+		value   string
+		located bool
+		created time.Time
+	}{
+		{"a local time where the image was taken", time.Time{}, "2018-03-03T18:30:15", true, known},
+		{"a local time without a location", time.Time{}, "2018-03-03T18:30:15", false, time.Date(2018, 3, 3, 18, 30, 15, 0, time.UTC)},
+		{"a zoned time", time.Time{}, "2018-03-03T18:30:15-08:00", false, known},
+		{"a precise time over a known time", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), "2018-03-03T18:30:15-08:00", false, known},
+		{"a year over a known time", known, "2018", false, known},
+		{"a date over a known time", known, "2018-03-04", true, known},
+		{"a year over an unknown time", time.Time{}, "2018", false, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		created, precision, zoned, err := parseXmpDate(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xmp := &Xmp{CreateDate: created, CreateDatePrecision: precision, CreateDateLocal: !zoned}
+		if test.located {
+			xmp.Latitude, xmp.Longitude, xmp.HasLocation = sanFrancisco.Latitude, sanFrancisco.Longitude, true
+		}
+
+		img := &Image{}
+		if !test.known.IsZero() {
+			if err = img.SetCreatedUtc(test.known); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = img.SetXmp(xmp); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !img.CreatedUtc().Equal(test.created) {
+			t.Errorf("%s: the date/time is %v, want %v", test.name, img.CreatedUtc(), test.created)
+		}
+	}
+}
@@ -27,6 +27,7 @@ func main() {
 		Usage: "Scans a directory path for image files and injects them into a pipeline for processing.",
 		Commands: []*cli.Command{
 			&cmd.Start,
+			&cmd.Scan,
 		},
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
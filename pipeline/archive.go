@@ -0,0 +1,283 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// The separator between the path to an archive and the name of a member within it, as in "batch.zip!/dir/img.png".
+const kArchiveSeparator = "!/"
+
+// Type archiveKind indicates the format of an archive file.
+type archiveKind int
+
+// Constants for the values of an archiveKind.
+const (
+	notArchive archiveKind = iota
+	zipArchive
+	tarArchive
+	tarGzipArchive
+)
+
+// Function archiveKindOf determines the format of an archive from its file name.
+// Parameter name is the path or name of the file.
+// Returns the format, or notArchive if the file is not a supported archive.
+func archiveKindOf(name string) archiveKind {
+	name = strings.ToLower(name)
+
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return zipArchive
+	case strings.HasSuffix(name, ".tar"):
+		return tarArchive
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return tarGzipArchive
+	default:
+		return notArchive
+	}
+}
+
+// Function IsArchiveMemberPath determines whether a path refers to a member within an archive.
+// Parameter path is the path to check.
+func IsArchiveMemberPath(path string) bool {
+	_, _, ok := SplitArchiveMemberPath(path)
+	return ok
+}
+
+// Function SplitArchiveMemberPath separates a virtual path into the path to the archive and the member's name.
+// Parameter path is a virtual path such as "batch.zip!/dir/img.png".
+// Returns the archive's path, the member's name, and false if the path does not refer to a member within an archive.
+func SplitArchiveMemberPath(path string) (string, string, bool) {
+	for start := 0; ; {
+		i := strings.Index(path[start:], kArchiveSeparator)
+		if i < 0 {
+			return "", "", false
+		}
+		i += start
+
+		// A directory may contain the separator in its name, so the part before it must name an archive.
+		if archiveKindOf(path[:i]) != notArchive {
+			return path[:i], path[i+len(kArchiveSeparator):], true
+		}
+		start = i + len(kArchiveSeparator)
+	}
+}
+
+// Function listArchive invokes a callback with the virtual path, information and beginning of each regular file
+// within an archive. The beginning is read as the archive is listed, so a member's format can be detected without
+// opening the member again, which would read a compressed tar archive from its start for every member.
+// Archives within archives are not descended into.
+// Parameter fileSystem is the file system containing the archive.
+// Parameter path is the path to the archive.
+// Parameter callback is invoked with the virtual path, information and first kFormatHeaderSize bytes of each member.
+// Returns nil if there are no errors.
+func listArchive(fileSystem fs.FS, path string, callback func(memberPath string, info fs.FileInfo, header []byte)) error {
+
+	if archiveKindOf(path) == zipArchive {
+		reader, closer, err := openZip(fileSystem, path)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		for _, member := range reader.File {
+			if member.FileInfo().Mode().IsRegular() {
+				// A member that cannot be read is still listed, and the error is encountered when it is processed.
+				var header []byte
+				if stream, err := member.Open(); err == nil {
+					header, _ = readFormatHeader(stream)
+					stream.Close()
+				}
+				callback(path+kArchiveSeparator+cleanMemberName(member.Name), member.FileInfo(), header)
+			}
+		}
+		return nil
+	}
+
+	reader, closer, err := openTar(fileSystem, path)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("failed to read the archive %s: %v", path, err))
+		}
+
+		if header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA {
+			beginning, _ := readFormatHeader(reader)
+			callback(path+kArchiveSeparator+cleanMemberName(header.Name), header.FileInfo(), beginning)
+		}
+	}
+}
+
+// Function OpenPath opens an operating system file for reading as a stream,
+// where the path may refer to a member within an archive.
+// Parameter path is the path to a file, or a virtual path such as "batch.zip!/dir/img.png".
+// Returns the stream, which the caller must close, or error.
+func OpenPath(path string) (io.ReadCloser, error) {
+	return OpenPathInFileSystem(osFileSystem{}, path)
+}
+
+// Function OpenPathInFileSystem opens a file for reading as a stream, where the path may refer to a member within an archive.
+// A member is read directly from its archive, without extracting the archive to disk.
+// A remote file, from an HTTP listing, is read from its web server.
+// Parameter fileSystem is the file system containing the file.
+// Parameter path is the path to a file, a virtual path such as "batch.zip!/dir/img.png", or a URL.
+// Returns the stream, which the caller must close, or error.
+func OpenPathInFileSystem(fileSystem fs.FS, path string) (io.ReadCloser, error) {
+	if IsRemotePath(path) {
+		return openRemote(path)
+	}
+
+	archive, name, ok := SplitArchiveMemberPath(path)
+	if !ok {
+		return fileSystem.Open(path)
+	}
+
+	if archiveKindOf(archive) == zipArchive {
+		reader, closer, err := openZip(fileSystem, archive)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, member := range reader.File {
+			if cleanMemberName(member.Name) == name {
+				stream, err := member.Open()
+				if err != nil {
+					closer.Close()
+					return nil, err
+				}
+				return &memberReader{Reader: stream, closers: []io.Closer{stream, closer}}, nil
+			}
+		}
+
+		closer.Close()
+		return nil, errors.New(fmt.Sprintf("the archive %s does not contain %s", archive, name))
+	}
+
+	// A tar archive can only be read sequentially, so it is scanned until the member is found.
+	reader, closer, err := openTar(fileSystem, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			closer.Close()
+			return nil, errors.New(fmt.Sprintf("the archive %s does not contain %s", archive, name))
+		}
+		if err != nil {
+			closer.Close()
+			return nil, errors.New(fmt.Sprintf("failed to read the archive %s: %v", archive, err))
+		}
+
+		if cleanMemberName(header.Name) == name {
+			return &memberReader{Reader: reader, closers: []io.Closer{closer}}, nil
+		}
+	}
+}
+
+// Function openZip opens a zip archive, which requires random access to the archive's file.
+// Parameter fileSystem is the file system containing the archive.
+// Parameter path is the path to the archive.
+// Returns the archive's reader and the closer that releases it, or error.
+func openZip(fileSystem fs.FS, path string) (*zip.Reader, io.Closer, error) {
+	file, err := fileSystem.Open(path)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("failed to open the archive %s: %v", path, err))
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, errors.New(fmt.Sprintf("failed to open the archive %s: %v", path, err))
+	}
+
+	// Files that cannot be read at arbitrary offsets are read into memory.
+	readerAt, ok := file.(io.ReaderAt)
+	if !ok {
+		contents, err := io.ReadAll(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, errors.New(fmt.Sprintf("failed to read the archive %s: %v", path, err))
+		}
+		readerAt = bytes.NewReader(contents)
+	}
+
+	reader, err := zip.NewReader(readerAt, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, nil, errors.New(fmt.Sprintf("failed to open the archive %s: %v", path, err))
+	}
+
+	return reader, file, nil
+}
+
+// Function openTar opens a tar archive, decompressing it when it is gzipped.
+// Parameter fileSystem is the file system containing the archive.
+// Parameter path is the path to the archive.
+// Returns the archive's reader and the closer that releases it, or error.
+func openTar(fileSystem fs.FS, path string) (*tar.Reader, io.Closer, error) {
+	file, err := fileSystem.Open(path)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("failed to open the archive %s: %v", path, err))
+	}
+
+	if archiveKindOf(path) != tarGzipArchive {
+		return tar.NewReader(file), file, nil
+	}
+
+	decompressor, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, errors.New(fmt.Sprintf("failed to decompress the archive %s: %v", path, err))
+	}
+
+	return tar.NewReader(decompressor), &memberReader{closers: []io.Closer{decompressor, file}}, nil
+}
+
+// Function cleanMemberName normalizes the name of an archive's member, so it can be matched consistently.
+func cleanMemberName(name string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(name, "./"), "/")
+}
+
+// Type memberReader is a stream that releases every resource beneath it when it is closed.
+type memberReader struct {
+	io.Reader
+
+	// Field closers are closed in order.
+	closers []io.Closer
+}
+
+// Method Close releases the resources beneath the stream.
+// Returns the first error that was encountered, otherwise nil.
+func (r *memberReader) Close() error {
+	var first error
+	for _, closer := range r.closers {
+		err := closer.Close()
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
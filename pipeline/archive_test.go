@@ -0,0 +1,231 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// Variable testArchiveMembers are the regular files that are written into each test archive, in the listed order.
+var testArchiveMembers = []struct {
+	// Field name is the member's name, as it is written into the archive.
+	name string
+
+	// Field path is the member's name within its virtual path.
+	path string
+
+	// Field format is the format that is detected from the member's beginning.
+	format FileFormat
+
+	// Field content is the member's content.
+	content []byte
+}{
+	{"./scans/receipt.gif", "scans/receipt.gif", GIFFormat, []byte("GIF89a receipt")},
+	{"/cover.bmp", "cover.bmp", BMPFormat, []byte("BM cover")},
+	{"notes.txt", "notes.txt", UnknownFormat, []byte("not an image")},
+	{"scans/empty.png", "scans/empty.png", UnknownFormat, nil},
+}
+
+// Function newTestZip creates a zip archive of the test members and a directory.
+func newTestZip(t *testing.T) []byte {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	if _, err := writer.Create("scans/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range testArchiveMembers {
+		stream, err := writer.Create(member.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream.Write(member.content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+// Function newTestTar creates a tar archive of the test members, a directory and a symbolic link,
+// which is gzipped when it is compressed.
+func newTestTar(t *testing.T, compressed bool) []byte {
+	var archive bytes.Buffer
+	var stream io.Writer = &archive
+	var compressor *gzip.Writer
+	if compressed {
+		compressor = gzip.NewWriter(&archive)
+		stream = compressor
+	}
+
+	writer := tar.NewWriter(stream)
+	headers := []*tar.Header{
+		{Name: "scans/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "latest.gif", Typeflag: tar.TypeSymlink, Linkname: "scans/receipt.gif"},
+	}
+	for _, header := range headers {
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, member := range testArchiveMembers {
+		header := &tar.Header{Name: member.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(member.content))}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write(member.content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if compressor != nil {
+		compressor.Close()
+	}
+	return archive.Bytes()
+}
+
+// Function TestSplitArchiveMemberPath verifies that a virtual path is split at the separator that follows an archive.
+func TestSplitArchiveMemberPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		archive string
+		member  string
+		ok      bool
+	}{
+		{"/photos/batch.zip!/dir/img.png", "/photos/batch.zip", "dir/img.png", true},
+		{"/photos/BATCH.TGZ!/img.png", "/photos/BATCH.TGZ", "img.png", true},
+		{"/photos/wow!/batch.tar.gz!/a!/b.jpg", "/photos/wow!/batch.tar.gz", "a!/b.jpg", true},
+		{"/photos/wow!/img.png", "", "", false},
+		{"/photos/batch.zip", "", "", false},
+		{"/photos/batch.zip/img.png", "", "", false},
+	}
+
+	for _, test := range tests {
+		archive, member, ok := SplitArchiveMemberPath(test.path)
+		if archive != test.archive || member != test.member || ok != test.ok {
+			t.Errorf("SplitArchiveMemberPath(%q) = %q, %q, %v", test.path, archive, member, ok)
+		}
+		if IsArchiveMemberPath(test.path) != test.ok {
+			t.Errorf("IsArchiveMemberPath(%q) = %v", test.path, !test.ok)
+		}
+	}
+}
+
+// Function TestListArchive verifies that only the regular members of each kind of archive are listed,
+// with their virtual paths and beginnings, and that each listed member can be opened by its virtual path.
+func TestListArchive(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"in/batch.zip":    {Data: newTestZip(t)},
+		"in/batch.tar":    {Data: newTestTar(t, false)},
+		"in/batch.tar.gz": {Data: newTestTar(t, true)},
+		"in/broken.tgz":   {Data: []byte("not gzipped")},
+	}
+
+	for _, archive := range []string{"in/batch.zip", "in/batch.tar", "in/batch.tar.gz"} {
+		var listed []string
+		formats := make(map[string]FileFormat)
+		err := listArchive(fileSystem, archive, func(memberPath string, info fs.FileInfo, header []byte) {
+			listed = append(listed, memberPath)
+			formats[memberPath] = detectFormat(header)
+			if !info.Mode().IsRegular() {
+				t.Errorf("%s is not a regular file", memberPath)
+			}
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", archive, err)
+		}
+		if len(listed) != len(testArchiveMembers) {
+			t.Errorf("%s lists %v", archive, listed)
+			continue
+		}
+
+		for index, member := range testArchiveMembers {
+			path := archive + kArchiveSeparator + member.path
+			if listed[index] != path || formats[path] != member.format {
+				t.Errorf("%s lists %s as %v, want %s as %v", archive, listed[index], formats[listed[index]], path, member.format)
+			}
+
+			stream, err := OpenPathInFileSystem(fileSystem, path)
+			if err != nil {
+				t.Errorf("%s: %v", path, err)
+				continue
+			}
+			content, err := io.ReadAll(stream)
+			stream.Close()
+			if err != nil || !bytes.Equal(content, member.content) {
+				t.Errorf("%s reads %q, %v", path, content, err)
+			}
+		}
+
+		if _, err = OpenPathInFileSystem(fileSystem, archive+kArchiveSeparator+"missing.png"); err == nil {
+			t.Errorf("%s opens a member that it does not contain", archive)
+		}
+	}
+
+	if err := listArchive(fileSystem, "in/broken.tgz", func(string, fs.FileInfo, []byte) {}); err == nil {
+		t.Error("an archive that is not gzipped was listed")
+	}
+	if err := listArchive(fileSystem, "in/missing.zip", func(string, fs.FileInfo, []byte) {}); err == nil {
+		t.Error("a missing archive was listed")
+	}
+}
+
+// Function TestWalkArchives verifies that a walk passes the members of the archives that it discovers,
+// with their detected formats, instead of the archives.
+func TestWalkArchives(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"2018/trip.zip":    {Data: newTestZip(t)},
+		"2018/IMG_001.JPG": {Data: []byte("\xFF\xD8\xFF\xE0")},
+	}
+
+	for _, archives := range []bool{false, true} {
+		p, err := New([]Source{DirectorySource{Path: "."}}, 4096, 16, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetFileSystem(fileSystem); err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetArchives(archives); err != nil {
+			t.Fatal(err)
+		}
+
+		pathsChannel := make(chan WorkItem, 16)
+		if err = p.walkPathToChannel(".", pathsChannel, nil); err != nil {
+			t.Fatal(err)
+		}
+		close(pathsChannel)
+
+		formats := make(map[string]FileFormat)
+		for item := range pathsChannel {
+			formats[item.Path] = item.Format
+		}
+
+		// The format of a file is detected by the describe step, while a member's is detected as its archive is listed.
+		want := map[string]FileFormat{"2018/IMG_001.JPG": UnknownFormat, "2018/trip.zip": UnknownFormat}
+		if archives {
+			delete(want, "2018/trip.zip")
+			for _, member := range testArchiveMembers {
+				want["2018/trip.zip"+kArchiveSeparator+member.path] = member.format
+			}
+		}
+		if len(formats) != len(want) {
+			t.Errorf("archives %v: the walk passed %v", archives, formats)
+		}
+		for path, format := range want {
+			if found, ok := formats[path]; !ok || found != format {
+				t.Errorf("archives %v: %s was passed as %v, %v, want %v", archives, path, found, ok, format)
+			}
+		}
+	}
+}
@@ -0,0 +1,322 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Type DedupeMode indicates how discovered paths that refer to the same image are recognized.
+type DedupeMode int
+
+// Constants for the values of a DedupeMode.
+const (
+	// NoDedupe processes every discovered path.
+	NoDedupe DedupeMode = iota
+
+	// InodeDedupe processes one path for each device and inode, so hard links are processed once.
+	InodeDedupe
+
+	// ContentDedupe processes one path for each distinct content hash, so copies are also processed once.
+	ContentDedupe
+)
+
+// Function ParseDedupeMode converts the name of a dedupe mode to a DedupeMode.
+// Parameter name is one of "none", "inode" or "content".
+// Returns the DedupeMode or error.
+func ParseDedupeMode(name string) (DedupeMode, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return NoDedupe, nil
+	case "inode":
+		return InodeDedupe, nil
+	case "content":
+		return ContentDedupe, nil
+	default:
+		return NoDedupe, errors.New(fmt.Sprintf("%s%s", "the dedupe mode must be none, inode or content, not ", name))
+	}
+}
+
+// Method String gets the name of the dedupe mode.
+func (m DedupeMode) String() string {
+	switch m {
+	case InodeDedupe:
+		return "inode"
+	case ContentDedupe:
+		return "content"
+	default:
+		return "none"
+	}
+}
+
+// Type CanonicalPolicy indicates which path of a duplicate group is processed.
+type CanonicalPolicy int
+
+// Constants for the values of a CanonicalPolicy.
+const (
+	// FirstCanonical processes the first path to be discovered, so paths stream through without delay.
+	FirstCanonical CanonicalPolicy = iota
+
+	// ShortestCanonical processes the shortest path, breaking ties lexically.
+	// Every path must be discovered before any can be processed.
+	ShortestCanonical
+
+	// LexicalCanonical processes the lexically smallest path.
+	// Every path must be discovered before any can be processed.
+	LexicalCanonical
+)
+
+// Function ParseCanonicalPolicy converts the name of a canonical policy to a CanonicalPolicy.
+// Parameter name is one of "first", "shortest" or "lexical".
+// Returns the CanonicalPolicy or error.
+func ParseCanonicalPolicy(name string) (CanonicalPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "first":
+		return FirstCanonical, nil
+	case "shortest":
+		return ShortestCanonical, nil
+	case "lexical":
+		return LexicalCanonical, nil
+	default:
+		return FirstCanonical, errors.New(fmt.Sprintf("%s%s", "the canonical policy must be first, shortest or lexical, not ", name))
+	}
+}
+
+// Method String gets the name of the canonical policy.
+func (c CanonicalPolicy) String() string {
+	switch c {
+	case ShortestCanonical:
+		return "shortest"
+	case LexicalCanonical:
+		return "lexical"
+	default:
+		return "first"
+	}
+}
+
+// Method prefers determines whether path a should be canonical rather than path b.
+func (c CanonicalPolicy) prefers(a string, b string) bool {
+	if c == ShortestCanonical && len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// Type dedupeRecord is the key that the dedupe step determined for a file, and the state of the file when it did,
+// so the file is examined again when it changes, such as when it is rewritten in place while it is watched.
+type dedupeRecord struct {
+	// Field key is shared by every path that refers to the same image.
+	key string
+
+	// Field size is the file's size in bytes.
+	size int64
+
+	// Field modTime is when the file was last modified.
+	modTime time.Time
+
+	// Field sidecars are the paths to the work item's sidecar files, separated by NUL characters,
+	// which are only recorded for the paths that have been discovered.
+	sidecars string
+}
+
+// Function newDedupeRecord is a factory that creates the record of a work item's primary file.
+// Parameter item is the work item, which has been described.
+// Parameter key is the key that the dedupe step determined for the file.
+// Returns an initialized instance.
+func newDedupeRecord(item WorkItem, key string) dedupeRecord {
+	return dedupeRecord{key: key, size: item.Size, modTime: item.ModTime, sidecars: strings.Join(item.Sidecars, "\x00")}
+}
+
+// Method isCurrent determines whether a work item's primary file is unchanged since the record was made.
+// Parameter item is the work item, which has been described.
+func (r dedupeRecord) isCurrent(item WorkItem) bool {
+	return r.size == item.Size && r.modTime.Equal(item.ModTime)
+}
+
+// Pass one work item for each unique image into the pipeline, and record the duplicate groups in the run report.
+// The images are compared by their primary files, and each canonical image keeps its own sidecar files.
+// Parameter inChannel is the unidirectional channel of discovered work items.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+func (p Pipeline) dedupePaths(inChannel <-chan WorkItem, pathsChannel chan<- WorkItem) {
+	defer close(pathsChannel)
+
+	var (
+		groups      = make(map[string]*DuplicateGroup)
+		canonicals  = make(map[string]WorkItem)
+		order       []*DuplicateGroup
+		identities  = make(map[fileIdentity]dedupeRecord)
+		paths       = make(map[string]dedupeRecord)
+		firstPolicy = p.CanonicalPolicy() == FirstCanonical
+	)
+
+	for item := range inChannel {
+		path := item.Path
+
+		// The step precedes the describe step, so the file's identity is recorded here, without reading the file.
+		err := p.describeItem(&item, false)
+		var key string
+		if err == nil {
+			key, err = p.dedupeKey(item, identities)
+		}
+		if err != nil {
+			// A file that cannot be identified cannot be recognized as a duplicate, so it is processed.
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			item.AddError(kDedupeStep, err)
+			pathsChannel <- item
+			continue
+		}
+		item.SetAttribute(kDedupeStep, "key", key)
+
+		// The same path can be discovered more than once, such as from overlapping roots or a manifest and a walk,
+		// so it is only processed or reported once, unless its file or its sidecar files have changed, such as when
+		// the file is rewritten in place or a sidecar file arrives while the path is watched.
+		record := newDedupeRecord(item, key)
+		previous, discovered := paths[path]
+		if discovered && previous.key == key && previous.sidecars == record.sidecars && previous.isCurrent(item) {
+			continue
+		}
+		paths[path] = record
+
+		// A path whose file has changed no longer has its previous content, so it is no longer its previous duplicate.
+		if discovered {
+			if group := groups[previous.key]; group != nil {
+				group.Duplicates = removePath(group.Duplicates, path)
+			}
+		}
+
+		group, seen := groups[key]
+		if !seen {
+			group = &DuplicateGroup{Key: key, Canonical: path}
+			groups[key] = group
+			order = append(order, group)
+
+			// The first path is canonical, so it does not need to wait for the rest of the discovery.
+			if firstPolicy {
+				pathsChannel <- item
+			} else {
+				canonicals[key] = item
+			}
+			continue
+		}
+
+		// The canonical path is processed again when it changes.
+		if group.Canonical == path {
+			if firstPolicy {
+				pathsChannel <- item
+			} else {
+				canonicals[key] = item
+			}
+			continue
+		}
+
+		if !firstPolicy && p.CanonicalPolicy().prefers(path, group.Canonical) {
+			group.Duplicates = append(group.Duplicates, group.Canonical)
+			group.Canonical = path
+			canonicals[key] = item
+			continue
+		}
+		group.Duplicates = append(group.Duplicates, path)
+	}
+
+	for _, group := range order {
+		if !firstPolicy {
+			pathsChannel <- canonicals[group.Key]
+		}
+
+		if len(group.Duplicates) > 0 {
+			p.Report().addDuplicateGroup(*group)
+		}
+	}
+}
+
+// Function removePath removes a path from a list of paths, preserving the order of the others.
+// Parameter paths is the list, which is modified.
+// Parameter path is the path to remove.
+// Returns the list without the path.
+func removePath(paths []string, path string) []string {
+	for i, candidate := range paths {
+		if candidate == path {
+			return append(paths[:i], paths[i+1:]...)
+		}
+	}
+	return paths
+}
+
+// Method dedupeKey determines the key that is shared by every path that refers to the same image.
+// Parameter item is the work item for the file, whose identity has been recorded.
+// Parameter identities maps the identity of each file that has been hashed to its key and its state when it was hashed,
+// so hard links are not hashed again, while a file that has changed since it was hashed is hashed again.
+// Returns the key or error.
+func (p Pipeline) dedupeKey(item WorkItem, identities map[fileIdentity]dedupeRecord) (string, error) {
+	path := item.Path
+
+	// A member within an archive or a remote file has no inode of its own, so it can only be identified by its content.
+	if IsArchiveMemberPath(path) || IsRemotePath(path) {
+		if p.DedupeMode() == ContentDedupe {
+			return p.hashFile(path)
+		}
+		return "path " + path, nil
+	}
+
+	identity, ok := fileIdentity{device: item.Device, inode: item.Inode}, item.HasIdentity()
+	if record, found := identities[identity]; ok && found && record.isCurrent(item) {
+		return record.key, nil
+	}
+
+	var (
+		key string
+		err error
+	)
+	switch {
+
+	case p.DedupeMode() == ContentDedupe:
+		key, err = p.hashFile(path)
+		if err != nil {
+			return "", err
+		}
+
+	case ok:
+		key = fmt.Sprintf("inode %d:%d", identity.device, identity.inode)
+
+	default:
+		// Without inode numbers, each path can only be identified by itself.
+		key = "path " + path
+	}
+
+	// The record of a file that has changed replaces its previous record, so the cache holds one record for each file.
+	if ok {
+		identities[identity] = dedupeRecord{key: key, size: item.Size, modTime: item.ModTime}
+	}
+
+	return key, nil
+}
+
+// Method hashFile computes the SHA-256 hash of a file's contents.
+// Parameter path is the path to the file, or the virtual path to a member within an archive.
+// Returns the key for the hash or error.
+func (p Pipeline) hashFile(path string) (string, error) {
+	file, err := p.OpenPath(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("failed to hash %s: %v", path, err))
+	}
+
+	return "sha256 " + hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,180 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Function TestDedupePathsCanonicalPolicies verifies which copy of an image each canonical policy processes,
+// and that the other copies, including a path that is discovered twice, are reported once.
+func TestDedupePathsCanonicalPolicies(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"b/copy.jpg":     "same",
+		"a/original.jpg": "same",
+		"c.jpg":          "same",
+		"unique.jpg":     "different",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string {
+		return filepath.Join(root, filepath.FromSlash(name))
+	}
+	discovered := []string{path("b/copy.jpg"), path("a/original.jpg"), path("unique.jpg"), path("c.jpg"), path("b/copy.jpg")}
+
+	tests := []struct {
+		policy     CanonicalPolicy
+		processed  []string
+		duplicates []string
+	}{
+		{FirstCanonical, []string{path("b/copy.jpg"), path("unique.jpg")}, []string{path("a/original.jpg"), path("c.jpg")}},
+		{ShortestCanonical, []string{path("c.jpg"), path("unique.jpg")}, []string{path("a/original.jpg"), path("b/copy.jpg")}},
+		{LexicalCanonical, []string{path("a/original.jpg"), path("unique.jpg")}, []string{path("b/copy.jpg"), path("c.jpg")}},
+	}
+
+	for _, test := range tests {
+		p, err := New([]Source{DirectorySource{Path: root}}, 4096, 8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetDedupeMode(ContentDedupe); err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetCanonicalPolicy(test.policy); err != nil {
+			t.Fatal(err)
+		}
+
+		inChannel, outChannel := make(chan WorkItem, len(discovered)), make(chan WorkItem, len(discovered))
+		for _, discoveredPath := range discovered {
+			inChannel <- WorkItem{Path: discoveredPath}
+		}
+		close(inChannel)
+		p.dedupePaths(inChannel, outChannel)
+
+		var processed []string
+		for item := range outChannel {
+			processed = append(processed, item.Path)
+		}
+		if !reflect.DeepEqual(processed, test.processed) {
+			t.Errorf("%v processed %v, want %v", test.policy, processed, test.processed)
+		}
+
+		groups := p.Report().DuplicateGroups()
+		if len(groups) != 1 {
+			t.Errorf("%v reported %d duplicate groups, want 1", test.policy, len(groups))
+			continue
+		}
+		if !reflect.DeepEqual(groups[0].Duplicates, test.duplicates) {
+			t.Errorf("%v reported the duplicates %v, want %v", test.policy, groups[0].Duplicates, test.duplicates)
+		}
+	}
+}
+
+// Function TestCanonicalPolicyPrefers verifies the order of the paths for each canonical policy.
+func TestCanonicalPolicyPrefers(t *testing.T) {
+	tests := []struct {
+		policy  CanonicalPolicy
+		a       string
+		b       string
+		prefers bool
+	}{
+		{ShortestCanonical, "/z.jpg", "/a/b.jpg", true},
+		{ShortestCanonical, "/a/b.jpg", "/z.jpg", false},
+		{ShortestCanonical, "/a.jpg", "/b.jpg", true},
+		{LexicalCanonical, "/z.jpg", "/a/b.jpg", false},
+		{LexicalCanonical, "/a/b.jpg", "/z.jpg", true},
+	}
+
+	for _, test := range tests {
+		if prefers := test.policy.prefers(test.a, test.b); prefers != test.prefers {
+			t.Errorf("%v.prefers(%q, %q) = %v, want %v", test.policy, test.a, test.b, prefers, test.prefers)
+		}
+	}
+}
+
+// Function TestDedupePathsRewrittenInPlace verifies that a path that is discovered again is dropped while its file is
+// unchanged, and is processed again when its file is rewritten in place, keeping its inode, or when a sidecar file arrives.
+func TestDedupePathsRewrittenInPlace(t *testing.T) {
+	root := t.TempDir()
+	original, link := filepath.Join(root, "a.jpg"), filepath.Join(root, "b.jpg")
+	if err := os.WriteFile(original, []byte("before"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(original, link); err != nil {
+		t.Skip("hard links are not supported: ", err)
+	}
+
+	for _, mode := range []DedupeMode{InodeDedupe, ContentDedupe} {
+		if err := os.WriteFile(original, []byte("before"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := New([]Source{DirectorySource{Path: root}}, 4096, 8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetDedupeMode(mode); err != nil {
+			t.Fatal(err)
+		}
+
+		// The items are sent one at a time, and the channel is unbuffered, so each item has been examined
+		// before the file is rewritten.
+		inChannel, outChannel := make(chan WorkItem), make(chan WorkItem, 8)
+		go p.dedupePaths(inChannel, outChannel)
+		discover := func(path string, sidecars ...string) {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			item := WorkItem{Path: path, Sidecars: sidecars}
+			item.describe(info)
+			inChannel <- item
+		}
+
+		discover(original)
+		discover(link)
+		discover(original)
+
+		// The file is rewritten in place, so it keeps its inode, with a modification time that is certain to differ.
+		if err = os.WriteFile(original, []byte("after!"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		later := time.Now().Add(time.Hour)
+		if err = os.Chtimes(original, later, later); err != nil {
+			t.Fatal(err)
+		}
+		discover(original)
+		discover(original)
+		discover(original, filepath.Join(root, "a.xmp"))
+		close(inChannel)
+
+		var processed []string
+		for item := range outChannel {
+			processed = append(processed, filepath.Base(item.Path))
+		}
+		if want := []string{"a.jpg", "a.jpg", "a.jpg"}; !reflect.DeepEqual(processed, want) {
+			t.Errorf("%v processed %v, want %v", mode, processed, want)
+		}
+
+		groups := p.Report().DuplicateGroups()
+		if len(groups) != 1 || !reflect.DeepEqual(groups[0].Duplicates, []string{link}) {
+			t.Errorf("%v reported the duplicate groups %+v, want one with %s", mode, groups, link)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"bytes"
+	"io"
+)
+
+// The number of bytes at the beginning of a file that are read to detect its format.
+const kFormatHeaderSize = 16
+
+// The names of the steps, which record their results and errors in the work items.
+const (
+	kDescribeStep = "describe"
+	kDedupeStep   = "dedupe"
+	kStateStep    = "state"
+)
+
+// Type FileFormat indicates the format of a file, as detected from its contents.
+type FileFormat int
+
+// Constants for the values of a FileFormat.
+const (
+	UnknownFormat FileFormat = iota
+	JPEGFormat
+	PNGFormat
+	GIFFormat
+	TIFFFormat
+	WebPFormat
+	BMPFormat
+	HEICFormat
+)
+
+// Method String gets the name of the format.
+func (f FileFormat) String() string {
+	switch f {
+	case JPEGFormat:
+		return "jpeg"
+	case PNGFormat:
+		return "png"
+	case GIFFormat:
+		return "gif"
+	case TIFFFormat:
+		return "tiff"
+	case WebPFormat:
+		return "webp"
+	case BMPFormat:
+		return "bmp"
+	case HEICFormat:
+		return "heic"
+	default:
+		return "unknown"
+	}
+}
+
+// Function detectFormat determines the format of a file from the signature at its beginning.
+// Parameter header is the beginning of the file.
+func detectFormat(header []byte) FileFormat {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEGFormat
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return PNGFormat
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return GIFFormat
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return TIFFFormat
+	case len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")):
+		return WebPFormat
+	case bytes.HasPrefix(header, []byte("BM")):
+		return BMPFormat
+	case len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")):
+		switch string(header[8:12]) {
+		case "heic", "heix", "hevc", "heim", "heis", "mif1", "msf1":
+			return HEICFormat
+		}
+	}
+	return UnknownFormat
+}
+
+// Describe each work item that reaches the step once, numbering it in the order that it arrives and recording its
+// primary file's size, modification time, identity and format, so the steps after it do not need to examine the file
+// again. The step follows the shard and dedupe steps, so only the files that this process keeps are examined.
+// A work item whose file could not be described records the error, and is still passed to the steps after it.
+// Parameter inChannel is the unidirectional channel of discovered work items.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+func (p Pipeline) describePaths(inChannel <-chan WorkItem, pathsChannel chan<- WorkItem) {
+	defer close(pathsChannel)
+
+	sequence := uint64(0)
+	for item := range inChannel {
+		sequence++
+		item.Sequence = sequence
+
+		err := p.describeItem(&item, true)
+		if err != nil {
+			item.AddError(kDescribeStep, err)
+		}
+
+		pathsChannel <- item
+	}
+}
+
+// Method describeItem records the size, modification time and identity of a work item's primary file,
+// unless they were recorded when it was discovered, such as from its directory entry.
+// Parameter item is the work item.
+// Parameter detect indicates whether the file's format is also detected, which requires reading its beginning.
+// Returns nil if there are no errors.
+func (p Pipeline) describeItem(item *WorkItem, detect bool) error {
+
+	// A remote file cannot be examined without reading it, and a member within an archive was described as it was listed.
+	if IsRemotePath(item.Path) || IsArchiveMemberPath(item.Path) {
+		return nil
+	}
+
+	if item.ModTime.IsZero() {
+		info, err := p.statPath(item.Path)
+		if err != nil {
+			return err
+		}
+		item.describe(info)
+	}
+
+	if !detect || item.Format != UnknownFormat {
+		return nil
+	}
+
+	file, err := p.OpenPath(item.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header, err := readFormatHeader(file)
+	if err != nil {
+		return err
+	}
+	item.Format = detectFormat(header)
+
+	return nil
+}
+
+// Function readFormatHeader reads the beginning of a file, which detectFormat examines.
+// Parameter reader is the stream containing the file.
+// Returns at most kFormatHeaderSize bytes, which are fewer when the file is shorter, or error.
+func readFormatHeader(reader io.Reader) ([]byte, error) {
+	header := make([]byte, kFormatHeaderSize)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return header[:n], nil
+}
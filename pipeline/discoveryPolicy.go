@@ -0,0 +1,62 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// Variable errSkipDirectory is returned from the walk's callback to skip descending into a directory.
+var errSkipDirectory = errors.New("skip this directory")
+
+// Variable errNotRegular is the error for a path that is not a regular file, so it is never opened.
+var errNotRegular = errors.New("not a regular file")
+
+// Function isHiddenName determines whether a file or directory is hidden, because its name begins with a dot.
+func isHiddenName(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+// Method excludes determines whether discovery skips a file, or skips descending into a directory,
+// because it is hidden or, for a directory, because it is on another file system.
+// The paths given explicitly as roots or in a manifest are never excluded.
+// Parameter path is the path to the file or directory.
+// Parameter isDirectory indicates whether the path is a directory.
+func (p Pipeline) excludes(path string, isDirectory bool) bool {
+	name := filepath.Base(path)
+
+	if isDirectory {
+		if p.SkipHiddenDirectories() && isHiddenName(name) {
+			return true
+		}
+		return p.OneFileSystem() && p.isMountPoint(path)
+	}
+
+	return p.SkipHiddenFiles() && isHiddenName(name)
+}
+
+// Method isMountPoint determines whether a directory is on a different device than its parent,
+// which is where another file system, such as a network mount, has been mounted.
+// It is always false when the source file system does not provide device numbers.
+// Parameter path is the path to the directory.
+func (p Pipeline) isMountPoint(path string) bool {
+	info, err := p.statPath(path)
+	if err != nil {
+		return false
+	}
+	parentInfo, err := p.statPath(p.parentPath(path))
+	if err != nil {
+		return false
+	}
+
+	identity, ok := identifyFile(info)
+	parentIdentity, parentOk := identifyFile(parentInfo)
+
+	return ok && parentOk && identity.device != parentIdentity.device
+}
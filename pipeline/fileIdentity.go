@@ -0,0 +1,39 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"os"
+	"syscall"
+)
+
+// The flag that opens a file without blocking, so a FIFO that replaced a regular file cannot hang the pipeline.
+const kOpenNonBlocking = syscall.O_NONBLOCK
+
+// Type fileIdentity uniquely identifies a file within a host, so hard links to the same file can be recognized.
+type fileIdentity struct {
+	// Field device is the identifier of the device containing the file.
+	device uint64
+
+	// Field inode is the file's inode number on its device.
+	inode uint64
+}
+
+// Function identifyFile gets the identity of a file from its information.
+// Parameter info is the result of os.Stat or os.Lstat.
+// Returns the identity, and false if the platform does not provide one.
+func identifyFile(info os.FileInfo) (fileIdentity, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileIdentity{}, false
+	}
+
+	return fileIdentity{device: uint64(stat.Dev), inode: uint64(stat.Ino)}, true
+}
@@ -0,0 +1,32 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+//go:build windows || plan9
+// +build windows plan9
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"os"
+)
+
+// The flag that opens a file without blocking, which is not needed on this platform.
+const kOpenNonBlocking = 0
+
+// Type fileIdentity uniquely identifies a file within a host, so hard links to the same file can be recognized.
+type fileIdentity struct {
+	// Field device is the identifier of the device containing the file.
+	device uint64
+
+	// Field inode is the file's inode number on its device.
+	inode uint64
+}
+
+// Function identifyFile cannot identify files, because the platform does not provide inode numbers.
+// Returns false.
+func identifyFile(info os.FileInfo) (fileIdentity, bool) {
+	return fileIdentity{}, false
+}
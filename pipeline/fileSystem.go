@@ -0,0 +1,127 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	slashpath "path"
+	"path/filepath"
+)
+
+// Type osFileSystem is an fs.FS that opens operating system paths as they are, including absolute paths.
+// It lets discovery and the later steps treat the operating system like any other source file system.
+type osFileSystem struct{}
+
+// Method Open opens the named operating system file for reading.
+// Only regular files are opened, because opening a socket, FIFO or device can block or have side effects.
+func (osFileSystem) Open(name string) (fs.File, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errNotRegular}
+	}
+
+	// Opening without blocking, then checking again, guards against the file being replaced after it was checked.
+	file, err := os.OpenFile(name, os.O_RDONLY|kOpenNonBlocking, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err = file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errNotRegular}
+	}
+
+	return file, nil
+}
+
+// Method Stat gets the information about the named operating system file, following symbolic links.
+func (osFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// Method ReadDir reads the named operating system directory, sorted by file name.
+func (osFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// Type directoryEntry is a node in a walked hierarchy, read from either godirwalk or an fs.FS.
+type directoryEntry interface {
+	// Function Name gets the name of the node, without any path information.
+	Name() string
+
+	// Function IsDir determines whether the node is a directory.
+	IsDir() bool
+
+	// Function IsRegular determines whether the node is a regular file, rather than a symbolic link or special file.
+	IsRegular() bool
+}
+
+// Type fsDirectoryEntry adapts an fs.DirEntry to a directoryEntry.
+type fsDirectoryEntry struct {
+	fs.DirEntry
+}
+
+// Method IsRegular determines whether the node is a regular file, rather than a symbolic link or special file.
+func (e fsDirectoryEntry) IsRegular() bool {
+	return e.Type().IsRegular()
+}
+
+// Method FileSystem gets the source file system that is walked and read, or nil for the operating system's.
+func (p Pipeline) FileSystem() fs.FS {
+	return p.fileSystem
+}
+
+// Method SetFileSystem sets the source file system that is walked and read, or nil for the operating system's.
+// When it is set, the paths are slash-separated paths within it, such as ".", and the operating system
+// is never accessed for the files to process, so fstest.MapFS, embed.FS or a *zip.Reader can be used.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetFileSystem(fileSystem fs.FS) error {
+	p.fileSystem = fileSystem
+	return nil
+}
+
+// Method sourceFileSystem gets the file system containing the files to process.
+func (p Pipeline) sourceFileSystem() fs.FS {
+	if p.FileSystem() == nil {
+		return osFileSystem{}
+	}
+	return p.FileSystem()
+}
+
+// Method joinPath joins a directory and a name using the source file system's separator.
+func (p Pipeline) joinPath(directory string, name string) string {
+	if p.FileSystem() == nil {
+		return filepath.Join(directory, name)
+	}
+	return slashpath.Join(directory, name)
+}
+
+// Method parentPath gets the directory containing a path, using the source file system's separator.
+func (p Pipeline) parentPath(path string) string {
+	if p.FileSystem() == nil {
+		return filepath.Dir(path)
+	}
+	return slashpath.Dir(path)
+}
+
+// Method statPath gets the information about a file in the source file system, following symbolic links.
+func (p Pipeline) statPath(path string) (fs.FileInfo, error) {
+	return fs.Stat(p.sourceFileSystem(), path)
+}
+
+// Method OpenPath opens a file from the source file system for reading as a stream,
+// where the path may refer to a member within an archive.
+// Parameter path is the path to a file, or a virtual path such as "batch.zip!/dir/img.png".
+// Returns the stream, which the caller must close, or error.
+func (p Pipeline) OpenPath(path string) (io.ReadCloser, error) {
+	return OpenPathInFileSystem(p.sourceFileSystem(), path)
+}
@@ -0,0 +1,239 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"github.com/abitofhelp/pipeline/geodesy"
+	"github.com/abitofhelp/pipeline/image"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The name of the geofence step, which records its results and errors in the work items.
+const kGeofenceStep = "geofence"
+
+// Type Geofence selects the work items by where their images were taken, such as only the images taken in a city.
+type Geofence struct {
+	// Field Region is the area on the Earth, or nil when the work items are not geofenced.
+	Region geodesy.Region
+
+	// Field Exclude indicates whether the images taken within the region are dropped, rather than the images outside of it.
+	Exclude bool
+
+	// Field KeepUnlocated indicates whether the images without a location are kept, rather than dropped.
+	KeepUnlocated bool
+}
+
+// Function ParseGeofence converts a region in one of these forms to a Geofence that keeps the images within it:
+// "bbox:south,west,north,east", "circle:latitude,longitude,meters", or "polygon:lat,lng;lat,lng;lat,lng...".
+// Parameter text is the region, or empty when the work items are not geofenced.
+// Returns the Geofence or error.
+func ParseGeofence(text string) (Geofence, error) {
+	if text == "" {
+		return Geofence{}, nil
+	}
+
+	var (
+		region geodesy.Region
+		err    error
+	)
+
+	kind, values, _ := strings.Cut(text, ":")
+	switch strings.ToLower(kind) {
+	case "bbox":
+		var numbers []float64
+		numbers, err = parseNumbers(values, ",", 4)
+		if err == nil {
+			region, err = geodesy.NewBoundingBox(numbers[0], numbers[1], numbers[2], numbers[3])
+		}
+	case "circle":
+		var numbers []float64
+		numbers, err = parseNumbers(values, ",", 3)
+		if err == nil {
+			region, err = geodesy.NewCircle(latlng.LatLng{Latitude: numbers[0], Longitude: numbers[1]}, numbers[2])
+		}
+	case "polygon":
+		var vertices []latlng.LatLng
+		for _, vertex := range strings.Split(values, ";") {
+			var numbers []float64
+			numbers, err = parseNumbers(vertex, ",", 2)
+			if err != nil {
+				break
+			}
+			vertices = append(vertices, latlng.LatLng{Latitude: numbers[0], Longitude: numbers[1]})
+		}
+		if err == nil {
+			region, err = geodesy.NewPolygon(vertices)
+		}
+	default:
+		err = errors.New("the region must be a bbox, circle or polygon")
+	}
+
+	if err != nil {
+		return Geofence{}, errors.New(fmt.Sprintf("%s%s: %v", "the geofence is not valid: ", text, err))
+	}
+
+	return Geofence{Region: region}, nil
+}
+
+// Function parseNumbers converts a list of decimal numbers to their values.
+// Parameter text is the list.
+// Parameter separator separates the numbers in the list.
+// Parameter count is the number of numbers that the list must contain.
+// Returns the values or error.
+func parseNumbers(text string, separator string, count int) ([]float64, error) {
+	fields := strings.Split(text, separator)
+	if len(fields) != count {
+		return nil, errors.New(fmt.Sprintf("expected %d numbers separated by %q", count, separator))
+	}
+
+	numbers := make([]float64, count)
+	for i, field := range fields {
+		number, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers[i] = number
+	}
+	return numbers, nil
+}
+
+// Method IsGeofenced determines whether the work items are selected by where their images were taken.
+func (g Geofence) IsGeofenced() bool {
+	return g.Region != nil
+}
+
+// Method keeps determines whether the geofence keeps an image.
+// Parameter location is where the image was taken, which is valid when hasLocation is true.
+// Parameter hasLocation indicates whether the image's location is known.
+func (g Geofence) keeps(location latlng.LatLng, hasLocation bool) bool {
+	if !hasLocation {
+		return g.KeepUnlocated
+	}
+	return g.Region.Contains(location) != g.Exclude
+}
+
+// Pass only the work items whose images the pipeline's geofence keeps. The location is read from the image's
+// XMP packet, then from its EXIF metadata, and then from its XMP sidecar files, so each work item's files are read.
+// A location that cannot be read records the error, and the next source of the location is tried.
+// Parameter inChannel is the unidirectional channel of discovered work items.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+func (p Pipeline) geofencePaths(inChannel <-chan WorkItem, pathsChannel chan<- WorkItem) {
+	defer close(pathsChannel)
+
+	geofence := p.Geofence()
+	for item := range inChannel {
+		location, hasLocation := p.itemLocation(&item)
+		if hasLocation {
+			item.SetAttribute(kGeofenceStep, "latlng", location)
+			item.SetAttribute(kGeofenceStep, "inside", geofence.Region.Contains(location))
+		}
+
+		if geofence.keeps(location, hasLocation) {
+			pathsChannel <- item
+		} else {
+			p.Report().addGeofenced()
+		}
+	}
+}
+
+// Method itemLocation reads where a work item's image was taken. The sources are tried in the precedence of image.Load,
+// where the XMP packet overrides the EXIF metadata, so an image is geofenced on the location that it is reported with.
+// Parameter item is the work item, which records the errors that are encountered.
+// Returns the location, and whether it is known.
+func (p Pipeline) itemLocation(item *WorkItem) (latlng.LatLng, bool) {
+	type source struct {
+		path string
+		read func(io.Reader) (latlng.LatLng, bool, error)
+	}
+
+	var sources []source
+	if item.Format == JPEGFormat || item.Format == PNGFormat {
+		sources = append(sources, source{item.Path, xmpLocation}, source{item.Path, exifLocation})
+	}
+	for _, sidecar := range item.Sidecars {
+		if strings.EqualFold(filepath.Ext(sidecar), ".xmp") {
+			sources = append(sources, source{sidecar, sidecarLocation})
+		}
+	}
+
+	for _, source := range sources {
+		location, ok, err := p.readLocation(source.path, source.read)
+		if err != nil {
+			item.AddError(kGeofenceStep, err)
+			continue
+		}
+		if ok {
+			return location, true
+		}
+	}
+
+	return latlng.LatLng{}, false
+}
+
+// Method readLocation opens a file and reads where its image was taken.
+// Parameter path is the path to the file.
+// Parameter read reads the location from the file's stream.
+// Returns the location, whether it is known, or error.
+func (p Pipeline) readLocation(path string, read func(io.Reader) (latlng.LatLng, bool, error)) (latlng.LatLng, bool, error) {
+	file, err := p.OpenPath(path)
+	if err != nil {
+		return latlng.LatLng{}, false, err
+	}
+	defer file.Close()
+
+	return read(file)
+}
+
+// Function exifLocation reads where an image was taken from its EXIF metadata.
+// Parameter reader is the stream containing the image.
+// Returns the location, whether it is known, or error.
+func exifLocation(reader io.Reader) (latlng.LatLng, bool, error) {
+	exif, err := image.ReadExif(reader)
+	if err == image.ErrNoExif {
+		return latlng.LatLng{}, false, nil
+	}
+	if err != nil {
+		return latlng.LatLng{}, false, err
+	}
+	return latlng.LatLng{Latitude: exif.Latitude, Longitude: exif.Longitude}, exif.HasLocation, nil
+}
+
+// Function xmpLocation reads where an image was taken from its XMP packet.
+// Parameter reader is the stream containing the image.
+// Returns the location, whether it is known, or error.
+func xmpLocation(reader io.Reader) (latlng.LatLng, bool, error) {
+	xmp, err := image.ReadXmp(reader)
+	if err == image.ErrNoXmp {
+		return latlng.LatLng{}, false, nil
+	}
+	if err != nil {
+		return latlng.LatLng{}, false, err
+	}
+	return latlng.LatLng{Latitude: xmp.Latitude, Longitude: xmp.Longitude}, xmp.HasLocation, nil
+}
+
+// Function sidecarLocation reads where an image was taken from its XMP sidecar file.
+// Parameter reader is the stream containing the sidecar file.
+// Returns the location, whether it is known, or error.
+func sidecarLocation(reader io.Reader) (latlng.LatLng, bool, error) {
+	packet, err := io.ReadAll(io.LimitReader(reader, image.MaxMetadataSize))
+	if err != nil {
+		return latlng.LatLng{}, false, err
+	}
+
+	xmp, err := image.ParseXmp(packet)
+	if err != nil {
+		return latlng.LatLng{}, false, err
+	}
+	return latlng.LatLng{Latitude: xmp.Latitude, Longitude: xmp.Longitude}, xmp.HasLocation, nil
+}
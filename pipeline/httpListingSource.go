@@ -0,0 +1,133 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// The maximum time to wait for a web server to begin responding.
+	kRemoteResponseTimeout = 60 * time.Second
+
+	// The maximum size of an HTTP listing, which protects against a listing that never ends.
+	kMaxListingSize = 64 * 1024 * 1024
+)
+
+// Variable remoteClient is the HTTP client that reads listings and remote files.
+var remoteClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: kRemoteResponseTimeout,
+	},
+}
+
+// Variable hrefPattern matches the target of each link in an HTML page.
+var hrefPattern = regexp.MustCompile(`(?i)href\s*=\s*["']([^"'#]+)["']`)
+
+// Type HTTPListingSource is a Source that reads a listing of remote files from a web server,
+// passing the URL of each file into the pipeline, where it can be opened with OpenPath.
+// The listing is either an HTML page, such as a web server's directory index, whose links are the files,
+// or a text file with one URL per line. Relative URLs are resolved against the listing's URL,
+// and links to directories, parent directories and sorting queries are ignored.
+type HTTPListingSource struct {
+	// Field URL is the URL of the listing.
+	URL string
+}
+
+// Method Load reads the listing, passing the work items for its files into the pipeline.
+func (s HTTPListingSource) Load(pipeline Pipeline, itemsChannel chan<- WorkItem) error {
+	base, err := url.Parse(s.URL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") {
+		return errors.New("the listing must be an http or https URL, not " + s.URL)
+	}
+
+	response, err := remoteClient.Get(s.URL)
+	if err != nil {
+		return pipeline.walkError(s.URL, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return pipeline.walkError(s.URL, errors.New(fmt.Sprintf("%s%s", "the web server responded ", response.Status)))
+	}
+
+	// One more byte than the limit is read, so a listing that exceeds it is reported rather than silently truncated.
+	contents, err := io.ReadAll(io.LimitReader(response.Body, kMaxListingSize+1))
+	if err != nil {
+		return pipeline.walkError(s.URL, err)
+	}
+	if len(contents) > kMaxListingSize {
+		return pipeline.walkError(s.URL, errors.New(fmt.Sprintf("%s%d bytes", "the listing exceeds ", kMaxListingSize)))
+	}
+
+	var references []string
+	if strings.Contains(response.Header.Get("Content-Type"), "html") {
+		for _, match := range hrefPattern.FindAllSubmatch(contents, -1) {
+			references = append(references, string(match[1]))
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(contents))
+		for scanner.Scan() {
+			references = append(references, strings.TrimSpace(scanner.Text()))
+		}
+		if err := scanner.Err(); err != nil {
+			return pipeline.walkError(s.URL, errors.New(fmt.Sprintf("%s%v", "failed to read the listing: ", err)))
+		}
+	}
+
+	for _, reference := range references {
+		if pipeline.isStopping() {
+			return nil
+		}
+
+		if reference == "" || strings.HasPrefix(reference, "?") || strings.HasSuffix(reference, "/") {
+			continue
+		}
+
+		target, err := base.Parse(reference)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+			continue
+		}
+
+		itemsChannel <- WorkItem{Path: target.String()}
+	}
+
+	return nil
+}
+
+// Function IsRemotePath determines whether a path is the URL of a remote file.
+// Parameter path is the path to check.
+func IsRemotePath(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// Function openRemote opens a remote file for reading as a stream.
+// Parameter path is the URL of the file.
+// Returns the stream, which the caller must close, or error.
+func openRemote(path string) (io.ReadCloser, error) {
+	response, err := remoteClient.Get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, errors.New(fmt.Sprintf("failed to open %s: the web server responded %s", path, response.Status))
+	}
+
+	return response.Body, nil
+}
@@ -0,0 +1,193 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/abitofhelp/pipeline/image"
+	"io"
+)
+
+const (
+	// The maximum size of a JPEG segment's data, which its two byte length also counts.
+	kMaxJPEGSegmentSize = 65533
+)
+
+// Type jpegSegment is a marker segment of a JPEG image, as it was read.
+type jpegSegment struct {
+	// Field marker is the segment's marker, such as 0xE1 for APP1.
+	marker byte
+
+	// Field raw are the segment's bytes: any fill bytes, the marker, its length and its data.
+	raw []byte
+
+	// Field data is the segment's data, which follows its length.
+	data []byte
+}
+
+// Function injectJPEGMetadata copies a JPEG image, replacing or inserting its APP1 EXIF segment with one that records
+// when and where the image was taken, and its APP1 XMP segment with one that records the image's XMP metadata.
+// The other values of the EXIF data and the other properties of the XMP packet are preserved, and every other segment
+// and the entropy-coded image data are copied unchanged, so the image is not re-compressed. A new EXIF segment is
+// inserted after any APP0 segments, as JFIF requires, and a new XMP segment after the EXIF segment.
+// Parameter reader is the stream containing the JPEG image.
+// Parameter writer is the stream that receives the JPEG image with its metadata.
+// Parameter img is the image whose metadata is injected, when it is known.
+// Returns nil if there are no errors.
+func injectJPEGMetadata(reader *bufio.Reader, writer io.Writer, img *image.Image) error {
+	start := make([]byte, 2)
+	_, err := io.ReadFull(reader, start)
+	if err != nil || start[0] != 0xFF || start[1] != 0xD8 {
+		return errors.New("the stream does not contain a JPEG image")
+	}
+
+	// The metadata segments precede the scan, so only they are held in memory.
+	segments, err := readJPEGSegments(reader)
+	if err != nil {
+		return err
+	}
+
+	if img != nil {
+		insert := 0
+		for insert < len(segments) && segments[insert].marker == 0xE0 {
+			insert++
+		}
+
+		segments, err = updateAPP1Segment(segments, image.ExifIdentifier, insert, img.EncodeExif)
+		if err != nil {
+			return err
+		}
+
+		if exif := findAPP1Segment(segments, image.ExifIdentifier); exif >= 0 {
+			insert = exif + 1
+		}
+
+		segments, err = updateAPP1Segment(segments, image.XmpIdentifier, insert, img.EncodeXmp)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = writer.Write(start)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		_, err = writer.Write(segment.raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Copy the scan and everything after it unchanged.
+	_, err = io.Copy(writer, reader)
+	return err
+}
+
+// Function findAPP1Segment gets the position of the first APP1 segment that begins with an identifier, or -1 when there is none.
+func findAPP1Segment(segments []jpegSegment, identifier string) int {
+	for index, segment := range segments {
+		if segment.marker == 0xE1 && bytes.HasPrefix(segment.data, []byte(identifier)) {
+			return index
+		}
+	}
+	return -1
+}
+
+// Function updateAPP1Segment replaces or inserts the APP1 segment that begins with an identifier.
+// Parameter segments are the image's segments.
+// Parameter identifier is the identifier at the beginning of the segment's data.
+// Parameter insert is the position where the segment is inserted, when the image does not have one.
+// Parameter encode updates the segment's data after the identifier, which is nil when the image does not have one,
+// and returns it unchanged when there is nothing to update.
+// Returns the image's segments or error.
+func updateAPP1Segment(segments []jpegSegment, identifier string, insert int, encode func([]byte) ([]byte, error)) ([]jpegSegment, error) {
+	var existing []byte
+	position := findAPP1Segment(segments, identifier)
+	if position >= 0 {
+		existing = segments[position].data[len(identifier):]
+	}
+
+	data, err := encode(existing)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil || bytes.Equal(data, existing) {
+		return segments, nil
+	}
+
+	if len(identifier)+len(data) > kMaxJPEGSegmentSize {
+		return nil, errors.New("the metadata is too large for a JPEG APP1 segment")
+	}
+
+	segment := jpegSegment{marker: 0xE1, raw: []byte{0xFF, 0xE1, 0, 0}}
+	binary.BigEndian.PutUint16(segment.raw[2:], uint16(2+len(identifier)+len(data)))
+	segment.raw = append(append(segment.raw, identifier...), data...)
+	segment.data = segment.raw[4:]
+
+	if position >= 0 {
+		segments[position] = segment
+		return segments, nil
+	}
+	return append(segments[:insert], append([]jpegSegment{segment}, segments[insert:]...)...), nil
+}
+
+// Function readJPEGSegments reads the marker segments of a JPEG image up to and including the start of its scan.
+// Parameter reader is the stream containing the JPEG image, positioned after its SOI marker.
+// Returns the segments or error.
+func readJPEGSegments(reader *bufio.Reader) ([]jpegSegment, error) {
+	var segments []jpegSegment
+
+	for {
+		// A marker may be preceded by fill bytes, which are kept.
+		var raw []byte
+		for {
+			c, err := reader.ReadByte()
+			if err != nil {
+				return nil, errors.New("the JPEG image is truncated")
+			}
+			raw = append(raw, c)
+			if c != 0xFF && len(raw) >= 2 && raw[len(raw)-2] == 0xFF {
+				break
+			}
+			if c != 0xFF {
+				return nil, errors.New("the JPEG image is corrupt")
+			}
+		}
+
+		segment := jpegSegment{marker: raw[len(raw)-1], raw: raw}
+		switch segment.marker {
+		case 0xD9:
+			return nil, errors.New("the JPEG image does not contain any image data")
+		case 0x01, 0xD0, 0xD1, 0xD2, 0xD3, 0xD4, 0xD5, 0xD6, 0xD7:
+			// These markers do not have a length or data.
+			segments = append(segments, segment)
+			continue
+		}
+
+		var length [2]byte
+		_, err := io.ReadFull(reader, length[:])
+		if err != nil || binary.BigEndian.Uint16(length[:]) < 2 {
+			return nil, errors.New("the JPEG image is corrupt")
+		}
+
+		segment.data = make([]byte, binary.BigEndian.Uint16(length[:])-2)
+		_, err = io.ReadFull(reader, segment.data)
+		if err != nil {
+			return nil, errors.New("the JPEG image is truncated")
+		}
+		segment.raw = append(append(segment.raw, length[:]...), segment.data...)
+		segments = append(segments, segment)
+
+		if segment.marker == 0xDA {
+			return segments, nil
+		}
+	}
+}
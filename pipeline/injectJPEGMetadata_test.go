@@ -0,0 +1,168 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"github.com/abitofhelp/pipeline/image"
+	goimage "image"
+	"image/jpeg"
+	"testing"
+)
+
+// Function newTestSegment creates the bytes of a JPEG marker segment with its length.
+func newTestSegment(marker byte, data []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(data)))
+	return append(segment, data...)
+}
+
+// Function newTestJPEG encodes a small JPEG image, inserting segments after its SOI marker.
+// Returns the image, and the encoded image's own segments and data that follow the inserted segments.
+func newTestJPEG(t *testing.T, inserted ...[]byte) ([]byte, []byte) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, goimage.NewGray(goimage.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := []byte{0xFF, 0xD8}
+	for _, segment := range inserted {
+		stream = append(stream, segment...)
+	}
+	return append(stream, encoded.Bytes()[2:]...), encoded.Bytes()[2:]
+}
+
+// Function TestInjectJPEGMetadata verifies that the EXIF and XMP segments are written after the APP0 segments,
+// that existing ones are replaced where they are rather than duplicated, and that every other segment and the image
+// data are copied unchanged.
+func TestInjectJPEGMetadata(t *testing.T) {
+	jfif := newTestSegment(0xE0, []byte("JFIF\x00\x01\x02\x00\x00\x01\x00\x01\x00\x00"))
+	jfxx := newTestSegment(0xE0, []byte("JFXX\x00\x13"))
+	comment := newTestSegment(0xFE, []byte("a comment"))
+	exif := newTestSegment(0xE1, append([]byte(image.ExifIdentifier), littleEndianTestExif...))
+	xmp := newTestSegment(0xE1, []byte(image.XmpIdentifier+kTestPacket))
+
+	tests := []struct {
+		name     string
+		inserted [][]byte
+		markers  []byte
+		make     string
+	}{
+		{"no metadata", nil, []byte{0xE1, 0xE1}, ""},
+		{"APP0 segments", [][]byte{jfif, jfxx, comment}, []byte{0xE0, 0xE0, 0xE1, 0xE1, 0xFE}, ""},
+		{"existing metadata", [][]byte{jfif, comment, exif, xmp}, []byte{0xE0, 0xFE, 0xE1, 0xE1}, "Canon"},
+		{"existing XMP before EXIF", [][]byte{xmp, jfif}, []byte{0xE1, 0xE1, 0xE0}, ""},
+	}
+
+	for _, test := range tests {
+		input, encoded := newTestJPEG(t, test.inserted...)
+		img := newTestImage(t)
+
+		var output bytes.Buffer
+		if err := injectMetadataToStream(bytes.NewReader(input), &output, img, nil); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(output.Bytes())); err != nil {
+			t.Errorf("%s: the written image cannot be decoded: %v", test.name, err)
+		}
+
+		reader := bufio.NewReader(bytes.NewReader(output.Bytes()[2:]))
+		segments, err := readJPEGSegments(reader)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		// The inserted segments are followed by the encoded image's own segments, which are unchanged.
+		if len(segments) < len(test.markers) {
+			t.Fatalf("%s: there are %d segments", test.name, len(segments))
+		}
+		var markers []byte
+		for _, segment := range segments[:len(test.markers)] {
+			markers = append(markers, segment.marker)
+			if segment.marker != 0xE1 && !containsSegment(test.inserted, segment.raw) {
+				t.Errorf("%s: the segment %X changed", test.name, segment.marker)
+			}
+		}
+		if !bytes.Equal(markers, test.markers) {
+			t.Errorf("%s: the markers are % X, want % X", test.name, markers, test.markers)
+		}
+
+		var rest bytes.Buffer
+		for _, segment := range segments[len(test.markers):] {
+			rest.Write(segment.raw)
+		}
+		reader.WriteTo(&rest)
+		if !bytes.Equal(rest.Bytes(), encoded) {
+			t.Errorf("%s: the image's segments and data changed", test.name)
+		}
+
+		if exif := findAPP1Segment(segments, image.ExifIdentifier); exif < 0 || findAPP1Segment(segments[exif+1:], image.ExifIdentifier) >= 0 {
+			t.Errorf("%s: there is not exactly one EXIF segment", test.name)
+		}
+		if xmp := findAPP1Segment(segments, image.XmpIdentifier); xmp < 0 || findAPP1Segment(segments[xmp+1:], image.XmpIdentifier) >= 0 {
+			t.Errorf("%s: there is not exactly one XMP segment", test.name)
+		}
+
+		written, err := image.ReadExif(bytes.NewReader(output.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !written.DateTimeOriginal.Equal(img.CreatedUtc()) || !written.HasLocation || written.Make != test.make {
+			t.Errorf("%s: the EXIF metadata is %+v", test.name, written)
+		}
+		writtenXmp, err := image.ReadXmp(bytes.NewReader(output.Bytes()))
+		if err != nil || writtenXmp.Title != "New" {
+			t.Errorf("%s: the XMP metadata is %v, %v", test.name, writtenXmp, err)
+		}
+	}
+}
+
+// Function TestInjectJPEGMetadataUnchanged verifies that an image whose metadata is unknown is copied unchanged.
+func TestInjectJPEGMetadataUnchanged(t *testing.T) {
+	input, _ := newTestJPEG(t, newTestSegment(0xE0, []byte("JFIF\x00\x01\x02\x00\x00\x01\x00\x01\x00\x00")))
+
+	img, err := image.NewImageFromPath("/photos/test.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	if err = injectMetadataToStream(bytes.NewReader(input), &output, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output.Bytes(), input) {
+		t.Error("the image without metadata was changed")
+	}
+
+	if err = injectMetadataToStream(bytes.NewReader(input), &output, img, map[string]string{"Software": "pipeline"}); err == nil {
+		t.Error("custom metadata was injected into a JPEG image")
+	}
+	if err = injectMetadataToStream(bytes.NewReader(input[:30]), &output, newTestImage(t), nil); err == nil {
+		t.Error("a truncated image was accepted")
+	}
+}
+
+// Function containsSegment determines whether a segment's bytes are one of the inserted segments.
+func containsSegment(inserted [][]byte, raw []byte) bool {
+	for _, segment := range inserted {
+		if bytes.Equal(segment, raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// Variable littleEndianTestExif is little-endian EXIF data whose IFD0 records only the camera's make, "Canon".
+var littleEndianTestExif = []byte{
+	'I', 'I', 42, 0, 8, 0, 0, 0,
+	1, 0,
+	0x0f, 0x01, 2, 0, 6, 0, 0, 0, 26, 0, 0, 0,
+	0, 0, 0, 0,
+	'C', 'a', 'n', 'o', 'n', 0,
+}
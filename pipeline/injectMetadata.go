@@ -0,0 +1,164 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"github.com/abitofhelp/pipeline/image"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The name of the inject step, which records its results and errors in the work items.
+const kInjectStep = "inject"
+
+// Method injectItemMetadata writes a work item's image to the pipeline's output directory with its metadata injected,
+// without decoding or re-compressing its pixels. The metadata is read from the image's XMP sidecar files, then from
+// its EXIF metadata, and then from its XMP packet, so each source overrides the one before it, and the precedence is
+// the same as the geofence's. Only JPEG and PNG images are written, and the path of the written image is attached
+// to the work item. An error is recorded in the work item.
+// Parameter item is the work item.
+func (p Pipeline) injectItemMetadata(item *WorkItem) {
+	if item.Format != JPEGFormat && item.Format != PNGFormat {
+		return
+	}
+
+	img, err := p.itemMetadata(item)
+	if err != nil {
+		item.AddError(kInjectStep, err)
+		return
+	}
+
+	destination := p.outputPathOf(item.Path)
+	err = p.injectToFile(item.Path, destination, img)
+	if err != nil {
+		item.AddError(kInjectStep, err)
+		return
+	}
+
+	item.SetAttribute(kInjectStep, "output", destination)
+}
+
+// Method itemMetadata reads a work item's metadata from its XMP sidecar files and its image's EXIF metadata
+// and XMP packet. A source that cannot be read records the error, and the other sources are still read.
+// Parameter item is the work item, which records the errors that are encountered.
+// Returns the image with its metadata, or error.
+func (p Pipeline) itemMetadata(item *WorkItem) (*image.Image, error) {
+	img, err := image.NewImageFromPath(item.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	type source struct {
+		path string
+		read func(io.Reader) error
+	}
+
+	var sources []source
+	for _, sidecar := range item.Sidecars {
+		if strings.EqualFold(filepath.Ext(sidecar), ".xmp") {
+			sources = append(sources, source{sidecar, func(reader io.Reader) error {
+				packet, err := io.ReadAll(io.LimitReader(reader, image.MaxMetadataSize))
+				if err != nil {
+					return err
+				}
+				xmp, err := image.ParseXmp(packet)
+				if err != nil {
+					return err
+				}
+				return img.SetXmp(xmp)
+			}})
+		}
+	}
+	sources = append(sources, source{item.Path, img.ReadExif}, source{item.Path, img.ReadXmp})
+
+	for _, source := range sources {
+		err = p.readMetadata(source.path, source.read)
+		if err != nil && err != image.ErrNoExif && err != image.ErrNoXmp {
+			item.AddError(kInjectStep, err)
+		}
+	}
+
+	return img, nil
+}
+
+// Method readMetadata opens a file and reads its metadata.
+// Parameter path is the path to the file.
+// Parameter read reads the metadata from the file's stream.
+// Returns nil if there are no errors.
+func (p Pipeline) readMetadata(path string, read func(io.Reader) error) error {
+	file, err := p.OpenPath(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return read(file)
+}
+
+// Method outputPathOf gets the path in the output directory that receives a file's image: its path relative to the
+// deepest of the directories walked by the pipeline's sources that contains it, so the output mirrors the input.
+// A member within an archive is written beneath a directory named for its archive, and a remote file beneath
+// a directory named for its host.
+// Parameter path is the path to the file.
+func (p Pipeline) outputPathOf(path string) string {
+	relative := p.shardKey(path)
+	if IsRemotePath(path) {
+		if location, err := url.Parse(path); err == nil {
+			relative = location.Host + "/" + location.Path
+		}
+	}
+
+	// The path is cleaned from a root, so a name such as "../x.jpg" within an archive cannot escape the output directory.
+	relative = filepath.FromSlash(strings.Replace(relative, kArchiveSeparator, "/", -1))
+	relative = filepath.Clean(string(filepath.Separator) + strings.TrimPrefix(relative, filepath.VolumeName(relative)))
+
+	return filepath.Join(p.OutputPath(), relative)
+}
+
+// Method injectToFile writes an image with its metadata injected to a file, replacing the file atomically,
+// so an interrupted run does not leave a partial image.
+// Parameter source is the path to the image, which may refer to a member within an archive.
+// Parameter destination is the path to the file that receives the image.
+// Parameter img is the image's metadata.
+// Returns nil if there are no errors.
+func (p Pipeline) injectToFile(source string, destination string, img *image.Image) error {
+	reader, err := p.OpenPath(source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = os.MkdirAll(filepath.Dir(destination), 0755)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to create the output directory: ", err))
+	}
+
+	temporary, err := os.CreateTemp(filepath.Dir(destination), "."+filepath.Base(destination)+".*")
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to create the output file: ", err))
+	}
+	defer os.Remove(temporary.Name())
+
+	err = injectMetadataToStream(reader, temporary, img, nil)
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to inject the metadata: ", err))
+	}
+
+	err = os.Rename(temporary.Name(), destination)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to write the output file: ", err))
+	}
+	return nil
+}
@@ -6,6 +6,384 @@
 // Package pipeline implements a processing pipeline with multiple steps.
 package pipeline
 
-func injectMetadataToStream() {
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/abitofhelp/pipeline/image"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
+const (
+	// The maximum length of the keyword of a PNG text chunk.
+	kMaxPNGKeywordSize = 79
+
+	// The size of a text value, in bytes, above which its chunk is compressed.
+	kCompressTextSize = 1024
+
+	// The keywords of the text chunks carrying the image's metadata.
+	kPNGKeywordCreated = "Creation Time"
+	kPNGKeywordLatLng  = "LatLng"
+)
+
+// Type pngText is a keyword and value to write in a PNG text chunk.
+type pngText struct {
+	// Field keyword is the keyword, which identifies the kind of value.
+	keyword string
+
+	// Field value is the value, in UTF-8.
+	value string
+
+	// Field international indicates whether the value is written in an uncompressed iTXt chunk, as XMP requires.
+	international bool
+}
+
+// Inject an image's metadata into a stream containing a PNG or JPEG image, without decoding or re-compressing
+// the pixels. A PNG image receives text chunks placed before its image data: every other chunk is copied unchanged,
+// and a text chunk that has the keyword of a new value is replaced by it. A JPEG image receives the metadata in
+// its APP1 EXIF segment, and every other segment is copied unchanged. Both receive the image's XMP metadata,
+// which is merged into their XMP packet, wherever it is. The pipeline's inject step writes the images with this.
+// Parameter reader is the stream containing the image.
+// Parameter writer is the stream that receives the image with its metadata.
+// Parameter img is the image whose createdUtc, latlng and XMP metadata are injected, when they are known.
+// Parameter custom are further keywords and values to inject, which only a PNG image can carry.
+// Returns nil if there are no errors.
+func injectMetadataToStream(reader io.Reader, writer io.Writer, img *image.Image, custom map[string]string) error {
+	buffered := bufio.NewReader(reader)
+
+	signature, _ := buffered.Peek(len(image.PNGSignature))
+	if bytes.HasPrefix(signature, []byte{0xFF, 0xD8}) {
+		if len(custom) > 0 {
+			return errors.New("custom metadata can only be injected into a PNG image")
+		}
+		return injectJPEGMetadata(buffered, writer, img)
+	}
+
+	texts, err := pngTexts(img, custom)
+	if err != nil {
+		return err
+	}
+
+	return injectPNGTexts(buffered, writer, texts, img)
+}
+
+// Function pngTexts gets the keywords and values to inject for an image, in the order that they are written.
+// Parameter img is the image.
+// Parameter custom are further keywords and values to inject.
+// Returns the keywords and values or error.
+func pngTexts(img *image.Image, custom map[string]string) ([]pngText, error) {
+	var texts []pngText
+
+	if img != nil {
+		if created := img.CreatedUtc(); !created.IsZero() {
+			texts = append(texts, pngText{keyword: kPNGKeywordCreated, value: created.UTC().Format(time.RFC3339)})
+		}
+		if location := img.LatLng(); img.HasLocation() {
+			value := strconv.FormatFloat(location.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(location.Longitude, 'f', -1, 64)
+			texts = append(texts, pngText{keyword: kPNGKeywordLatLng, value: value})
+		}
+	}
+
+	keywords := make([]string, 0, len(custom))
+	for keyword := range custom {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		if keyword == kPNGKeywordCreated || keyword == kPNGKeywordLatLng || keyword == image.XmpKeyword {
+			return nil, errors.New(fmt.Sprintf("%s%q", "the keyword is reserved for the image's metadata: ", keyword))
+		}
+		texts = append(texts, pngText{keyword: keyword, value: custom[keyword]})
+	}
+
+	for _, text := range texts {
+		if _, err := latin1Keyword(text.keyword); err != nil {
+			return nil, err
+		}
+		if strings.IndexByte(text.value, 0) >= 0 {
+			return nil, errors.New(fmt.Sprintf("%s%q", "the PNG text cannot contain a null character: ", text.keyword))
+		}
+	}
+
+	return texts, nil
+}
+
+// Function injectPNGTexts copies a PNG image chunk by chunk, writing text chunks before its first IDAT chunk
+// and dropping the existing text chunks that have the same keywords, wherever they are. When the image has XMP
+// metadata, it is merged into the image's XMP packet, which is replaced. The merged packet is written before the
+// image data when the packet precedes it, and otherwise before the IEND chunk, so a packet after the image data
+// is merged without buffering the image data. A further XMP packet is dropped.
+// Parameter reader is the stream containing the PNG image.
+// Parameter writer is the stream that receives the PNG image with the text chunks.
+// Parameter texts are the keywords and values to write.
+// Parameter img is the image whose XMP metadata is written, or nil.
+// Returns nil if there are no errors.
+func injectPNGTexts(reader *bufio.Reader, writer io.Writer, texts []pngText, img *image.Image) error {
+	signature := make([]byte, len(image.PNGSignature))
+	_, err := io.ReadFull(reader, signature)
+	if err != nil || string(signature) != image.PNGSignature {
+		return errors.New("the stream does not contain a PNG or JPEG image")
+	}
+	_, err = writer.Write(signature)
+	if err != nil {
+		return err
+	}
+
+	replaced := make(map[string]bool, len(texts))
+	for _, text := range texts {
+		keyword, _ := latin1Keyword(text.keyword)
+		replaced[keyword] = true
+	}
+
+	writeXmp := img != nil && !img.Xmp().IsEmpty()
+	var existingXmp []byte
+	xmpWritten := false
+
+	injected := false
+	for {
+		var header [8]byte
+		_, err := io.ReadFull(reader, header[:])
+		if err != nil {
+			return errors.New("the PNG image is truncated")
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		kind := string(header[4:])
+
+		if kind == "IDAT" && !injected {
+			for _, text := range texts {
+				err = writePNGText(writer, text)
+				if err != nil {
+					return err
+				}
+			}
+			if writeXmp && existingXmp != nil {
+				err = writePNGXmp(writer, img, existingXmp)
+				if err != nil {
+					return err
+				}
+				xmpWritten = true
+			}
+			injected = true
+		}
+
+		if kind == "IEND" && writeXmp && !xmpWritten {
+			err = writePNGXmp(writer, img, existingXmp)
+			if err != nil {
+				return err
+			}
+			xmpWritten = true
+		}
+
+		// A text chunk begins with its keyword, which is terminated by a null byte.
+		prefix := []byte{}
+		if kind == "tEXt" || kind == "iTXt" || kind == "zTXt" {
+			prefix = make([]byte, minInt64(length, kMaxPNGKeywordSize+1))
+			_, err = io.ReadFull(reader, prefix)
+			if err != nil {
+				return errors.New("the PNG image is truncated")
+			}
+			keyword := ""
+			if end := bytes.IndexByte(prefix, 0); end >= 0 {
+				keyword = string(prefix[:end])
+			}
+
+			if writeXmp && kind == "iTXt" && keyword == image.XmpKeyword {
+				if length > image.MaxMetadataSize {
+					return errors.New("the PNG image's XMP packet is too large")
+				}
+				data := make([]byte, length-int64(len(prefix)))
+				_, err = io.ReadFull(reader, data)
+				if err == nil {
+					_, err = reader.Discard(4)
+				}
+				if err != nil {
+					return errors.New("the PNG image is truncated")
+				}
+				if existingXmp == nil && !xmpWritten {
+					existingXmp, err = image.InternationalText(append(prefix[len(keyword)+1:], data...))
+					if err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if replaced[keyword] {
+				_, err = io.CopyN(io.Discard, reader, length-int64(len(prefix))+4)
+				if err != nil {
+					return errors.New("the PNG image is truncated")
+				}
+				continue
+			}
+		}
+
+		// Copy the chunk unchanged, including its CRC.
+		_, err = writer.Write(append(header[:], prefix...))
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(writer, reader, length-int64(len(prefix))+4)
+		if err != nil {
+			return errors.New("the PNG image is truncated")
+		}
+
+		if kind == "IEND" {
+			if !injected {
+				return errors.New("the PNG image does not contain any image data")
+			}
+			return nil
+		}
+	}
+}
+
+// Function writePNGXmp writes an image's XMP metadata in an uncompressed iTXt chunk, merged into an existing packet.
+// Parameter writer is the stream that receives the chunk.
+// Parameter img is the image whose XMP metadata is written.
+// Parameter existing is the image's existing XMP packet, or nil when there is none.
+// Returns nil if there are no errors.
+func writePNGXmp(writer io.Writer, img *image.Image, existing []byte) error {
+	packet, err := img.EncodeXmp(existing)
+	if err != nil {
+		return err
+	}
+	return writePNGText(writer, pngText{keyword: image.XmpKeyword, value: string(packet), international: true})
+}
+
+// Function writePNGText writes a keyword and value in a text chunk: tEXt for Latin-1 text, iTXt for other
+// UTF-8 text, and zTXt or a compressed iTXt for text that is larger than kCompressTextSize.
+// Parameter writer is the stream that receives the chunk.
+// Parameter text is the keyword and value.
+// Returns nil if there are no errors.
+func writePNGText(writer io.Writer, text pngText) error {
+	keyword, err := latin1Keyword(text.keyword)
+	if err != nil {
+		return err
+	}
+
+	value, isLatin1 := latin1(text.value)
+	compress := len(text.value) > kCompressTextSize
+
+	var data bytes.Buffer
+	data.WriteString(keyword)
+	data.WriteByte(0)
+
+	var kind string
+	switch {
+	case text.international:
+		kind = "iTXt"
+		data.Write([]byte{0, 0, 0, 0}) // The value is not compressed, and there is no language tag or translated keyword.
+		data.WriteString(text.value)
+	case isLatin1 && !compress:
+		kind = "tEXt"
+		data.Write(value)
+	case isLatin1:
+		kind = "zTXt"
+		data.WriteByte(0) // The compression method, which is zlib.
+		err = deflateTo(&data, value)
+	default:
+		kind = "iTXt"
+		if compress {
+			data.Write([]byte{1, 0}) // The value is compressed with zlib.
+		} else {
+			data.Write([]byte{0, 0})
+		}
+		data.WriteByte(0) // There is no language tag.
+		data.WriteByte(0) // There is no translated keyword.
+		if compress {
+			err = deflateTo(&data, []byte(text.value))
+		} else {
+			data.WriteString(text.value)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return writePNGChunk(writer, kind, data.Bytes())
+}
+
+// Function writePNGChunk writes a chunk with its length and CRC.
+// Parameter writer is the stream that receives the chunk.
+// Parameter kind is the chunk's type, such as "tEXt".
+// Parameter data is the chunk's data.
+// Returns nil if there are no errors.
+func writePNGChunk(writer io.Writer, kind string, data []byte) error {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk[:4], uint32(len(data)))
+	copy(chunk[4:], kind)
+	chunk = append(chunk, data...)
+
+	// The CRC covers the chunk's type and data, but not its length.
+	chunk = append(chunk, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(chunk[len(chunk)-4:], crc32.ChecksumIEEE(chunk[4:len(chunk)-4]))
+
+	_, err := writer.Write(chunk)
+	return err
+}
+
+// Function deflateTo compresses a value with zlib.
+// Parameter buffer receives the compressed value.
+// Parameter value is the value to compress.
+// Returns nil if there are no errors.
+func deflateTo(buffer *bytes.Buffer, value []byte) error {
+	compressor := zlib.NewWriter(buffer)
+	_, err := compressor.Write(value)
+	if err != nil {
+		return err
+	}
+	return compressor.Close()
+}
+
+// Function latin1Keyword validates the keyword of a text chunk and encodes it in Latin-1.
+// A keyword has 1 to 79 printable Latin-1 characters, without leading, trailing or consecutive spaces.
+// Parameter keyword is the keyword, in UTF-8.
+// Returns the encoded keyword or error.
+func latin1Keyword(keyword string) (string, error) {
+	encoded, ok := latin1(keyword)
+	if !ok || len(encoded) == 0 || len(encoded) > kMaxPNGKeywordSize {
+		return "", errors.New(fmt.Sprintf("%s%q", "the PNG keyword must have 1 to 79 Latin-1 characters: ", keyword))
+	}
+
+	for index, c := range encoded {
+		if c < 0x20 || (c > 0x7E && c < 0xA1) {
+			return "", errors.New(fmt.Sprintf("%s%q", "the PNG keyword must only contain printable characters: ", keyword))
+		}
+		if c == ' ' && (index == 0 || index == len(encoded)-1 || encoded[index-1] == ' ') {
+			return "", errors.New(fmt.Sprintf("%s%q", "the PNG keyword has misplaced spaces: ", keyword))
+		}
+	}
+
+	return string(encoded), nil
+}
+
+// Function latin1 encodes a UTF-8 string in Latin-1.
+// Parameter value is the string.
+// Returns the encoded string, or false if a character cannot be encoded in Latin-1.
+func latin1(value string) ([]byte, bool) {
+	encoded := make([]byte, 0, len(value))
+	for _, r := range value {
+		if r > 0xFF {
+			return nil, false
+		}
+		encoded = append(encoded, byte(r))
+	}
+	return encoded, true
+}
+
+// Function minInt64 gets the smaller of two numbers.
+func minInt64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
 }
@@ -0,0 +1,245 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/abitofhelp/pipeline/image"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"hash/crc32"
+	goimage "image"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Constant kTestPacket is an XMP packet with a title and a property of another application, which must be preserved.
+const kTestPacket = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+	`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" ` +
+	`xmp:CreatorTool="Camera Firmware 1.0"><dc:title><rdf:Alt><rdf:li xml:lang="x-default">Old</rdf:li></rdf:Alt></dc:title>` +
+	`</rdf:Description></rdf:RDF></x:xmpmeta>`
+
+// Type testPNGChunk is a chunk of a PNG stream, as it was read by a test.
+type testPNGChunk struct {
+	kind     string
+	data     []byte
+	validCrc bool
+}
+
+// Method keyword gets the keyword of a text chunk, or empty for any other chunk.
+func (c testPNGChunk) keyword() string {
+	if c.kind != "tEXt" && c.kind != "iTXt" && c.kind != "zTXt" {
+		return ""
+	}
+	end := bytes.IndexByte(c.data, 0)
+	if end < 0 {
+		return ""
+	}
+	return string(c.data[:end])
+}
+
+// Function readTestPNGChunks splits a PNG stream into its chunks, failing the test when the stream is malformed.
+func readTestPNGChunks(t *testing.T, stream []byte) []testPNGChunk {
+	if !bytes.HasPrefix(stream, []byte(image.PNGSignature)) {
+		t.Fatal("the stream does not begin with the PNG signature")
+	}
+	stream = stream[len(image.PNGSignature):]
+
+	var chunks []testPNGChunk
+	for len(stream) > 0 {
+		if len(stream) < 12 {
+			t.Fatal("the PNG stream is truncated")
+		}
+		length := int(binary.BigEndian.Uint32(stream[:4]))
+		if len(stream) < 12+length {
+			t.Fatal("the PNG stream is truncated")
+		}
+		crc := binary.BigEndian.Uint32(stream[8+length:])
+		chunks = append(chunks, testPNGChunk{
+			kind:     string(stream[4:8]),
+			data:     stream[8 : 8+length],
+			validCrc: crc == crc32.ChecksumIEEE(stream[4:8+length]),
+		})
+		stream = stream[12+length:]
+	}
+	return chunks
+}
+
+// Function newTestPNG encodes a small PNG image, inserting chunks before its image data and after it.
+func newTestPNG(t *testing.T, before []testPNGChunk, after []testPNGChunk) []byte {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, goimage.NewGray(goimage.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	var stream bytes.Buffer
+	stream.WriteString(image.PNGSignature)
+	for _, chunk := range readTestPNGChunks(t, encoded.Bytes()) {
+		inserted := []testPNGChunk{}
+		switch chunk.kind {
+		case "IDAT":
+			inserted, before = before, nil
+		case "IEND":
+			inserted = after
+		}
+		for _, insert := range append(inserted, chunk) {
+			if err := writePNGChunk(&stream, insert.kind, insert.data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return stream.Bytes()
+}
+
+// Function newTestImage creates an image whose date/time, location and title are injected.
+func newTestImage(t *testing.T) *image.Image {
+	img, err := image.NewImageFromPath("/photos/test.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = img.SetCreatedUtc(time.Date(2018, 7, 4, 19, 30, 15, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if err = img.SetLatLng(latlng.LatLng{Latitude: 37.7749, Longitude: -122.4194}); err != nil {
+		t.Fatal(err)
+	}
+	if err = img.SetTitle("New"); err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// Function TestInjectPNGTexts verifies that the text chunks are written before the image data with valid CRCs,
+// that an existing keyword is replaced rather than duplicated, that an existing XMP packet is merged where it was,
+// and that the other chunks are copied unchanged.
+func TestInjectPNGTexts(t *testing.T) {
+	xmpChunk := func() testPNGChunk {
+		return testPNGChunk{kind: "iTXt", data: []byte(image.XmpKeyword + "\x00\x00\x00\x00\x00" + kTestPacket)}
+	}
+	existing := []testPNGChunk{
+		{kind: "tEXt", data: []byte(kPNGKeywordCreated + "\x002001-01-01T00:00:00Z")},
+		{kind: "tEXt", data: []byte("Author\x00Photographer")},
+	}
+
+	tests := []struct {
+		name         string
+		before       []testPNGChunk
+		after        []testPNGChunk
+		xmpAfterIDAT bool
+	}{
+		{"no metadata", nil, nil, true},
+		{"text and XMP before the image data", append(existing, xmpChunk()), nil, false},
+		{"XMP after the image data", existing, []testPNGChunk{xmpChunk()}, true},
+	}
+
+	for _, test := range tests {
+		input := newTestPNG(t, test.before, test.after)
+
+		var output bytes.Buffer
+		err := injectMetadataToStream(bytes.NewReader(input), &output, newTestImage(t), map[string]string{"Software": "pipeline"})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if _, err = png.Decode(bytes.NewReader(output.Bytes())); err != nil {
+			t.Errorf("%s: the written image cannot be decoded: %v", test.name, err)
+		}
+
+		var (
+			keywords  = make(map[string]int)
+			values    = make(map[string]string)
+			firstIDAT = -1
+			xmpIndex  = -1
+			unchanged []testPNGChunk
+			chunks    = readTestPNGChunks(t, output.Bytes())
+		)
+		for index, chunk := range chunks {
+			if !chunk.validCrc {
+				t.Errorf("%s: the %s chunk at %d has an invalid CRC", test.name, chunk.kind, index)
+			}
+			if chunk.kind == "IDAT" && firstIDAT < 0 {
+				firstIDAT = index
+			}
+
+			keyword := chunk.keyword()
+			if keyword == "" {
+				unchanged = append(unchanged, chunk)
+				continue
+			}
+			keywords[keyword]++
+			values[keyword] = string(chunk.data[len(keyword)+1:])
+			if keyword == image.XmpKeyword {
+				xmpIndex = index
+			} else if firstIDAT >= 0 {
+				t.Errorf("%s: the %q chunk follows the image data", test.name, keyword)
+			}
+		}
+
+		for _, keyword := range []string{kPNGKeywordCreated, kPNGKeywordLatLng, "Software", image.XmpKeyword} {
+			if keywords[keyword] != 1 {
+				t.Errorf("%s: there are %d %q chunks, want 1", test.name, keywords[keyword], keyword)
+			}
+		}
+		if values[kPNGKeywordCreated] != "2018-07-04T19:30:15Z" || values["Software"] != "pipeline" {
+			t.Errorf("%s: the text chunks are %q", test.name, values)
+		}
+		if test.before != nil && values["Author"] != "Photographer" {
+			t.Errorf("%s: the existing text chunk was not preserved: %q", test.name, values)
+		}
+		if (xmpIndex > firstIDAT) != test.xmpAfterIDAT {
+			t.Errorf("%s: the XMP packet is at %d, and the image data at %d", test.name, xmpIndex, firstIDAT)
+		}
+
+		xmp, err := image.ParseXmp([]byte(strings.TrimPrefix(values[image.XmpKeyword], "\x00\x00\x00\x00")))
+		if err != nil || xmp.Title != "New" {
+			t.Errorf("%s: the XMP packet is %v, %v", test.name, xmp, err)
+		}
+		if test.before != nil || test.after != nil {
+			if !strings.Contains(values[image.XmpKeyword], "Camera Firmware 1.0") {
+				t.Errorf("%s: the existing XMP packet was not merged", test.name)
+			}
+		}
+
+		// The chunks other than the text chunks are copied unchanged.
+		original := []testPNGChunk{}
+		for _, chunk := range readTestPNGChunks(t, input) {
+			if chunk.keyword() == "" {
+				original = append(original, chunk)
+			}
+		}
+		if len(unchanged) != len(original) {
+			t.Fatalf("%s: there are %d other chunks, want %d", test.name, len(unchanged), len(original))
+		}
+		for index := range original {
+			if unchanged[index].kind != original[index].kind || !bytes.Equal(unchanged[index].data, original[index].data) {
+				t.Errorf("%s: the %s chunk at %d changed", test.name, original[index].kind, index)
+			}
+		}
+	}
+}
+
+// Function TestInjectPNGTextsRejects verifies that a stream that is not a complete PNG image is rejected.
+func TestInjectPNGTextsRejects(t *testing.T) {
+	valid := newTestPNG(t, nil, nil)
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"not an image", []byte("not an image")},
+		{"truncated", valid[:len(valid)-20]},
+		{"no image data", append([]byte(image.PNGSignature), valid[len(valid)-12:]...)},
+	}
+
+	for _, test := range tests {
+		var output bytes.Buffer
+		if err := injectMetadataToStream(bytes.NewReader(test.input), &output, newTestImage(t), nil); err == nil {
+			t.Errorf("%s: the stream was accepted", test.name)
+		}
+	}
+}
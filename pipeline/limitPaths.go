@@ -0,0 +1,56 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"github.com/abitofhelp/pipeline/image"
+)
+
+// The name of the limits step, which records its results and errors in the work items.
+const kLimitsStep = "limits"
+
+// Pass only the work items whose images are within the pipeline's limits, as declared in their headers,
+// so a decompression bomb is dropped before a step after it decodes its pixels. Only the formats that can be
+// decoded are checked. A header that cannot be read records the error, and the work item is still passed.
+// Parameter inChannel is the unidirectional channel of discovered work items.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+func (p Pipeline) limitPaths(inChannel <-chan WorkItem, pathsChannel chan<- WorkItem) {
+	defer close(pathsChannel)
+
+	for item := range inChannel {
+		err := p.checkItemLimits(item)
+
+		var limitErr *image.LimitError
+		if errors.As(err, &limitErr) {
+			p.Report().addOversized(item.Path, limitErr)
+			continue
+		}
+		if err != nil {
+			item.AddError(kLimitsStep, err)
+		}
+
+		pathsChannel <- item
+	}
+}
+
+// Method checkItemLimits determines whether a work item's image is within the pipeline's limits.
+// Parameter item is the work item.
+// Returns nil when the image is within the limits or cannot be decoded, a *image.LimitError when it is not, or error.
+func (p Pipeline) checkItemLimits(item WorkItem) error {
+	if item.Format != JPEGFormat && item.Format != PNGFormat && item.Format != GIFFormat {
+		return nil
+	}
+
+	file, err := p.OpenPath(item.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return p.Limits().CheckHeader(file)
+}
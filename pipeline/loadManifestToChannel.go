@@ -0,0 +1,117 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Read the paths of the files to process from a manifest, and pass them into the pipeline for processing.
+// The entries are separated by newlines, or by NUL characters to be compatible with the output of `find -print0`.
+// The manifest is always read from the operating system, while its entries are paths within the source file system.
+// Parameter manifest is the path to the manifest file, or "-" to read the manifest from stdin.
+// Parameter nullDelimited indicates whether the entries are separated by NUL rather than newline characters.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+// Returns nil if there are no errors.
+func (p Pipeline) loadManifestToChannel(manifest string, nullDelimited bool, pathsChannel chan<- WorkItem) error {
+
+	var reader io.Reader = os.Stdin
+	if manifest != kStdinManifest {
+		file, err := os.Open(manifest)
+		if err != nil {
+			return errors.New(fmt.Sprintf("%s%v", "failed to open the manifest: ", err))
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	delimiter := byte('\n')
+	if nullDelimited {
+		delimiter = 0
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, p.ScannerBufferSize()), bufio.MaxScanTokenSize+int(p.ScannerBufferSize()))
+	scanner.Split(splitOnDelimiter(delimiter))
+
+	for scanner.Scan() {
+		if p.isStopping() {
+			return nil
+		}
+
+		path := scanner.Text()
+		if delimiter == '\n' {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path == "" {
+			continue
+		}
+
+		// Only regular files are processed, so directories and special files in the manifest are skipped.
+		info, err := p.statPath(path)
+		if err != nil {
+			err = p.walkError(path, err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			err = p.walkError(path, errNotRegular)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// A listed sidecar is passed with its primary file, which is expected to be listed too.
+		items := p.findSidecars(path)
+		if len(items) != 1 || items[0].Path != path {
+			continue
+		}
+
+		err = p.enqueuePath(items[0], pathsChannel)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to read the manifest: ", err))
+	}
+
+	return nil
+}
+
+// Function splitOnDelimiter creates a bufio.SplitFunc that separates tokens at each occurrence of a delimiter.
+// Parameter delimiter is the byte that terminates each token.
+func splitOnDelimiter(delimiter byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		if i := bytes.IndexByte(data, delimiter); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+
+		// The final entry is not required to have a trailing delimiter.
+		if atEOF {
+			return len(data), data, nil
+		}
+
+		// Request more data.
+		return 0, nil, nil
+	}
+}
@@ -0,0 +1,89 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Function TestLoadManifestToChannel verifies that the manifest's entries are split on either delimiter,
+// and that the entries which are not regular files are recorded as walk errors under each policy.
+func TestLoadManifestToChannel(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"first.png", "second.jpg"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "album"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []string{
+		filepath.Join(root, "first.png"),
+		"",
+		filepath.Join(root, "album"),
+		filepath.Join(root, "second.jpg"),
+	}
+
+	tests := []struct {
+		name          string
+		nullDelimited bool
+		policy        WalkErrorPolicy
+		paths         []string
+		halts         bool
+	}{
+		{"newlines", false, SkipOnWalkError, []string{entries[0], entries[3]}, false},
+		{"NUL characters", true, SkipOnWalkError, []string{entries[0], entries[3]}, false},
+		{"halt", false, HaltOnWalkError, []string{entries[0]}, true},
+	}
+
+	for _, test := range tests {
+		delimiter := "\r\n"
+		if test.nullDelimited {
+			delimiter = "\x00"
+		}
+		manifest := filepath.Join(t.TempDir(), "manifest")
+		if err := os.WriteFile(manifest, []byte(strings.Join(entries, delimiter)), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := New([]Source{ManifestSource{Path: manifest, NullDelimited: test.nullDelimited}}, 4096, 8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetWalkErrorPolicy(test.policy); err != nil {
+			t.Fatal(err)
+		}
+
+		pathsChannel := make(chan WorkItem, len(entries))
+		err = p.loadManifestToChannel(manifest, test.nullDelimited, pathsChannel)
+		close(pathsChannel)
+
+		var walkErr *WalkError
+		if errors.As(err, &walkErr) != test.halts {
+			t.Errorf("%s: the manifest returned %v", test.name, err)
+		}
+
+		var paths []string
+		for item := range pathsChannel {
+			paths = append(paths, item.Path)
+		}
+		if strings.Join(paths, "|") != strings.Join(test.paths, "|") {
+			t.Errorf("%s: the manifest passed %v, want %v", test.name, paths, test.paths)
+		}
+
+		walkErrors := p.Report().WalkErrors()
+		if len(walkErrors) != 1 || walkErrors[0].Path != entries[2] || !errors.Is(walkErrors[0].Err, errNotRegular) {
+			t.Errorf("%s: the report's walk errors are %v", test.name, walkErrors)
+		}
+	}
+}
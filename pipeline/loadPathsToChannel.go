@@ -7,49 +7,220 @@
 package pipeline
 
 import (
-	"fmt"
+	"errors"
 	godirwalk "github.com/karrick/godirwalk"
-	"os"
-	. "path/filepath"
-	"sync"
+	"io/fs"
+	"sort"
 )
 
-// Recursively walk a file system hierarchy to locate files, and pass the paths into the pipeline for processing.
-// Parameter pathToDirectory is the path to a folder containing files to process.
-// Parameter pathsChannel is the unidirectional channel being used to feed the paths to the pipeline.
+// Variable errStopped is returned from the walk's callback when the pipeline has been signaled to stop.
+var errStopped = errors.New("the pipeline has been stopped")
+
+// Type walkCallback is invoked with the path and directory entry for each node in a walked hierarchy.
+// Returning errStopped or a *WalkError halts the walk, and returning any other error records it and skips the node.
+type walkCallback func(path string, de directoryEntry) error
+
+// Load each of the pipeline's sources in turn, such as a manifest and the file system hierarchies to walk,
+// and pass the work items for the files into the pipeline for processing.
+// A WatchSource continues to watch its hierarchies for new files until the pipeline is stopped.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
 // Parameter commandChannel is used to start the pipeline's processing.
-func (p Pipeline) loadPathsToChannel(pathToDirectory string, pathsChannel chan<- string, commandChannel <-chan bool, wg *sync.WaitGroup) {
-	defer wg.Done()
+// Returns nil if there are no errors.
+func (p Pipeline) loadPathsToChannel(pathsChannel chan<- WorkItem, commandChannel <-chan bool) error {
+
+	// The consumers will finish once all of the paths have been processed.
+	defer close(pathsChannel)
 
 	// We want to wait until the commandChannel signals go...
 	<-commandChannel
 
-	cnt := uint64(0)
+	for _, source := range p.Sources() {
+		if p.isStopping() {
+			return nil
+		}
+
+		err := source.Load(p, pathsChannel)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Pass the work item for a regular file into the pipeline for processing.
+// When discovery descends into archives, the virtual paths of an archive's members are passed instead of the archive.
+// Parameter item is the work item for the file.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+// Returns a *WalkError when an archive could not be read and the walk must halt, otherwise nil.
+func (p Pipeline) enqueuePath(item WorkItem, pathsChannel chan<- WorkItem) error {
+	if p.Archives() && archiveKindOf(item.Path) != notArchive {
+		err := listArchive(p.sourceFileSystem(), item.Path, func(memberPath string, info fs.FileInfo, header []byte) {
+			member := WorkItem{Path: memberPath}
+			member.describe(info)
+			member.Format = detectFormat(header)
+			pathsChannel <- member
+		})
+		if err != nil {
+			return p.walkError(item.Path, err)
+		}
+		return nil
+	}
 
-	godirwalk.Walk(pathToDirectory, &godirwalk.Options{
+	pathsChannel <- item
 
-		FollowSymbolicLinks: false,
-		Unsorted:            true,
+	return nil
+}
+
+// Recursively walk a file system hierarchy to locate files, and pass their work items into the pipeline for processing.
+// The entries of each directory are traversed in the pipeline's sort order.
+// When there are multiple walkers, subtrees are walked concurrently.
+// Parameter pathToDirectory is the path to a folder containing files to process.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+// Parameter watcher, when it is not nil, begins watching each directory that is walked.
+// Returns the *WalkError that halted the walk, otherwise nil.
+func (p Pipeline) walkPathToChannel(pathToDirectory string, pathsChannel chan<- WorkItem, watcher *directoryWatcher) error {
 
-		Callback: func(path string, de *godirwalk.Dirent) error {
-			if de.IsRegular() {
-				wg.Add(1)
-				fmt.Printf("\ncnt: %d", cnt)
-				pathsChannel <- Join(path, de.Name())
-				cnt++
+	// The root must be a directory, so a special file given as a root is never opened.
+	info, err := p.statPath(pathToDirectory)
+	if err == nil && !info.IsDir() {
+		err = errors.New("not a directory")
+	}
+	if err != nil {
+		return p.walkError(pathToDirectory, err)
+	}
+
+	if watcher != nil {
+		err := watcher.addDirectory(pathToDirectory)
+		if err != nil {
+			err = p.walkError(pathToDirectory, err)
+			if err != nil {
+				return err
 			}
+		}
+	}
+
+	callback := func(path string, de directoryEntry) error {
+		if p.isStopping() {
+			return errStopped
+		}
 
-			// Signal no errors...
+		if de.IsDir() {
+			if p.excludes(path, true) {
+				return errSkipDirectory
+			}
+			if watcher != nil {
+				return watcher.addDirectory(path)
+			}
 			return nil
-		},
+		}
+
+		// Symbolic links, sockets, FIFOs and devices are never passed into the pipeline.
+		if de.IsRegular() && !p.excludes(path, false) {
+			item := WorkItem{Path: path, Sidecars: sidecarsOf(de)}
+			item.describeEntry(de)
+			return p.enqueuePath(item, pathsChannel)
+		}
+
+		// Signal no errors...
+		return nil
+	}
+
+	if p.WalkerCount() > 1 {
+		err = p.walkDirectoryInParallel(pathToDirectory, callback)
+	} else {
+		// A single walker reuses one scratch buffer for reading every directory.
+		err = p.walkDirectory(pathToDirectory, make([]byte, p.ScannerBufferSize()), callback)
+	}
+
+	// Stopping the pipeline is not a failure of the walk.
+	if err == errStopped {
+		return nil
+	}
+
+	return err
+}
+
+// Recursively walk a directory, invoking a callback for each of its entries before descending into subdirectories.
+// Symbolic links are not followed.
+// Parameter osDirname is the path to the directory to walk.
+// Parameter scratchBuffer is the reusable buffer for reading directory entries.
+// Parameter callback is invoked with the path and directory entry for each node in the hierarchy.
+// Returns errStopped or the *WalkError that halted the walk, otherwise nil.
+func (p Pipeline) walkDirectory(osDirname string, scratchBuffer []byte, callback walkCallback) error {
+
+	dirents, err := p.readDirectory(osDirname, scratchBuffer)
+	if err != nil {
+		return err
+	}
+
+	for _, de := range dirents {
+		path := p.joinPath(osDirname, de.Name())
+
+		err := callback(path, de)
+		if err == errSkipDirectory {
+			continue
+		}
+		if isHalt(err) {
+			return err
+		}
+		if err != nil {
+			err = p.walkError(path, err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if de.IsDir() {
+			err = p.walkDirectory(path, scratchBuffer, callback)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Read the entries of a directory, ordered by the pipeline's sort order, with sidecar files grouped with their primary files.
+// Operating system directories are read by godirwalk, and the directories of any other source file system by fs.ReadDir.
+// Parameter osDirname is the path to the directory to read.
+// Parameter scratchBuffer is the reusable buffer for reading operating system directory entries.
+// Returns the entries, which are empty when the directory could not be read and was skipped,
+// or the *WalkError that must halt the walk.
+func (p Pipeline) readDirectory(osDirname string, scratchBuffer []byte) ([]directoryEntry, error) {
+
+	var (
+		dirents []directoryEntry
+		err     error
+	)
+
+	if p.FileSystem() == nil {
+		var osDirents godirwalk.Dirents
+		osDirents, err = godirwalk.ReadDirents(osDirname, scratchBuffer)
+		for _, de := range osDirents {
+			dirents = append(dirents, de)
+		}
+	} else {
+		var fsDirents []fs.DirEntry
+		fsDirents, err = fs.ReadDir(p.FileSystem(), osDirname)
+		for _, de := range fsDirents {
+			dirents = append(dirents, fsDirectoryEntry{de})
+		}
+	}
+
+	if err != nil {
+		// On error, we will skip the directory and continue walking the file system
+		// hierarchy of remaining nodes, unless the walk error policy halts the walk.
+		return nil, p.walkError(osDirname, err)
+	}
 
-		ErrorCallback: func(osPathname string, err error) godirwalk.ErrorAction {
-			// Your program may want to log the error somehow.
-			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+	if order := p.SortOrder(); order != Unsorted {
+		sort.Slice(dirents, func(i, j int) bool {
+			return order.less(dirents[i].Name(), dirents[j].Name())
+		})
+	}
 
-			// On error, we will skip the current file system node and continue
-			// walking the file system hierarchy of remaining nodes.
-			return godirwalk.SkipNode
-		},
-	})
+	return p.groupSidecarEntries(osDirname, dirents), nil
 }
@@ -11,11 +11,18 @@ import (
 	"fmt"
 	. "github.com/abitofhelp/go-helpers/string"
 	. "github.com/abitofhelp/go-helpers/time"
+	"github.com/abitofhelp/pipeline/image"
+	godirwalk "github.com/karrick/godirwalk"
+	"io/fs"
+	"os"
 	"strings"
 	"sync"
 	"time"
 )
 
+// The manifest path that indicates the manifest is read from stdin.
+const kStdinManifest = "-"
+
 // Type Status indicates the current state of the pipeline.
 type Status int
 
@@ -39,6 +46,12 @@ type IPipeline interface {
 
 	// Function Stop terminates processing after all steps have been completed.
 	Stop() error
+
+	// Function Report gets the outcomes of the pipeline's run.
+	Report() *Report
+
+	// Function Scan counts the files and bytes in scope for a run, without processing them.
+	Scan() (ScanTotals, error)
 }
 
 // Type Pipeline is a struct that provides data and methods to create and manage a pipeline.
@@ -52,9 +65,6 @@ type Pipeline struct {
 	// Field status indicates the current status of the pipeline.
 	status Status
 
-	// Field path is the file system path to a directory containing image files to process.
-	path string
-
 	// Field scannerBufferSize is  the number of reusable bytes to use for the directory scanner's work.
 	scannerBufferSize uint64
 
@@ -64,26 +74,102 @@ type Pipeline struct {
 	// Field pathConsumerCount is the number of concurrent and parallel goroutines that will consume paths from a channel in the pipeline.
 	pathConsumerCount uint64
 
-	// Field pathsChannel is the channel containing paths to the files that will be processed.
-	pathsChannel chan string
+	// Field pathsChannel is the channel containing the work items for the files that will be processed.
+	pathsChannel chan WorkItem
 
 	// Field commandChannel is the channel that will signal to start the pipeline.
 	commandChannel chan bool
+
+	// Field walkerCount is the number of concurrent and parallel goroutines that will walk the directories.
+	walkerCount uint64
+
+	// Field sortOrder is the order in which the entries of each directory are traversed.
+	sortOrder SortOrder
+
+	// Field dedupeMode indicates how discovered paths that refer to the same image are recognized.
+	dedupeMode DedupeMode
+
+	// Field canonicalPolicy indicates which path of a duplicate group is processed.
+	canonicalPolicy CanonicalPolicy
+
+	// Field report accumulates the outcomes of the pipeline's run.
+	report *Report
+
+	// Field preScan indicates whether the run begins by counting the files and bytes in scope, so its progress can be reported.
+	preScan bool
+
+	// Field progress tracks how much of the run's work has been processed.
+	progress *Progress
+
+	// Field fileSystem is the source file system that is walked and read, or nil for the operating system's.
+	fileSystem fs.FS
+
+	// Field archives indicates whether discovery descends into zip and tar archives, passing their members into the pipeline.
+	archives bool
+
+	// Field statePath is the path to the state file for incremental runs, or empty to process every file.
+	statePath string
+
+	// Field outputPath is the directory that receives the images with their metadata injected, or empty to not write them.
+	outputPath string
+
+	// Field stateHash indicates whether incremental runs also compare the hash of each file's contents.
+	stateHash bool
+
+	// Field deletedPolicy indicates what an incremental run does with files that were deleted since the last run.
+	deletedPolicy DeletedPolicy
+
+	// Field walkErrorPolicy indicates what discovery does when it encounters an error.
+	walkErrorPolicy WalkErrorPolicy
+
+	// Field skipHiddenDirectories indicates whether discovery skips descending into directories whose names begin with a dot.
+	skipHiddenDirectories bool
+
+	// Field skipHiddenFiles indicates whether discovery skips files whose names begin with a dot.
+	skipHiddenFiles bool
+
+	// Field oneFileSystem indicates whether discovery stays on the file system of each path, as with find -xdev.
+	oneFileSystem bool
+
+	// Field sidecars indicates whether discovery groups sidecar files with their primary files as one work item.
+	sidecars bool
+
+	// Field shard selects the part of the work that this process takes, when the work is split across processes.
+	shard Shard
+
+	// Field geofence selects the work items by where their images were taken.
+	geofence Geofence
+
+	// Field limits is the largest image that is passed to the steps that decode its pixels.
+	limits image.Limits
+
+	// Field sources are the mechanisms that discover work for the pipeline, such as walking directories, which are loaded in turn.
+	sources []Source
+
+	// Field scanning indicates whether the pipeline is a copy that only counts the work in scope, so sources do not watch.
+	scanning bool
+
+	// Field stopChannel is the channel that is closed to signal the pipeline to stop loading paths.
+	stopChannel chan bool
+
+	// Field stopOnce closes the stop channel only once, since the pipeline can be stopped concurrently,
+	// such as by a signal while watching. It is shared by the copies of the pipeline.
+	stopOnce *sync.Once
 }
 
 // Function New is a factory that creates an initialized Pipeline.
-// Parameter path to the directory containing files to process.
+// Parameter sources are the mechanisms that discover the files to process, such as a DirectorySource, which are loaded in turn.
 // Parameter scannerBufferSize is  the number of reusable bytes to use for the directory scanner's work.
 // Parameter pathChanSize is the number of file system paths that will be buffered in a channel in the pipeline.
 // Parameter pathConsumerCount is the number of concurrent and parallel goroutines that will consume paths from a channel in the pipeline.
 // Returns an initialized pipeline or error.
-func New(path string, scannerBufferSize uint64, pathChanSize uint64, pathConsumerCount uint64) (*Pipeline, error) {
+func New(sources []Source, scannerBufferSize uint64, pathChanSize uint64, pathConsumerCount uint64) (*Pipeline, error) {
 	pipeline := &Pipeline{}
 	if pipeline == nil {
 		return nil, errors.New("failed to create an instance of Pipeline")
 	}
 
-	err := pipeline.setPath(path)
+	err := pipeline.setSources(sources)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +189,16 @@ func New(path string, scannerBufferSize uint64, pathChanSize uint64, pathConsume
 		return nil, err
 	}
 
+	err = pipeline.SetWalkerCount(1)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pipeline.SetLimits(image.DefaultLimits)
+	if err != nil {
+		return nil, err
+	}
+
 	err = pipeline.setEndedUtc(Zero())
 	if err != nil {
 		return nil, err
@@ -113,8 +209,8 @@ func New(path string, scannerBufferSize uint64, pathChanSize uint64, pathConsume
 		return nil, err
 	}
 
-	// Create the channel that will provide paths to files for processing.
-	err = pipeline.setPathsChannel(make(chan string, pipeline.PathChanSize()))
+	// Create the channel that will provide the work items for files to process.
+	err = pipeline.setPathsChannel(make(chan WorkItem, pipeline.PathChanSize()))
 	if err != nil {
 		return nil, err
 	}
@@ -125,25 +221,23 @@ func New(path string, scannerBufferSize uint64, pathChanSize uint64, pathConsume
 		return nil, err
 	}
 
-	return pipeline, nil
-}
-
-// Method Path gets the path from the instance.
-func (p Pipeline) Path() string {
-	return p.path
-}
+	err = pipeline.setReport(NewReport())
+	if err != nil {
+		return nil, err
+	}
 
-// Method SetPath sets the value of the path in the instance.
-// If there is an error, an error is returned, otherwise nil.
-func (p *Pipeline) setPath(path string) error {
+	err = pipeline.setProgress(NewProgress())
+	if err != nil {
+		return nil, err
+	}
 
-	if path == "" {
-		return errors.New("the path cannot be empty")
+	// Create the channel that will signal to stop the pipeline.
+	err = pipeline.setStopChannel(make(chan bool))
+	if err != nil {
+		return nil, err
 	}
-	path = CleanStringForPlatform(path)
-	p.path = path
 
-	return nil
+	return pipeline, nil
 }
 
 // Method ScannerBufferSize gets the number of reusable bytes to use for the directory scanner's work.
@@ -154,6 +248,9 @@ func (p Pipeline) ScannerBufferSize() uint64 {
 // Method setScannerBufferSize sets the number of reusable bytes to use for the directory scanner's work.
 // If there is an error, an error is returned, otherwise nil.
 func (p *Pipeline) setScannerBufferSize(scannerBufferSize uint64) error {
+	if scannerBufferSize < uint64(godirwalk.MinimumScratchBufferSize) {
+		return errors.New(fmt.Sprintf("%s%d bytes", "the scanner buffer size must be at least ", godirwalk.MinimumScratchBufferSize))
+	}
 	p.scannerBufferSize = scannerBufferSize
 	return nil
 }
@@ -178,6 +275,9 @@ func (p Pipeline) PathConsumerCount() uint64 {
 // Method setPathConsumerCount sets the number of concurrent and parallel goroutines that will consume paths from a channel in the pipeline.
 // If there is an error, an error is returned, otherwise nil.
 func (p *Pipeline) setPathConsumerCount(pathConsumerCount uint64) error {
+	if pathConsumerCount == 0 {
+		return errors.New("there must be at least one goroutine consuming the paths channel")
+	}
 	p.pathConsumerCount = pathConsumerCount
 	return nil
 }
@@ -232,14 +332,14 @@ func (p *Pipeline) setEndedUtc(endedUtc time.Time) error {
 	return nil
 }
 
-// Method PathsChannel gets the channel containing paths to the files that will be processed.
-func (p Pipeline) PathsChannel() chan string {
+// Method PathsChannel gets the channel containing the work items for the files that will be processed.
+func (p Pipeline) PathsChannel() chan WorkItem {
 	return p.pathsChannel
 }
 
-// Method setPathsChannel sets the channel containing paths to the files that will be processed.
+// Method setPathsChannel sets the channel containing the work items for the files that will be processed.
 // If there is an error, an error is returned, otherwise nil.
-func (p *Pipeline) setPathsChannel(pathsChannel chan string) error {
+func (p *Pipeline) setPathsChannel(pathsChannel chan WorkItem) error {
 	p.pathsChannel = pathsChannel
 	return nil
 }
@@ -256,30 +356,500 @@ func (p *Pipeline) setCommandChannel(commandChannel chan bool) error {
 	return nil
 }
 
+// Method WalkerCount gets the number of concurrent and parallel goroutines that will walk the directories.
+func (p Pipeline) WalkerCount() uint64 {
+	return p.walkerCount
+}
+
+// Method SetWalkerCount sets the number of concurrent and parallel goroutines that will walk the directories.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetWalkerCount(walkerCount uint64) error {
+	if walkerCount == 0 {
+		return errors.New("there must be at least one goroutine walking the directories")
+	}
+	p.walkerCount = walkerCount
+	return nil
+}
+
+// Method SortOrder gets the order in which the entries of each directory are traversed.
+func (p Pipeline) SortOrder() SortOrder {
+	return p.sortOrder
+}
+
+// Method SetSortOrder sets the order in which the entries of each directory are traversed.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetSortOrder(sortOrder SortOrder) error {
+	if sortOrder < Unsorted || sortOrder > Natural {
+		return errors.New("the sort order is not valid")
+	}
+	p.sortOrder = sortOrder
+	return nil
+}
+
+// Method DedupeMode gets how discovered paths that refer to the same image are recognized.
+func (p Pipeline) DedupeMode() DedupeMode {
+	return p.dedupeMode
+}
+
+// Method SetDedupeMode sets how discovered paths that refer to the same image are recognized.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetDedupeMode(dedupeMode DedupeMode) error {
+	if dedupeMode < NoDedupe || dedupeMode > ContentDedupe {
+		return errors.New("the dedupe mode is not valid")
+	}
+	p.dedupeMode = dedupeMode
+	return nil
+}
+
+// Method CanonicalPolicy gets which path of a duplicate group is processed.
+func (p Pipeline) CanonicalPolicy() CanonicalPolicy {
+	return p.canonicalPolicy
+}
+
+// Method SetCanonicalPolicy sets which path of a duplicate group is processed.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetCanonicalPolicy(canonicalPolicy CanonicalPolicy) error {
+	if canonicalPolicy < FirstCanonical || canonicalPolicy > LexicalCanonical {
+		return errors.New("the canonical policy is not valid")
+	}
+	p.canonicalPolicy = canonicalPolicy
+	return nil
+}
+
+// Method Report gets the outcomes of the pipeline's run.
+func (p Pipeline) Report() *Report {
+	return p.report
+}
+
+// Method setReport sets the report that accumulates the outcomes of the pipeline's run.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) setReport(report *Report) error {
+	if report == nil {
+		return errors.New("the report cannot be nil")
+	}
+	p.report = report
+	return nil
+}
+
+// Method PreScan gets whether the run begins by counting the files and bytes in scope, so its progress can be reported.
+func (p Pipeline) PreScan() bool {
+	return p.preScan
+}
+
+// Method SetPreScan sets whether the run begins by counting the files and bytes in scope,
+// so its progress can be reported as the percent complete and the estimated time remaining.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetPreScan(preScan bool) error {
+	p.preScan = preScan
+	return nil
+}
+
+// Method Progress gets how much of the run's work has been processed, which is tracked when there is a pre-scan.
+func (p Pipeline) Progress() *Progress {
+	return p.progress
+}
+
+// Method setProgress sets the tracker of how much of the run's work has been processed.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) setProgress(progress *Progress) error {
+	if progress == nil {
+		return errors.New("the progress cannot be nil")
+	}
+	p.progress = progress
+	return nil
+}
+
+// Method Archives gets whether discovery descends into zip and tar archives, passing their members into the pipeline.
+func (p Pipeline) Archives() bool {
+	return p.archives
+}
+
+// Method SetArchives sets whether discovery descends into zip and tar archives, passing their members into the pipeline.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetArchives(archives bool) error {
+	p.archives = archives
+	return nil
+}
+
+// Method StatePath gets the path to the state file for incremental runs, or empty to process every file.
+func (p Pipeline) StatePath() string {
+	return p.statePath
+}
+
+// Method SetStatePath sets the path to the state file for incremental runs, or empty to process every file.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetStatePath(statePath string) error {
+	if statePath != "" {
+		statePath = CleanStringForPlatform(statePath)
+	}
+	p.statePath = statePath
+	return nil
+}
+
+// Method OutputPath gets the directory that receives the images with their metadata injected, or empty to not write them.
+func (p Pipeline) OutputPath() string {
+	return p.outputPath
+}
+
+// Method SetOutputPath sets the directory that receives the images with their metadata injected,
+// mirroring their paths, or empty to not write them.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetOutputPath(outputPath string) error {
+	if outputPath != "" {
+		outputPath = CleanStringForPlatform(outputPath)
+	}
+	p.outputPath = outputPath
+	return nil
+}
+
+// Method StateHash gets whether incremental runs also compare the hash of each file's contents.
+func (p Pipeline) StateHash() bool {
+	return p.stateHash
+}
+
+// Method SetStateHash sets whether incremental runs also compare the hash of each file's contents,
+// so files whose modification time changed without their contents changing are not processed again.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetStateHash(stateHash bool) error {
+	p.stateHash = stateHash
+	return nil
+}
+
+// Method DeletedPolicy gets what an incremental run does with files that were deleted since the last run.
+func (p Pipeline) DeletedPolicy() DeletedPolicy {
+	return p.deletedPolicy
+}
+
+// Method SetDeletedPolicy sets what an incremental run does with files that were deleted since the last run.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetDeletedPolicy(deletedPolicy DeletedPolicy) error {
+	if deletedPolicy < IgnoreDeleted || deletedPolicy > PruneDeleted {
+		return errors.New("the deleted policy is not valid")
+	}
+	p.deletedPolicy = deletedPolicy
+	return nil
+}
+
+// Method WalkErrorPolicy gets what discovery does when it encounters an error.
+func (p Pipeline) WalkErrorPolicy() WalkErrorPolicy {
+	return p.walkErrorPolicy
+}
+
+// Method SetWalkErrorPolicy sets what discovery does when it encounters an error.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetWalkErrorPolicy(walkErrorPolicy WalkErrorPolicy) error {
+	if walkErrorPolicy < SkipOnWalkError || walkErrorPolicy > HaltOnWalkError {
+		return errors.New("the walk error policy is not valid")
+	}
+	p.walkErrorPolicy = walkErrorPolicy
+	return nil
+}
+
+// Method SkipHiddenDirectories gets whether discovery skips descending into directories whose names begin with a dot.
+func (p Pipeline) SkipHiddenDirectories() bool {
+	return p.skipHiddenDirectories
+}
+
+// Method SetSkipHiddenDirectories sets whether discovery skips descending into directories whose names begin with a dot.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetSkipHiddenDirectories(skipHiddenDirectories bool) error {
+	p.skipHiddenDirectories = skipHiddenDirectories
+	return nil
+}
+
+// Method SkipHiddenFiles gets whether discovery skips files whose names begin with a dot.
+func (p Pipeline) SkipHiddenFiles() bool {
+	return p.skipHiddenFiles
+}
+
+// Method SetSkipHiddenFiles sets whether discovery skips files whose names begin with a dot.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetSkipHiddenFiles(skipHiddenFiles bool) error {
+	p.skipHiddenFiles = skipHiddenFiles
+	return nil
+}
+
+// Method OneFileSystem gets whether discovery stays on the file system of each path, as with find -xdev.
+func (p Pipeline) OneFileSystem() bool {
+	return p.oneFileSystem
+}
+
+// Method SetOneFileSystem sets whether discovery stays on the file system of each path, as with find -xdev,
+// so it does not descend into mount points such as network mounts beneath the paths.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetOneFileSystem(oneFileSystem bool) error {
+	p.oneFileSystem = oneFileSystem
+	return nil
+}
+
+// Method Shard gets the part of the work that this process takes, when the work is split across processes.
+func (p Pipeline) Shard() Shard {
+	return p.shard
+}
+
+// Method SetShard sets the part of the work that this process takes, when the work is split across processes.
+// Duplicates are only recognized within a shard, because duplicate files can belong to different shards.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetShard(shard Shard) error {
+	if shard.IsSharded() && (shard.Index < 1 || shard.Index > shard.Count) {
+		return errors.New(fmt.Sprintf("the shard's index must be from 1 to %d", shard.Count))
+	}
+	p.shard = shard
+	return nil
+}
+
+// Method Geofence gets how the work items are selected by where their images were taken.
+func (p Pipeline) Geofence() Geofence {
+	return p.geofence
+}
+
+// Method SetGeofence sets how the work items are selected by where their images were taken.
+// The geofence reads each work item's files, so it is applied after the cheaper steps have dropped the work items they skip.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetGeofence(geofence Geofence) error {
+	p.geofence = geofence
+	return nil
+}
+
+// Method Limits gets the largest image that is passed to the steps that decode its pixels.
+func (p Pipeline) Limits() image.Limits {
+	return p.limits
+}
+
+// Method SetLimits sets the largest image that is passed to the steps that decode its pixels, where a zero limit
+// is not enforced. Each image's header is checked, and the images that are larger are dropped and reported.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetLimits(limits image.Limits) error {
+	p.limits = limits
+	return nil
+}
+
+// Method Sidecars gets whether discovery groups sidecar files with their primary files as one work item.
+func (p Pipeline) Sidecars() bool {
+	return p.sidecars
+}
+
+// Method SetSidecars sets whether discovery groups sidecar files, such as IMG_0001.XMP and IMG_0001.json,
+// with their primary file, such as IMG_0001.JPG, and passes them through the pipeline as one work item.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) SetSidecars(sidecars bool) error {
+	p.sidecars = sidecars
+	return nil
+}
+
+// Method StopChannel gets the channel that is closed to signal the pipeline to stop loading paths.
+func (p Pipeline) StopChannel() chan bool {
+	return p.stopChannel
+}
+
+// Method setStopChannel sets the channel that is closed to signal the pipeline to stop loading paths.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) setStopChannel(stopChannel chan bool) error {
+	p.stopChannel = stopChannel
+	p.stopOnce = &sync.Once{}
+	return nil
+}
+
+// Method isScanning determines whether the pipeline is a copy that only counts the work in scope.
+func (p Pipeline) isScanning() bool {
+	return p.scanning
+}
+
+// Method isStopping determines whether the pipeline has been signaled to stop.
+func (p Pipeline) isStopping() bool {
+	select {
+	case <-p.StopChannel():
+		return true
+	default:
+		return false
+	}
+}
+
 // Method Start initiates processing in the pipeline.
 func (p Pipeline) Start() error {
 
-	var wg sync.WaitGroup
+	var (
+		wg      sync.WaitGroup
+		loadErr error
+	)
+
+	if len(p.Sources()) == 0 {
+		return errors.New("there must be a source of the files to process, such as a directory or a manifest")
+	}
+
+	// An incremental run remembers the files that were processed by the previous runs.
+	var state *stateDatabase
+	if p.StatePath() != "" {
+		var err error
+		state, err = loadStateDatabase(p.StatePath())
+		if err != nil {
+			return err
+		}
+	}
 
-	// Recursively scan the path for files to process...
-	go p.loadPathsToChannel(p.Path(), p.PathsChannel(), p.CommandChannel(), &wg)
+	// Count the work in scope, so the progress can be reported while the work is processed.
+	if p.PreScan() {
+		if !p.isRescannable() {
+			return errors.New("a pre-scan cannot be used with a manifest read from stdin, which can only be read once, or with an HTTP listing, which would be fetched twice")
+		}
+
+		totals, err := p.Scan()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Scan: %d files, %s in scope\n", totals.Files, formatBytes(totals.Bytes))
+
+		p.Progress().start(totals)
+		doneChannel := make(chan bool)
+		defer func() {
+			close(doneChannel)
+			fmt.Fprintf(os.Stderr, "\n%s\n", p.Progress())
+		}()
+		go p.Progress().reportEvery(os.Stderr, kProgressInterval, doneChannel)
+	}
+
+	// The discovered work items pass through the optional steps, which drop the work items that are not processed.
+	discoveredChannel := p.startFilterSteps(state, p.PathsChannel(), &wg)
+
+	// Load the manifest and recursively scan the paths for files to process...
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		loadErr = p.loadPathsToChannel(discoveredChannel, p.CommandChannel())
+	}()
 
 	// Start the loading of paths into the paths channel...
 	p.CommandChannel() <- true
 
-	// Spin off a goroutine to process each file in the channel
-	for path := range p.PathsChannel() {
+	// Spin off the goroutines that will process each file in the channel.
+	for i := uint64(0); i < p.PathConsumerCount(); i++ {
+		wg.Add(1)
+		go p.consumePaths(p.PathsChannel(), state, &wg)
+	}
+
+	// Wait for all goroutines to complete.
+	wg.Wait()
+
+	if state != nil {
+		err := p.finishState(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	return loadErr
+}
+
+// Method startFilterSteps starts the optional steps that drop the discovered work items that are not processed,
+// such as the duplicates and the unchanged files, each feeding the channel of the step after it.
+// A run and its pre-scan start the same steps, so the pre-scan counts the work items that the run processes.
+// Parameter state, when it is not nil, drops the files that are unchanged since the last run.
+// Parameter pathsChannel is the channel that receives the work items that pass every step.
+// Parameter wg is incremented for each step that is started, and decremented when the step finishes.
+// Returns the channel that receives the discovered work items, which is closed to finish the steps.
+func (p Pipeline) startFilterSteps(state *stateDatabase, pathsChannel chan<- WorkItem, wg *sync.WaitGroup) chan<- WorkItem {
+	discoveredChannel := pathsChannel
+
+	// When geofenced, the images taken outside of the geofence are dropped.
+	if p.Geofence().IsGeofenced() {
+		inChannel, outChannel := make(chan WorkItem, p.PathChanSize()), discoveredChannel
+		wg.Add(1)
 		go func() {
-			fmt.Printf("\nProcessing: %s", path)
+			defer wg.Done()
+			p.geofencePaths(inChannel, outChannel)
+		}()
+		discoveredChannel = inChannel
+	}
 
-			// Do something... Pass the something along to the next step.
+	// When limited, the images that are larger than the limits are dropped before their pixels can be decoded.
+	if p.Limits().IsLimited() {
+		inChannel, outChannel := make(chan WorkItem, p.PathChanSize()), discoveredChannel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.limitPaths(inChannel, outChannel)
 		}()
+		discoveredChannel = inChannel
 	}
 
-	// Wait for all goroutines to complete.
-	wg.Wait()
+	// When incremental, the unchanged files are dropped.
+	if state != nil {
+		inChannel, outChannel := make(chan WorkItem, p.PathChanSize()), discoveredChannel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.filterUnchangedPaths(state, inChannel, outChannel)
+		}()
+		discoveredChannel = inChannel
+	}
 
-	return nil
+	// Each work item is described once, after the shard and dedupe steps, so only the files that are kept are examined.
+	{
+		inChannel, outChannel := make(chan WorkItem, p.PathChanSize()), discoveredChannel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.describePaths(inChannel, outChannel)
+		}()
+		discoveredChannel = inChannel
+	}
+
+	// When deduplicating, the duplicates are dropped.
+	if p.DedupeMode() != NoDedupe {
+		inChannel, outChannel := make(chan WorkItem, p.PathChanSize()), discoveredChannel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.dedupePaths(inChannel, outChannel)
+		}()
+		discoveredChannel = inChannel
+	}
+
+	// When sharded, the work items that belong to other processes are dropped.
+	if p.Shard().IsSharded() {
+		inChannel, outChannel := make(chan WorkItem, p.PathChanSize()), discoveredChannel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.shardPaths(inChannel, outChannel)
+		}()
+		discoveredChannel = inChannel
+	}
+
+	return discoveredChannel
+}
+
+// Method consumePaths processes each work item in the paths channel until the channel has been closed.
+// Parameter pathsChannel is the unidirectional channel that is feeding the work items to the pipeline.
+// Parameter state, when it is not nil, records each file that has been processed.
+func (p Pipeline) consumePaths(pathsChannel <-chan WorkItem, state *stateDatabase, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for item := range pathsChannel {
+		if p.OutputPath() != "" {
+			p.injectItemMetadata(&item)
+		}
+
+		fmt.Printf("\nProcessing: %s", item.Path)
+		for _, sidecar := range item.Sidecars {
+			fmt.Printf("\n  Sidecar: %s", sidecar)
+		}
+		for _, stepErr := range item.Errors {
+			fmt.Printf("\n  Error: %s", stepErr)
+		}
+
+		// Do something... Pass the something along to the next step.
+
+		if state != nil {
+			for _, path := range item.Paths() {
+				state.markProcessed(path)
+			}
+		}
+
+		if p.PreScan() {
+			p.Progress().add(p.itemSize(item))
+		}
+	}
 }
 
 // Method Abort abends processing in the pipeline.
@@ -289,7 +859,11 @@ func (p Pipeline) Abort() error {
 }
 
 // Method Stop terminates processing after all steps have been completed.
+// Loading of new paths ceases, and the paths that have already been queued are processed.
+// It is safe to call concurrently and more than once.
 func (p Pipeline) Stop() error {
-	// TODO
+	p.stopOnce.Do(func() {
+		close(p.StopChannel())
+	})
 	return nil
 }
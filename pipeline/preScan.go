@@ -0,0 +1,90 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"sync"
+)
+
+// Method Scan counts the files and bytes in scope for a run, without processing them.
+// It discovers the files with the same sources as the run, and passes them through the same steps that drop work items,
+// such as the shard, dedupe, incremental, limits and geofence steps, so it counts the work items that the run processes.
+// Those steps read the files' contents when they need to, such as to hash them or to read their headers and locations.
+// The scan does not watch for new files, and it does not record anything in the run report.
+// Returns the totals, or the error that halted the discovery.
+func (p Pipeline) Scan() (ScanTotals, error) {
+
+	// The scan discovers the files using a copy of the pipeline, which stops when the pipeline is stopped.
+	scanner := p
+	scanner.scanning = true
+	scanner.report = NewReport()
+	scanner.commandChannel = make(chan bool, 1)
+	scanner.commandChannel <- true
+
+	var state *stateDatabase
+	if p.StatePath() != "" {
+		var err error
+		state, err = loadStateDatabase(p.StatePath())
+		if err != nil {
+			return ScanTotals{}, err
+		}
+	}
+
+	var (
+		totals       ScanTotals
+		loadErr      error
+		wg           sync.WaitGroup
+		itemsChannel = make(chan WorkItem, p.PathChanSize())
+	)
+
+	discoveredChannel := scanner.startFilterSteps(state, itemsChannel, &wg)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		loadErr = scanner.loadPathsToChannel(discoveredChannel, scanner.CommandChannel())
+	}()
+
+	for item := range itemsChannel {
+		totals.Files++
+		totals.Bytes += p.itemSize(item)
+	}
+
+	wg.Wait()
+
+	return totals, loadErr
+}
+
+// Method isRescannable determines whether each of the pipeline's sources can be loaded by a pre-scan and again by the run.
+// A manifest that is read from stdin can only be read once, and an HTTP listing would be fetched twice.
+func (p Pipeline) isRescannable() bool {
+	for _, source := range p.Sources() {
+		switch source := source.(type) {
+		case ManifestSource:
+			if source.Path == kStdinManifest {
+				return false
+			}
+		case HTTPListingSource:
+			return false
+		}
+	}
+	return true
+}
+
+// Method itemSize gets the total size of a work item's files, including its sidecar files.
+// The size of a remote file is unknown, so it is not counted.
+// Parameter item is the work item, which has been described.
+func (p Pipeline) itemSize(item WorkItem) uint64 {
+	size := uint64(item.Size)
+	for _, path := range item.Sidecars {
+		info, err := p.statPath(path)
+		if err == nil {
+			size += uint64(info.Size())
+		}
+	}
+	return size
+}
@@ -0,0 +1,106 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/abitofhelp/pipeline/image"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Function TestScanAppliesFilterSteps verifies that a scan counts only the work items that the run processes,
+// without the duplicates or the images larger than the limits, and that it does not record them in the run's report.
+func TestScanAppliesFilterSteps(t *testing.T) {
+
+	// An 8-bit RGBA PNG stream that ends after its IHDR chunk, which declares an image wider than the default limits.
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], 50000)
+	binary.BigEndian.PutUint32(ihdr[8:], 1)
+	ihdr[12], ihdr[13] = 8, 6
+	var oversized bytes.Buffer
+	oversized.WriteString(image.PNGSignature)
+	binary.Write(&oversized, binary.BigEndian, uint32(len(ihdr)-4))
+	oversized.Write(ihdr)
+	binary.Write(&oversized, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+
+	root := t.TempDir()
+	files := map[string][]byte{
+		"a.jpg":   []byte("same"),
+		"b.jpg":   []byte("same"),
+		"c.txt":   []byte("other"),
+		"big.png": oversized.Bytes(),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		dedupe DedupeMode
+		limits image.Limits
+		totals ScanTotals
+	}{
+		{"no filters", NoDedupe, image.Limits{}, ScanTotals{Files: 4, Bytes: uint64(13 + oversized.Len())}},
+		{"dedupe", ContentDedupe, image.Limits{}, ScanTotals{Files: 3, Bytes: uint64(9 + oversized.Len())}},
+		{"limits", NoDedupe, image.DefaultLimits, ScanTotals{Files: 3, Bytes: 13}},
+		{"dedupe and limits", ContentDedupe, image.DefaultLimits, ScanTotals{Files: 2, Bytes: 9}},
+	}
+
+	for _, test := range tests {
+		p, err := New([]Source{DirectorySource{Path: root}}, 4096, 8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetDedupeMode(test.dedupe); err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetLimits(test.limits); err != nil {
+			t.Fatal(err)
+		}
+
+		totals, err := p.Scan()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if totals != test.totals {
+			t.Errorf("%s: the scan counted %+v, want %+v", test.name, totals, test.totals)
+		}
+		if len(p.Report().DuplicateGroups()) != 0 {
+			t.Errorf("%s: the scan recorded duplicates in the run's report", test.name)
+		}
+	}
+}
+
+// Function TestIsRescannable verifies that a pre-scan is refused for the sources that can only be loaded once.
+func TestIsRescannable(t *testing.T) {
+	tests := []struct {
+		sources     []Source
+		rescannable bool
+	}{
+		{[]Source{DirectorySource{Path: "/photos"}, ManifestSource{Path: "/photos.txt"}}, true},
+		{[]Source{WatchSource{Paths: []string{"/photos"}}, ArchiveSource{Path: "/photos.zip"}}, true},
+		{[]Source{DirectorySource{Path: "/photos"}, ManifestSource{Path: kStdinManifest}}, false},
+		{[]Source{HTTPListingSource{URL: "http://example.com/photos/"}}, false},
+	}
+
+	for _, test := range tests {
+		p, err := New(test.sources, 4096, 8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rescannable := p.isRescannable(); rescannable != test.rescannable {
+			t.Errorf("the sources %v are rescannable %v, want %v", test.sources, rescannable, test.rescannable)
+		}
+	}
+}
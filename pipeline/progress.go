@@ -0,0 +1,177 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// The interval between the progress reports while the pipeline is running.
+const kProgressInterval = 5 * time.Second
+
+// Type ScanTotals is the number of files and bytes that are counted by a pre-scan or have been processed.
+type ScanTotals struct {
+	// Field Files is the number of work items.
+	Files uint64
+
+	// Field Bytes is the total size of the work items' files, including their sidecar files.
+	Bytes uint64
+}
+
+// Type Progress tracks how much of a run's work has been processed, compared to the totals from its pre-scan.
+// It is safe for concurrent use by the pipeline's steps.
+type Progress struct {
+	// Field mutex guards the other fields.
+	mutex sync.Mutex
+
+	// Field totals are the files and bytes in scope, as counted by the pre-scan.
+	totals ScanTotals
+
+	// Field processed are the files and bytes that have been processed.
+	processed ScanTotals
+
+	// Field startedUtc is the date/time in UTC when processing began.
+	startedUtc time.Time
+}
+
+// Function NewProgress is a factory that creates an empty Progress.
+func NewProgress() *Progress {
+	return &Progress{}
+}
+
+// Method start begins tracking the progress of a run.
+// Parameter totals are the files and bytes in scope, as counted by the pre-scan.
+func (p *Progress) start(totals ScanTotals) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.totals = totals
+	p.processed = ScanTotals{}
+	p.startedUtc = time.Now().UTC()
+}
+
+// Method add records that a work item has been processed.
+// Parameter bytes is the total size of the work item's files.
+func (p *Progress) add(bytes uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.processed.Files++
+	p.processed.Bytes += bytes
+}
+
+// Method Totals gets the files and bytes in scope, as counted by the pre-scan.
+func (p *Progress) Totals() ScanTotals {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.totals
+}
+
+// Method Processed gets the files and bytes that have been processed.
+func (p *Progress) Processed() ScanTotals {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.processed
+}
+
+// Method Fraction gets the fraction of the work that has been processed, from 0 to 1.
+// It is measured in bytes when the files in scope have a size, otherwise in files.
+func (p *Progress) Fraction() float64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.fraction()
+}
+
+// Method fraction gets the fraction of the work that has been processed, while the mutex is held.
+func (p *Progress) fraction() float64 {
+	var fraction float64
+	switch {
+	case p.totals.Bytes > 0:
+		fraction = float64(p.processed.Bytes) / float64(p.totals.Bytes)
+	case p.totals.Files > 0:
+		fraction = float64(p.processed.Files) / float64(p.totals.Files)
+	default:
+		return 1
+	}
+
+	// Files that are created after the pre-scan can carry the processed work past the totals.
+	if fraction > 1 {
+		return 1
+	}
+	return fraction
+}
+
+// Method ETA estimates the time remaining until the work has been processed, from the rate of progress so far.
+// Returns the estimate, or false when nothing has been processed yet to estimate from.
+func (p *Progress) ETA() (time.Duration, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.eta()
+}
+
+// Method eta estimates the time remaining, while the mutex is held.
+func (p *Progress) eta() (time.Duration, bool) {
+	fraction := p.fraction()
+	if fraction <= 0 || p.startedUtc.IsZero() {
+		return 0, false
+	}
+
+	elapsed := time.Now().UTC().Sub(p.startedUtc)
+	return time.Duration(float64(elapsed) * (1 - fraction) / fraction), true
+}
+
+// Method String gets a description of the progress, such as "Progress: 42.0% (420/1000 files, 1.2/3.0 GB), ETA 1m30s".
+func (p *Progress) String() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	eta := "unknown"
+	if remaining, ok := p.eta(); ok {
+		eta = remaining.Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("Progress: %.1f%% (%d/%d files, %s/%s), ETA %s",
+		100*p.fraction(), p.processed.Files, p.totals.Files,
+		formatBytes(p.processed.Bytes), formatBytes(p.totals.Bytes), eta)
+}
+
+// Method reportEvery writes the progress at each interval, until the done channel is closed.
+// Parameter w is the destination for the reports.
+// Parameter interval is the time between the reports.
+// Parameter doneChannel is closed when the run has finished.
+func (p *Progress) reportEvery(w io.Writer, interval time.Duration, doneChannel <-chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fmt.Fprintf(w, "\n%s\n", p)
+		case <-doneChannel:
+			return
+		}
+	}
+}
+
+// Function formatBytes formats a number of bytes with a decimal unit, such as "1.5 GB".
+func formatBytes(bytes uint64) string {
+	const kUnit = 1000
+	if bytes < kUnit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	divisor, exponent := uint64(kUnit), 0
+	for n := bytes / kUnit; n >= kUnit; n /= kUnit {
+		divisor *= kUnit
+		exponent++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(divisor), "kMGTPE"[exponent])
+}
@@ -0,0 +1,315 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"fmt"
+	"github.com/abitofhelp/pipeline/image"
+	"io"
+	"sync"
+)
+
+// Type DuplicateGroup is a set of paths that refer to the same image, of which only the canonical path is processed.
+type DuplicateGroup struct {
+	// Field Key identifies the group, as a device and inode or a content hash.
+	Key string
+
+	// Field Canonical is the path that was processed.
+	Canonical string
+
+	// Field Duplicates are the paths that were skipped, in the order they were discovered,
+	// except that a path that is replaced as the canonical path follows the paths before its replacement.
+	Duplicates []string
+}
+
+// Type OversizedImage is an image that was skipped, because it is larger than the pipeline's limits.
+type OversizedImage struct {
+	// Field Path is the path of the image.
+	Path string
+
+	// Field Err describes the limit that the image exceeds.
+	Err *image.LimitError
+}
+
+// Type Report accumulates the outcomes of a pipeline's run.
+// It is safe for concurrent use by the pipeline's steps.
+type Report struct {
+	// Field mutex guards the other fields.
+	mutex sync.Mutex
+
+	// Field duplicateGroups are the sets of paths that refer to the same image.
+	duplicateGroups []DuplicateGroup
+
+	// Field unchanged is the number of files that were skipped, because they are unchanged since the last run.
+	unchanged uint64
+
+	// Field deleted are the paths of the files that were deleted since the last run.
+	deleted []string
+
+	// Field walkErrors are the errors that were encountered while discovering files.
+	walkErrors []WalkError
+
+	// Field geofenced is the number of files that were skipped, because the geofence dropped them.
+	geofenced uint64
+
+	// Field oversized are the images that were skipped, because they are larger than the pipeline's limits.
+	oversized []OversizedImage
+}
+
+// Function NewReport is a factory that creates an initialized, empty Report.
+// Returns an initialized instance.
+func NewReport() *Report {
+	return &Report{}
+}
+
+// Method addDuplicateGroup records a set of paths that refer to the same image.
+func (r *Report) addDuplicateGroup(group DuplicateGroup) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.duplicateGroups = append(r.duplicateGroups, group)
+}
+
+// Method DuplicateGroups gets the sets of paths that refer to the same image, in the order they were resolved.
+func (r *Report) DuplicateGroups() []DuplicateGroup {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]DuplicateGroup(nil), r.duplicateGroups...)
+}
+
+// Method addUnchanged records that a file was skipped, because it is unchanged since the last run.
+func (r *Report) addUnchanged() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.unchanged++
+}
+
+// Method Unchanged gets the number of files that were skipped, because they are unchanged since the last run.
+func (r *Report) Unchanged() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.unchanged
+}
+
+// Method addGeofenced records that a file was skipped, because the geofence dropped it.
+func (r *Report) addGeofenced() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.geofenced++
+}
+
+// Method Geofenced gets the number of files that were skipped, because the geofence dropped them.
+func (r *Report) Geofenced() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.geofenced
+}
+
+// Method addOversized records an image that was skipped, because it is larger than the pipeline's limits.
+func (r *Report) addOversized(path string, limitErr *image.LimitError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.oversized = append(r.oversized, OversizedImage{Path: path, Err: limitErr})
+}
+
+// Method Oversized gets the images that were skipped, because they are larger than the pipeline's limits.
+func (r *Report) Oversized() []OversizedImage {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]OversizedImage(nil), r.oversized...)
+}
+
+// Method addDeleted records the path of a file that was deleted since the last run.
+func (r *Report) addDeleted(path string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.deleted = append(r.deleted, path)
+}
+
+// Method Deleted gets the paths of the files that were deleted since the last run.
+func (r *Report) Deleted() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]string(nil), r.deleted...)
+}
+
+// Method addWalkError records an error that was encountered while discovering files.
+func (r *Report) addWalkError(walkError WalkError) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.walkErrors = append(r.walkErrors, walkError)
+}
+
+// Method WalkErrors gets the errors that were encountered while discovering files, in the order they occurred.
+func (r *Report) WalkErrors() []WalkError {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]WalkError(nil), r.walkErrors...)
+}
+
+// Method WalkErrorsByKind gets the errors that were encountered while discovering files, grouped by their kind.
+func (r *Report) WalkErrorsByKind() map[WalkErrorKind][]WalkError {
+	groups := make(map[WalkErrorKind][]WalkError)
+	for _, walkError := range r.WalkErrors() {
+		groups[walkError.Kind] = append(groups[walkError.Kind], walkError)
+	}
+	return groups
+}
+
+// Method Write writes a human-readable summary of the report.
+// Parameter writer receives the summary.
+// Returns nil if there are no errors.
+func (r *Report) Write(writer io.Writer) error {
+	err := r.writeWalkErrors(writer)
+	if err != nil {
+		return err
+	}
+
+	err = r.writeIncremental(writer)
+	if err != nil {
+		return err
+	}
+
+	if geofenced := r.Geofenced(); geofenced > 0 {
+		_, err = fmt.Fprintf(writer, "\nGeofenced: %d files skipped by the geofence\n", geofenced)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.writeOversized(writer)
+	if err != nil {
+		return err
+	}
+
+	return r.writeDuplicates(writer)
+}
+
+// Method writeOversized writes the summary of the images that were skipped, because they are larger than the limits.
+func (r *Report) writeOversized(writer io.Writer) error {
+	oversized := r.Oversized()
+	if len(oversized) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(writer, "\nOversized: %d images skipped by the limits\n", len(oversized))
+	if err != nil {
+		return err
+	}
+	for _, skipped := range oversized {
+		_, err = fmt.Fprintf(writer, "  %s: %v\n", skipped.Path, skipped.Err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Method writeWalkErrors writes the summary of the errors that were encountered while discovering files.
+func (r *Report) writeWalkErrors(writer io.Writer) error {
+	walkErrors := r.WalkErrors()
+	if len(walkErrors) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(writer, "\nWalk errors: %d\n", len(walkErrors))
+	if err != nil {
+		return err
+	}
+
+	groups := r.WalkErrorsByKind()
+	for kind := PermissionDenied; kind <= OtherFailure; kind++ {
+		if len(groups[kind]) == 0 {
+			continue
+		}
+
+		_, err = fmt.Fprintf(writer, "  %s: %d\n", kind, len(groups[kind]))
+		if err != nil {
+			return err
+		}
+		for _, walkError := range groups[kind] {
+			_, err = fmt.Fprintf(writer, "    %s: %v\n", walkError.Path, walkError.Err)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Method writeIncremental writes the summary of the files that were unchanged or deleted since the last run.
+func (r *Report) writeIncremental(writer io.Writer) error {
+	if unchanged := r.Unchanged(); unchanged > 0 {
+		_, err := fmt.Fprintf(writer, "\nUnchanged: %d files skipped since the last run\n", unchanged)
+		if err != nil {
+			return err
+		}
+	}
+
+	deleted := r.Deleted()
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(writer, "\nDeleted: %d files since the last run\n", len(deleted))
+	if err != nil {
+		return err
+	}
+	for _, path := range deleted {
+		_, err = fmt.Fprintf(writer, "  %s\n", path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Method writeDuplicates writes the summary of the duplicate groups.
+func (r *Report) writeDuplicates(writer io.Writer) error {
+	groups := r.DuplicateGroups()
+	if len(groups) == 0 {
+		return nil
+	}
+
+	duplicates := 0
+	for _, group := range groups {
+		duplicates += len(group.Duplicates)
+	}
+
+	_, err := fmt.Fprintf(writer, "\nDuplicates: %d paths skipped in %d groups\n", duplicates, len(groups))
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		_, err = fmt.Fprintf(writer, "  %s (%s)\n", group.Canonical, group.Key)
+		if err != nil {
+			return err
+		}
+		for _, duplicate := range group.Duplicates {
+			_, err = fmt.Fprintf(writer, "    = %s\n", duplicate)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Type Shard selects the part of the work that one of several processes takes, such as shard 2 of 4.
+// Each file belongs to exactly one shard, determined by the hash of its path relative to the root where it was found,
+// so processes sharing a network mount can split a tree without coordinating, even when it is mounted at different paths.
+type Shard struct {
+	// Field Index is the shard that this process takes, from 1 to Count.
+	Index uint64
+
+	// Field Count is the number of shards, or 0 when the work is not sharded.
+	Count uint64
+}
+
+// Function ParseShard converts a shard in the form "i/n", such as "2/4", to a Shard.
+// Parameter text is the shard, or empty when the work is not sharded.
+// Returns the Shard or error.
+func ParseShard(text string) (Shard, error) {
+	if text == "" {
+		return Shard{}, nil
+	}
+
+	parts := strings.Split(text, "/")
+	if len(parts) == 2 {
+		index, indexErr := strconv.ParseUint(parts[0], 10, 64)
+		count, countErr := strconv.ParseUint(parts[1], 10, 64)
+		if indexErr == nil && countErr == nil && index >= 1 && index <= count {
+			return Shard{Index: index, Count: count}, nil
+		}
+	}
+
+	return Shard{}, errors.New(fmt.Sprintf("%s%s", "the shard must be i/n, where i is from 1 to n, not ", text))
+}
+
+// Method String gets the shard in the form "i/n", or empty when the work is not sharded.
+func (s Shard) String() string {
+	if s.Count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+// Method IsSharded determines whether the work is split into shards.
+func (s Shard) IsSharded() bool {
+	return s.Count > 0
+}
+
+// Method contains determines whether a key belongs to the shard.
+// Parameter key is the shard key of a work item.
+func (s Shard) contains(key string) bool {
+	if !s.IsSharded() {
+		return true
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return hash.Sum64()%s.Count == s.Index-1
+}
+
+// Pass only the work items that belong to the pipeline's shard.
+// Parameter inChannel is the unidirectional channel of discovered work items.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+func (p Pipeline) shardPaths(inChannel <-chan WorkItem, pathsChannel chan<- WorkItem) {
+	defer close(pathsChannel)
+
+	for item := range inChannel {
+		if p.inShard(item) {
+			pathsChannel <- item
+		}
+	}
+}
+
+// Method inShard determines whether a work item belongs to the pipeline's shard.
+// A work item's sidecar files belong to the shard of its primary file.
+// Parameter item is the work item.
+func (p Pipeline) inShard(item WorkItem) bool {
+	return p.Shard().contains(p.shardKey(item.Path))
+}
+
+// Method shardKey gets the key that determines a file's shard: its slash-separated path relative to the deepest of the
+// directories walked by the pipeline's sources that contains it, or the path itself when it is listed in a manifest
+// or is remote.
+// A member within an archive is keyed by its archive's relative path and its name.
+// Parameter path is the path to the file.
+func (p Pipeline) shardKey(path string) string {
+	if IsRemotePath(path) {
+		return path
+	}
+
+	if archive, name, ok := SplitArchiveMemberPath(path); ok {
+		return p.shardKey(archive) + kArchiveSeparator + name
+	}
+
+	key, depth := path, -1
+	for _, root := range p.roots() {
+		relative, err := filepath.Rel(root, path)
+		if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(root) > depth {
+			key, depth = relative, len(root)
+		}
+	}
+
+	return filepath.ToSlash(key)
+}
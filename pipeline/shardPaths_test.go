@@ -0,0 +1,112 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// Function TestParseShard verifies the accepted and rejected forms of a shard.
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		text  string
+		shard Shard
+		fails bool
+	}{
+		{"", Shard{}, false},
+		{"1/1", Shard{Index: 1, Count: 1}, false},
+		{"2/4", Shard{Index: 2, Count: 4}, false},
+		{"4/4", Shard{Index: 4, Count: 4}, false},
+		{"0/4", Shard{}, true},
+		{"5/4", Shard{}, true},
+		{"2", Shard{}, true},
+		{"2/4/8", Shard{}, true},
+		{"-1/4", Shard{}, true},
+		{"a/b", Shard{}, true},
+		{"1/0", Shard{}, true},
+	}
+
+	for _, test := range tests {
+		shard, err := ParseShard(test.text)
+		if (err != nil) != test.fails || shard != test.shard {
+			t.Errorf("ParseShard(%q) = %v, %v", test.text, shard, err)
+		}
+		if err == nil && shard.String() != test.text {
+			t.Errorf("ParseShard(%q).String() = %q", test.text, shard.String())
+		}
+	}
+}
+
+// Function TestShardContains verifies that each key belongs to exactly one of the shards,
+// and that every shard receives some of the keys.
+func TestShardContains(t *testing.T) {
+	for _, count := range []uint64{1, 2, 3, 7} {
+		sizes := make([]int, count)
+		for k := 0; k < 1000; k++ {
+			key := fmt.Sprintf("photos/%04d/IMG_%04d.JPG", k/100, k)
+
+			owners := 0
+			for index := uint64(1); index <= count; index++ {
+				if (Shard{Index: index, Count: count}).contains(key) {
+					owners++
+					sizes[index-1]++
+				}
+			}
+			if owners != 1 {
+				t.Fatalf("the key %q belongs to %d of %d shards", key, owners, count)
+			}
+		}
+
+		for index, size := range sizes {
+			if size == 0 {
+				t.Errorf("shard %d/%d received none of the keys", index+1, count)
+			}
+		}
+	}
+
+	if !(Shard{}).contains("any") {
+		t.Error("the work that is not sharded must contain every key")
+	}
+}
+
+// Function TestShardKey verifies that a file's key is relative to the deepest root that contains it,
+// so processes that mount a tree at different paths agree on its shards.
+func TestShardKey(t *testing.T) {
+	tests := []struct {
+		roots []string
+		path  string
+		key   string
+	}{
+		{[]string{"/mnt/a"}, "/mnt/a/2018/IMG_1.JPG", "2018/IMG_1.JPG"},
+		{[]string{"/Volumes/share"}, "/Volumes/share/2018/IMG_1.JPG", "2018/IMG_1.JPG"},
+		{[]string{"/mnt/a", "/mnt/a/2018"}, "/mnt/a/2018/IMG_1.JPG", "IMG_1.JPG"},
+		{[]string{"/mnt/a"}, "/mnt/ab/IMG_1.JPG", "/mnt/ab/IMG_1.JPG"},
+		{[]string{"/mnt/a"}, "/mnt/a/photos.zip" + kArchiveSeparator + "x/IMG_1.JPG", "photos.zip" + kArchiveSeparator + "x/IMG_1.JPG"},
+		{[]string{"/mnt/a"}, "https://example.com/IMG_1.JPG", "https://example.com/IMG_1.JPG"},
+	}
+
+	for _, test := range tests {
+		var sources []Source
+		for _, root := range test.roots {
+			sources = append(sources, DirectorySource{Path: filepath.FromSlash(root)})
+		}
+		p, err := New(sources, 4096, 8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := test.path
+		if !IsRemotePath(path) {
+			path = filepath.FromSlash(path)
+		}
+		if key := p.shardKey(path); key != filepath.ToSlash(test.key) {
+			t.Errorf("shardKey(%q) with the roots %v = %q, want %q", test.path, test.roots, key, test.key)
+		}
+	}
+}
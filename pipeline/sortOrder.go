@@ -0,0 +1,113 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Type SortOrder indicates the order in which the entries of each directory are traversed.
+type SortOrder int
+
+// Constants for the values of a SortOrder.
+const (
+	// Unsorted traverses the entries in the order the file system returns them, which is the fastest.
+	Unsorted SortOrder = iota
+
+	// Lexical traverses the entries in byte-wise order of their names.
+	Lexical
+
+	// Natural traverses the entries in name order, comparing runs of digits by their numeric value,
+	// so that "img2.png" precedes "img10.png".
+	Natural
+)
+
+// Function ParseSortOrder converts the name of a sort order to a SortOrder.
+// Parameter name is one of "none", "lexical" or "natural".
+// Returns the SortOrder or error.
+func ParseSortOrder(name string) (SortOrder, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return Unsorted, nil
+	case "lexical":
+		return Lexical, nil
+	case "natural":
+		return Natural, nil
+	default:
+		return Unsorted, errors.New(fmt.Sprintf("%s%s", "the sort order must be none, lexical or natural, not ", name))
+	}
+}
+
+// Method String gets the name of the sort order.
+func (o SortOrder) String() string {
+	switch o {
+	case Lexical:
+		return "lexical"
+	case Natural:
+		return "natural"
+	default:
+		return "none"
+	}
+}
+
+// Method less determines whether name a is ordered before name b.
+func (o SortOrder) less(a string, b string) bool {
+	if o == Natural {
+		return naturalLess(a, b)
+	}
+	return a < b
+}
+
+// Function naturalLess determines whether name a is ordered before name b, comparing runs of digits numerically.
+// Names that compare as equal numerically, such as "img01" and "img1", fall back to a byte-wise comparison,
+// so the order is total and deterministic.
+func naturalLess(a string, b string) bool {
+	i, j := 0, 0
+
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			// Find the end of each run of digits.
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+
+			// Ignore leading zeros, then the longer number is the larger one.
+			da := strings.TrimLeft(a[si:i], "0")
+			db := strings.TrimLeft(b[sj:j], "0")
+			if len(da) != len(db) {
+				return len(da) < len(db)
+			}
+			if da != db {
+				return da < db
+			}
+			continue
+		}
+
+		if a[i] != b[j] {
+			return a[i] < b[j]
+		}
+		i++
+		j++
+	}
+
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+
+	return a < b
+}
+
+// Function isDigit determines whether a byte is an ASCII digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
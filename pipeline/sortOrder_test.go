@@ -0,0 +1,85 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"sort"
+	"testing"
+)
+
+// Function TestNaturalLess verifies that runs of digits are compared by their numeric values.
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		less bool
+	}{
+		{"img2.png", "img10.png", true},
+		{"img10.png", "img2.png", false},
+		{"img2.png", "img2.png", false},
+		{"img01.png", "img1.png", true},
+		{"img1.png", "img01.png", false},
+		{"img007", "img7a", true},
+		{"a", "b", true},
+		{"img", "img1", true},
+		{"img1", "img", false},
+		{"2", "a", true},
+		{"x99999999999999999999", "x100000000000000000000", true},
+		{"", "", false},
+		{"", "a", true},
+	}
+
+	for _, test := range tests {
+		if less := naturalLess(test.a, test.b); less != test.less {
+			t.Errorf("naturalLess(%q, %q) = %v, want %v", test.a, test.b, less, test.less)
+		}
+	}
+}
+
+// Function TestSortOrderLess verifies that each sort order orders a directory's names as it documents.
+func TestSortOrderLess(t *testing.T) {
+	tests := []struct {
+		order SortOrder
+		want  []string
+	}{
+		{Lexical, []string{"img1.png", "img10.png", "img2.png", "img20.png"}},
+		{Natural, []string{"img1.png", "img2.png", "img10.png", "img20.png"}},
+	}
+
+	for _, test := range tests {
+		names := []string{"img20.png", "img2.png", "img10.png", "img1.png"}
+		sort.Slice(names, func(a, b int) bool { return test.order.less(names[a], names[b]) })
+		for i := range names {
+			if names[i] != test.want[i] {
+				t.Errorf("%v order = %v, want %v", test.order, names, test.want)
+				break
+			}
+		}
+	}
+}
+
+// Function TestParseSortOrder verifies the names of the sort orders.
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		order SortOrder
+		fails bool
+	}{
+		{"", Unsorted, false},
+		{"none", Unsorted, false},
+		{"Lexical", Lexical, false},
+		{"natural", Natural, false},
+		{"random", Unsorted, true},
+	}
+
+	for _, test := range tests {
+		order, err := ParseSortOrder(test.name)
+		if (err != nil) != test.fails || order != test.order {
+			t.Errorf("ParseSortOrder(%q) = %v, %v", test.name, order, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	. "github.com/abitofhelp/go-helpers/string"
+	"io/fs"
+)
+
+// Type Source is an interface that requires implementations of a mechanism that discovers the work entering the pipeline,
+// such as walking a directory, reading a manifest or listing an archive.
+type Source interface {
+
+	// Function Load passes the work items that the source discovers into the pipeline,
+	// until the source has been exhausted or the pipeline's stop channel has been closed.
+	// Parameter pipeline provides the pipeline's options, such as its filters and source file system.
+	// Parameter itemsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+	// Returns nil if there are no errors.
+	Load(pipeline Pipeline, itemsChannel chan<- WorkItem) error
+}
+
+// Type DirectorySource is a Source that recursively walks a directory for files.
+type DirectorySource struct {
+	// Field Path is the path to the directory.
+	Path string
+}
+
+// Method Load walks the directory, passing the work items for its files into the pipeline.
+func (s DirectorySource) Load(pipeline Pipeline, itemsChannel chan<- WorkItem) error {
+	return pipeline.walkPathToChannel(CleanStringForPlatform(s.Path), itemsChannel, nil)
+}
+
+// Type ManifestSource is a Source that reads the paths of the files to process from a manifest.
+type ManifestSource struct {
+	// Field Path is the path to the manifest file, or "-" to read the manifest from stdin.
+	Path string
+
+	// Field NullDelimited indicates whether the manifest's entries are separated by NUL rather than newline characters.
+	NullDelimited bool
+}
+
+// Method Load reads the manifest, passing the work items for its files into the pipeline.
+func (s ManifestSource) Load(pipeline Pipeline, itemsChannel chan<- WorkItem) error {
+	path := s.Path
+	if path != kStdinManifest {
+		path = CleanStringForPlatform(path)
+	}
+	return pipeline.loadManifestToChannel(path, s.NullDelimited, itemsChannel)
+}
+
+// Type ArchiveSource is a Source that lists the members of a zip or tar archive,
+// whether or not the pipeline descends into the archives that it discovers.
+type ArchiveSource struct {
+	// Field Path is the path to the archive.
+	Path string
+}
+
+// Method Load lists the archive, passing the work items for its members into the pipeline.
+func (s ArchiveSource) Load(pipeline Pipeline, itemsChannel chan<- WorkItem) error {
+	if archiveKindOf(s.Path) == notArchive {
+		return errors.New("the archive must be a .zip, .tar, .tar.gz or .tgz file, not " + s.Path)
+	}
+
+	err := listArchive(pipeline.sourceFileSystem(), s.Path, func(memberPath string, info fs.FileInfo, header []byte) {
+		if !pipeline.isStopping() {
+			member := WorkItem{Path: memberPath}
+			member.describe(info)
+			member.Format = detectFormat(header)
+			itemsChannel <- member
+		}
+	})
+	if err != nil {
+		return pipeline.walkError(s.Path, err)
+	}
+
+	return nil
+}
+
+// Type WatchSource is a Source that walks directories, then keeps watching them for new files
+// until the pipeline is stopped (Linux only).
+type WatchSource struct {
+	// Field Paths are the paths to the directories.
+	Paths []string
+}
+
+// Method Load walks the directories and watches them, passing the work items for their files into the pipeline.
+// A pre-scan only walks the directories.
+func (s WatchSource) Load(pipeline Pipeline, itemsChannel chan<- WorkItem) error {
+	if pipeline.FileSystem() != nil {
+		return errors.New("watch mode requires the operating system's file system")
+	}
+
+	// The watcher is created before the walk, so files that arrive during the walk are not missed.
+	watcher, err := newDirectoryWatcher(pipeline.excludes, pipeline.walkError)
+	if err != nil {
+		return err
+	}
+	defer watcher.close()
+
+	for _, path := range s.Paths {
+		err := pipeline.walkPathToChannel(CleanStringForPlatform(path), itemsChannel, watcher)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pipeline.isStopping() || pipeline.isScanning() {
+		return nil
+	}
+
+	// Keep enqueuing files as they are created, until the pipeline is stopped.
+	// A sidecar that is created after its primary file passes the primary file again, with its sidecars.
+	return watcher.watch(func(path string) error {
+		for _, item := range pipeline.findSidecars(path) {
+			err := pipeline.enqueuePath(item, itemsChannel)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, pipeline.StopChannel())
+}
+
+// Method Sources gets the mechanisms that discover work for the pipeline, in the order that they are loaded.
+func (p Pipeline) Sources() []Source {
+	return p.sources
+}
+
+// Method setSources sets the mechanisms that discover work for the pipeline, in the order that they are loaded.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) setSources(sources []Source) error {
+	p.sources = nil
+	for _, source := range sources {
+		err := p.AddSource(source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Method AddSource adds a mechanism that discovers work for the pipeline, which is loaded after the others.
+// If there is an error, an error is returned, otherwise nil.
+func (p *Pipeline) AddSource(source Source) error {
+	if source == nil {
+		return errors.New("the source cannot be nil")
+	}
+	p.sources = append(p.sources, source)
+	return nil
+}
+
+// Method roots gets the paths to the directories that the pipeline's sources walk,
+// which determine the relative paths of the files within them.
+func (p Pipeline) roots() []string {
+	var roots []string
+	for _, source := range p.Sources() {
+		switch source := source.(type) {
+		case DirectorySource:
+			roots = append(roots, CleanStringForPlatform(source.Path))
+		case WatchSource:
+			for _, path := range source.Paths {
+				roots = append(roots, CleanStringForPlatform(path))
+			}
+		}
+	}
+	return roots
+}
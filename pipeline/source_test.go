@@ -0,0 +1,200 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Function loadTestSources loads a pipeline's sources in turn, as a run does.
+// Returns the paths that were passed into the pipeline, in order, and the error.
+func loadTestSources(p *Pipeline) ([]string, error) {
+	pathsChannel := make(chan WorkItem, 64)
+	commandChannel := make(chan bool, 1)
+	commandChannel <- true
+
+	err := p.loadPathsToChannel(pathsChannel, commandChannel)
+
+	var paths []string
+	for item := range pathsChannel {
+		paths = append(paths, item.Path)
+	}
+	return paths, err
+}
+
+// Function TestSourcesLoadInOrder verifies that an archive, a directory and a manifest are loaded in the order
+// that they were added, each passing its own files.
+func TestSourcesLoadInOrder(t *testing.T) {
+	root := t.TempDir()
+	writes := map[string][]byte{
+		"walked/2018-06-01.jpg": []byte("walked"),
+		"walked/2018-06-02.jpg": []byte("walked"),
+		"listed/scan.tif":       []byte("listed"),
+		"listed/ignored.tif":    []byte("not listed"),
+		"batch.zip":             newTestZip(t),
+	}
+	for name, content := range writes {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := filepath.Join(root, "manifest.txt")
+	if err := os.WriteFile(manifest, []byte(filepath.Join(root, "listed/scan.tif")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := []Source{
+		ArchiveSource{Path: filepath.Join(root, "batch.zip")},
+		DirectorySource{Path: filepath.Join(root, "walked")},
+		ManifestSource{Path: manifest},
+	}
+	p, err := New(sources, 4096, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetSortOrder(Lexical); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := loadTestSources(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for _, member := range testArchiveMembers {
+		want = append(want, filepath.Join(root, "batch.zip")+kArchiveSeparator+member.path)
+	}
+	want = append(want,
+		filepath.Join(root, "walked/2018-06-01.jpg"),
+		filepath.Join(root, "walked/2018-06-02.jpg"),
+		filepath.Join(root, "listed/scan.tif"))
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("the sources passed %v, want %v", paths, want)
+	}
+}
+
+// Function TestSourcesReject verifies the sources that cannot be loaded, and that an archive which cannot be read
+// is recorded as a walk error rather than failing the run.
+func TestSourcesReject(t *testing.T) {
+	mapFS := fstest.MapFS{"photos/a.jpg": {Data: []byte("a")}}
+
+	tests := []struct {
+		name       string
+		source     Source
+		fileSystem bool
+		fails      bool
+		walkErrors int
+	}{
+		{"not an archive", ArchiveSource{Path: "photos/a.jpg"}, true, true, 0},
+		{"missing archive", ArchiveSource{Path: "photos/missing.tgz"}, true, false, 1},
+		{"watch within a file system", WatchSource{Paths: []string{"photos"}}, true, true, 0},
+		{"not a directory", DirectorySource{Path: "photos/a.jpg"}, true, false, 1},
+		{"missing manifest", ManifestSource{Path: filepath.Join(t.TempDir(), "missing.txt")}, false, true, 0},
+		{"ftp listing", HTTPListingSource{URL: "ftp://example.com/photos/"}, false, true, 0},
+	}
+
+	for _, test := range tests {
+		p, err := New([]Source{test.source}, 4096, 8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.fileSystem {
+			if err = p.SetFileSystem(mapFS); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		paths, err := loadTestSources(p)
+		if (err != nil) != test.fails || len(paths) != 0 {
+			t.Errorf("%s: the source passed %v, %v", test.name, paths, err)
+		}
+		if walkErrors := p.Report().WalkErrors(); len(walkErrors) != test.walkErrors {
+			t.Errorf("%s: the walk errors are %v", test.name, walkErrors)
+		}
+	}
+}
+
+// Function TestHTTPListingSource verifies that the files are read from an HTML index and from a text listing,
+// with relative URLs resolved and the directories and sorting links ignored, and that each file can be opened.
+func TestHTTPListingSource(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gallery/", func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/gallery/" {
+			fmt.Fprintf(writer, "contents of %s", request.URL.Path)
+			return
+		}
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(writer, `<html><body><a href="?C=M;O=A">Modified</a> <a href="../">Parent</a>
+			<a href="sunset.jpg">sunset.jpg</a> <A HREF='night%20sky.png'>night sky.png</A>
+			<a href="albums/">albums/</a> <a href="mailto:owner@example.com">owner</a>
+			<a href="/shared/logo.gif">logo</a></body></html>`)
+	})
+	mux.HandleFunc("/list.txt", func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(writer, "gallery/sunset.jpg\r\n\n  http://photos.example.com/remote.jpg  \nftp://example.com/old.jpg\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		url        string
+		paths      []string
+		walkErrors int
+	}{
+		{server.URL + "/gallery/", []string{
+			server.URL + "/gallery/sunset.jpg",
+			server.URL + "/gallery/night%20sky.png",
+			server.URL + "/shared/logo.gif",
+		}, 0},
+		{server.URL + "/list.txt", []string{
+			server.URL + "/gallery/sunset.jpg",
+			"http://photos.example.com/remote.jpg",
+		}, 0},
+		{server.URL + "/missing.txt", nil, 1},
+	}
+
+	for _, test := range tests {
+		p, err := New([]Source{HTTPListingSource{URL: test.url}}, 4096, 8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		paths, err := loadTestSources(p)
+		if err != nil || fmt.Sprint(paths) != fmt.Sprint(test.paths) {
+			t.Errorf("%s passed %v, %v, want %v", test.url, paths, err, test.paths)
+		}
+		if walkErrors := p.Report().WalkErrors(); len(walkErrors) != test.walkErrors {
+			t.Errorf("%s: the walk errors are %v", test.url, walkErrors)
+		}
+	}
+
+	stream, err := OpenPath(server.URL + "/gallery/sunset.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	content, err := io.ReadAll(stream)
+	if err != nil || !strings.HasSuffix(string(content), "/gallery/sunset.jpg") {
+		t.Errorf("the remote file reads %q, %v", content, err)
+	}
+	if _, err = OpenPath(server.URL + "/missing.jpg"); err == nil {
+		t.Error("a missing remote file was opened")
+	}
+}
@@ -0,0 +1,377 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The version of the state file's format.
+const kStateVersion = 1
+
+// Type DeletedPolicy indicates what an incremental run does with files that were deleted since the last run.
+type DeletedPolicy int
+
+// Constants for the values of a DeletedPolicy.
+const (
+	// IgnoreDeleted leaves the deleted files in the state file.
+	IgnoreDeleted DeletedPolicy = iota
+
+	// ReportDeleted lists the deleted files in the run report, and removes them from the state file.
+	ReportDeleted
+
+	// PruneDeleted silently removes the deleted files from the state file.
+	PruneDeleted
+)
+
+// Function ParseDeletedPolicy converts the name of a deleted policy to a DeletedPolicy.
+// Parameter name is one of "ignore", "report" or "prune".
+// Returns the DeletedPolicy or error.
+func ParseDeletedPolicy(name string) (DeletedPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "ignore":
+		return IgnoreDeleted, nil
+	case "report":
+		return ReportDeleted, nil
+	case "prune":
+		return PruneDeleted, nil
+	default:
+		return IgnoreDeleted, errors.New(fmt.Sprintf("%s%s", "the deleted policy must be ignore, report or prune, not ", name))
+	}
+}
+
+// Method String gets the name of the deleted policy.
+func (d DeletedPolicy) String() string {
+	switch d {
+	case ReportDeleted:
+		return "report"
+	case PruneDeleted:
+		return "prune"
+	default:
+		return "ignore"
+	}
+}
+
+// Type FileState is what an incremental run remembers about a file that has been processed.
+type FileState struct {
+	// Field Size is the file's size in bytes.
+	Size int64 `json:"size"`
+
+	// Field ModTime is when the file was last modified.
+	ModTime time.Time `json:"modTime"`
+
+	// Field Hash is the hash of the file's contents, when content hashing is enabled.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Type stateFile is the persisted form of a stateDatabase.
+type stateFile struct {
+	// Field Version is the version of the format.
+	Version int `json:"version"`
+
+	// Field Files maps each processed path to its state.
+	Files map[string]FileState `json:"files"`
+}
+
+// Type stateDatabase tracks the files that have been processed, so an incremental run only processes the new
+// or changed files. It is safe for concurrent use by the pipeline's steps.
+type stateDatabase struct {
+	// Field mutex guards the other fields.
+	mutex sync.Mutex
+
+	// Field path is the path to the state file.
+	path string
+
+	// Field files maps each processed path to its state.
+	files map[string]FileState
+
+	// Field pending maps each changed path that is being processed to its new state.
+	pending map[string]FileState
+
+	// Field seen records every path that was discovered during this run.
+	seen map[string]bool
+}
+
+// Function loadStateDatabase reads a state file, or creates an empty database when the file does not exist.
+// Parameter path is the path to the state file.
+// Returns an initialized instance or error.
+func loadStateDatabase(path string) (*stateDatabase, error) {
+	state := &stateDatabase{
+		path:    path,
+		files:   make(map[string]FileState),
+		pending: make(map[string]FileState),
+		seen:    make(map[string]bool),
+	}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s%v", "failed to read the state file: ", err))
+	}
+
+	var persisted stateFile
+	err = json.Unmarshal(contents, &persisted)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s%v", "failed to parse the state file: ", err))
+	}
+	if persisted.Version != kStateVersion {
+		return nil, errors.New(fmt.Sprintf("the state file's version %d is not supported", persisted.Version))
+	}
+	if persisted.Files != nil {
+		state.files = persisted.Files
+	}
+
+	return state, nil
+}
+
+// Method isChanged determines whether a file is new or has changed since it was last processed.
+// A changed file's new state is held until it has been processed.
+// Parameter path is the path to the file.
+// Parameter current is the file's current state, without its hash.
+// Parameter hash computes the hash of the file's contents, or is nil when content hashing is disabled.
+// Returns whether the file must be processed, or error.
+func (s *stateDatabase) isChanged(path string, current FileState, hash func() (string, error)) (bool, error) {
+	s.mutex.Lock()
+	s.seen[path] = true
+	previous, found := s.files[path]
+	s.mutex.Unlock()
+
+	if found && previous.Size == current.Size && previous.ModTime.Equal(current.ModTime) {
+		return false, nil
+	}
+
+	if hash != nil {
+		var err error
+		current.Hash, err = hash()
+		if err != nil {
+			return false, err
+		}
+
+		// A file that was only touched keeps its contents, so it does not need to be processed again.
+		if found && previous.Hash != "" && previous.Hash == current.Hash {
+			s.mutex.Lock()
+			s.files[path] = current
+			s.mutex.Unlock()
+			return false, nil
+		}
+	}
+
+	s.mutex.Lock()
+	s.pending[path] = current
+	s.mutex.Unlock()
+
+	return true, nil
+}
+
+// Method markProcessed records that a changed file has been processed, so it is skipped by the next run.
+// Parameter path is the path to the file.
+func (s *stateDatabase) markProcessed(path string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, ok := s.pending[path]
+	if !ok {
+		return
+	}
+	delete(s.pending, path)
+	s.files[path] = current
+}
+
+// Method deleted finds the files that were processed by a previous run, but no longer exist.
+// Parameter exists determines whether a file still exists.
+// Returns the paths of the deleted files, sorted.
+func (s *stateDatabase) deleted(exists func(path string) bool) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var paths []string
+	for path := range s.files {
+		// A file that was not discovered may still exist outside of this run's paths.
+		if !s.seen[path] && !exists(path) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Method forget removes files from the database.
+// Parameter paths are the paths of the files.
+func (s *stateDatabase) forget(paths []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, path := range paths {
+		delete(s.files, path)
+	}
+}
+
+// Method save writes the database to its state file, replacing the file atomically.
+// Returns nil if there are no errors.
+func (s *stateDatabase) save() error {
+	s.mutex.Lock()
+	contents, err := json.MarshalIndent(stateFile{Version: kStateVersion, Files: s.files}, "", "  ")
+	s.mutex.Unlock()
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to encode the state file: ", err))
+	}
+
+	temporary, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to write the state file: ", err))
+	}
+	defer os.Remove(temporary.Name())
+
+	_, err = temporary.Write(contents)
+	if err == nil {
+		err = temporary.Sync()
+	}
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temporary.Name(), s.path)
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v", "failed to write the state file: ", err))
+	}
+
+	return nil
+}
+
+// Filter out the work items whose files are unchanged since they were last processed.
+// A work item is processed again when its primary file or any of its sidecar files has changed.
+// Parameter state is the database of files that have been processed.
+// Parameter inChannel is the unidirectional channel of discovered work items.
+// Parameter pathsChannel is the unidirectional channel being used to feed the work items to the pipeline.
+func (p Pipeline) filterUnchangedPaths(state *stateDatabase, inChannel <-chan WorkItem, pathsChannel chan<- WorkItem) {
+	defer close(pathsChannel)
+
+	for item := range inChannel {
+		changed, err := p.isItemChanged(state, item)
+		if err != nil {
+			// A file that cannot be compared with its last run is processed.
+			fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
+			item.AddError(kStateStep, err)
+			pathsChannel <- item
+			continue
+		}
+		item.SetAttribute(kStateStep, "changed", changed)
+
+		if !changed {
+			p.Report().addUnchanged()
+			continue
+		}
+
+		pathsChannel <- item
+	}
+}
+
+// Method isItemChanged determines whether any of a work item's files is new or has changed since it was last processed.
+// Every file is checked, so each one is seen by the run and its new state is held until it has been processed.
+// The primary file is compared using the size and modification time that were recorded when it was discovered.
+// Parameter state is the database of files that have been processed.
+// Parameter item is the work item.
+// Returns whether the work item must be processed, or error.
+func (p Pipeline) isItemChanged(state *stateDatabase, item WorkItem) (bool, error) {
+	var (
+		changed bool
+		err     error
+	)
+
+	if item.ModTime.IsZero() {
+		changed, err = p.isChanged(state, item.Path)
+	} else {
+		changed, err = state.isChanged(item.Path, FileState{Size: item.Size, ModTime: item.ModTime}, p.contentHash(item.Path))
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, path := range item.Sidecars {
+		pathChanged, err := p.isChanged(state, path)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || pathChanged
+	}
+	return changed, nil
+}
+
+// Method isChanged determines whether a file is new or has changed since it was last processed.
+// A member within an archive is considered changed whenever its archive has changed,
+// and a remote file, which cannot be examined without reading it, is always considered changed.
+// Parameter state is the database of files that have been processed.
+// Parameter path is the path to the file.
+// Returns whether the file must be processed, or error.
+func (p Pipeline) isChanged(state *stateDatabase, path string) (bool, error) {
+	if IsRemotePath(path) {
+		return true, nil
+	}
+
+	statPath := path
+	if archive, _, ok := SplitArchiveMemberPath(path); ok {
+		statPath = archive
+	}
+
+	info, err := p.statPath(statPath)
+	if err != nil {
+		return false, err
+	}
+
+	return state.isChanged(path, FileState{Size: info.Size(), ModTime: info.ModTime().UTC()}, p.contentHash(path))
+}
+
+// Method contentHash gets the function that computes the hash of a file's contents for an incremental run,
+// or nil when the run does not compare the hashes.
+// Parameter path is the path to the file.
+func (p Pipeline) contentHash(path string) func() (string, error) {
+	if !p.StateHash() {
+		return nil
+	}
+	return func() (string, error) {
+		return p.hashFile(path)
+	}
+}
+
+// Method finishState acts on the files that were deleted since the last run, and saves the state file.
+// Parameter state is the database of files that have been processed.
+// Returns nil if there are no errors.
+func (p Pipeline) finishState(state *stateDatabase) error {
+
+	// A stopped run did not discover every file, but deleted files are confirmed to be missing before acting.
+	if p.DeletedPolicy() != IgnoreDeleted {
+		deleted := state.deleted(func(path string) bool {
+			if archive, _, ok := SplitArchiveMemberPath(path); ok {
+				path = archive
+			}
+			_, err := p.statPath(path)
+			return !errors.Is(err, fs.ErrNotExist)
+		})
+
+		if p.DeletedPolicy() == ReportDeleted {
+			for _, path := range deleted {
+				p.Report().addDeleted(path)
+			}
+		}
+		state.forget(deleted)
+	}
+
+	return state.save()
+}
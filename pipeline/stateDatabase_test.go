@@ -0,0 +1,120 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Function TestStateDatabaseSaveAndLoad verifies that only the processed files are saved, that a reloaded database
+// skips the unchanged and touched files, and that the deleted files are found and forgotten.
+func TestStateDatabaseSaveAndLoad(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "state.json")
+	noon := time.Date(2018, 9, 1, 12, 0, 0, 0, time.FixedZone("PDT", -7*3600))
+
+	state, err := loadStateDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first run processes one file, and fails to process another before it is saved.
+	for _, name := range []string{"/album/processed.jpg", "/album/failed.jpg"} {
+		changed, err := state.isChanged(name, FileState{Size: 2048, ModTime: noon}, func() (string, error) { return "cafe", nil })
+		if err != nil || !changed {
+			t.Fatalf("the new file %s is changed %v, %v", name, changed, err)
+		}
+	}
+	state.markProcessed("/album/processed.jpg")
+	state.markProcessed("/album/unknown.jpg")
+	if err = state.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("the state directory holds %v, %v", entries, err)
+	}
+
+	// The second run reloads the database.
+	state, err = loadStateDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.files) != 1 || state.files["/album/processed.jpg"].Hash != "cafe" ||
+		!state.files["/album/processed.jpg"].ModTime.Equal(noon) {
+		t.Fatalf("the reloaded state is %v", state.files)
+	}
+
+	hashed := 0
+	hash := func(value string) func() (string, error) {
+		return func() (string, error) {
+			hashed++
+			return value, nil
+		}
+	}
+	tests := []struct {
+		name    string
+		current FileState
+		hash    func() (string, error)
+		changed bool
+		hashed  int
+	}{
+		{"unchanged", FileState{Size: 2048, ModTime: noon.UTC()}, hash("cafe"), false, 0},
+		{"touched", FileState{Size: 2048, ModTime: noon.Add(time.Hour)}, hash("cafe"), false, 1},
+		{"rewritten", FileState{Size: 4096, ModTime: noon.Add(2 * time.Hour)}, hash("beef"), true, 2},
+		{"rewritten without hashing", FileState{Size: 1024, ModTime: noon}, nil, true, 2},
+	}
+	for _, test := range tests {
+		changed, err := state.isChanged("/album/processed.jpg", test.current, test.hash)
+		if err != nil || changed != test.changed || hashed != test.hashed {
+			t.Errorf("%s: changed %v, %v after %d hashes", test.name, changed, err, hashed)
+		}
+	}
+	if !state.files["/album/processed.jpg"].ModTime.Equal(noon.Add(time.Hour)) {
+		t.Errorf("the touched file's state is %v", state.files["/album/processed.jpg"])
+	}
+
+	// A file that was not discovered by this run, and no longer exists, has been deleted.
+	state.files["/album/removed.jpg"] = FileState{Size: 1}
+	state.files["/elsewhere/kept.jpg"] = FileState{Size: 1}
+	deleted := state.deleted(func(path string) bool { return path == "/elsewhere/kept.jpg" })
+	if len(deleted) != 1 || deleted[0] != "/album/removed.jpg" {
+		t.Errorf("the deleted files are %v", deleted)
+	}
+	state.forget(deleted)
+	if _, ok := state.files["/album/removed.jpg"]; ok || len(state.files) != 2 {
+		t.Errorf("the forgotten state is %v", state.files)
+	}
+}
+
+// Function TestLoadStateDatabaseRejects verifies that a state file which cannot be parsed, or whose version
+// is not supported, is not silently replaced.
+func TestLoadStateDatabaseRejects(t *testing.T) {
+	tests := map[string]string{
+		"truncated":       `{"version": 1, "files": {`,
+		"future version":  `{"version": 2, "files": {}}`,
+		"missing version": `{"files": {"/a.jpg": {"size": 1}}}`,
+	}
+
+	for name, contents := range tests {
+		path := filepath.Join(t.TempDir(), "state.json")
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadStateDatabase(path); err == nil {
+			t.Errorf("the %s state file was loaded", name)
+		}
+	}
+
+	if _, err := loadStateDatabase(t.TempDir()); err == nil {
+		t.Error("a directory was loaded as the state file")
+	}
+}
@@ -0,0 +1,169 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"sync"
+)
+
+// Type directoryQueue is the shared list of directories that remain to be read by the walkers.
+type directoryQueue struct {
+	// Field mutex guards the other fields.
+	mutex sync.Mutex
+
+	// Field ready signals the walkers when a directory has been queued or the walk has finished.
+	ready *sync.Cond
+
+	// Field directories are the paths of the directories that have been queued, but not yet read.
+	directories []string
+
+	// Field pending is the number of directories that have been queued, but not yet completely read.
+	pending int
+
+	// Field halted indicates that the walk has been stopped.
+	halted bool
+
+	// Field err is the error that halted the walk.
+	err error
+}
+
+// Method push queues a directory to be read.
+func (q *directoryQueue) push(osDirname string) {
+	q.mutex.Lock()
+	q.directories = append(q.directories, osDirname)
+	q.pending++
+	q.mutex.Unlock()
+
+	q.ready.Signal()
+}
+
+// Method pop waits for a queued directory, and removes it from the queue.
+// Returns the directory, and false when the walk has finished or been halted.
+func (q *directoryQueue) pop() (string, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for len(q.directories) == 0 && q.pending > 0 && !q.halted {
+		q.ready.Wait()
+	}
+
+	if q.halted || len(q.directories) == 0 {
+		return "", false
+	}
+
+	// Taking the most recently queued directory keeps the walk depth-first, so the queue stays small.
+	last := len(q.directories) - 1
+	osDirname := q.directories[last]
+	q.directories = q.directories[:last]
+
+	return osDirname, true
+}
+
+// Method done records that a popped directory has been completely read.
+func (q *directoryQueue) done() {
+	q.mutex.Lock()
+	q.pending--
+	finished := q.pending == 0
+	q.mutex.Unlock()
+
+	if finished {
+		q.ready.Broadcast()
+	}
+}
+
+// Method halt stops the walk, and releases any walkers that are waiting for a directory.
+// Parameter err is the error that halted the walk, of which the first is kept.
+func (q *directoryQueue) halt(err error) {
+	q.mutex.Lock()
+	if !q.halted {
+		q.halted = true
+		q.err = err
+	}
+	q.mutex.Unlock()
+
+	q.ready.Broadcast()
+}
+
+// Concurrently walk a directory's subtrees, invoking a callback for each of their entries.
+// Each walker reads directories with its own reusable scratch buffer of ScannerBufferSize bytes.
+// The callback is invoked from multiple goroutines, so it must be safe for concurrent use.
+// Parameter osDirname is the path to the directory to walk.
+// Parameter callback is invoked with the path and directory entry for each node in the hierarchy.
+// Returns errStopped or the *WalkError that halted the walk, otherwise nil.
+func (p Pipeline) walkDirectoryInParallel(osDirname string, callback walkCallback) error {
+
+	queue := &directoryQueue{}
+	queue.ready = sync.NewCond(&queue.mutex)
+	queue.push(osDirname)
+
+	var wg sync.WaitGroup
+
+	for i := uint64(0); i < p.WalkerCount(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			scratchBuffer := make([]byte, p.ScannerBufferSize())
+
+			for {
+				directory, ok := queue.pop()
+				if !ok {
+					return
+				}
+
+				err := p.walkEntries(directory, scratchBuffer, callback, queue)
+				if err != nil {
+					queue.halt(err)
+				}
+				queue.done()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return queue.err
+}
+
+// Method walkEntries invokes a callback for each entry in a directory, and queues its subdirectories to be read.
+// Parameter osDirname is the path to the directory to read.
+// Parameter scratchBuffer is the walker's reusable buffer for reading directory entries.
+// Parameter callback is invoked with the path and directory entry for each entry in the directory.
+// Parameter queue receives the subdirectories.
+// Returns errStopped or the *WalkError that halts the walk, otherwise nil.
+func (p Pipeline) walkEntries(osDirname string, scratchBuffer []byte, callback walkCallback, queue *directoryQueue) error {
+
+	dirents, err := p.readDirectory(osDirname, scratchBuffer)
+	if err != nil {
+		return err
+	}
+
+	for _, de := range dirents {
+		path := p.joinPath(osDirname, de.Name())
+
+		err := callback(path, de)
+		if err == errSkipDirectory {
+			continue
+		}
+		if isHalt(err) {
+			return err
+		}
+		if err != nil {
+			err = p.walkError(path, err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if de.IsDir() {
+			queue.push(path)
+		}
+	}
+
+	return nil
+}
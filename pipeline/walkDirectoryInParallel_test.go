@@ -0,0 +1,192 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Type unreadableFS is a file system in which one directory fails to be read.
+type unreadableFS struct {
+	fstest.MapFS
+
+	// Field unreadable is the path of the directory that fails.
+	unreadable string
+}
+
+// Method ReadDir reads a directory, failing for the unreadable directory.
+func (u unreadableFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == u.unreadable {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: syscall.EIO}
+	}
+	return u.MapFS.ReadDir(name)
+}
+
+// Function newTestTree creates a file system with several levels of directories, some of them empty.
+// Returns the file system, and the sorted paths of its files.
+func newTestTree() (fstest.MapFS, []string) {
+	tree := fstest.MapFS{"empty": &fstest.MapFile{Mode: fs.ModeDir}}
+	var files []string
+	for year := 2015; year <= 2018; year++ {
+		for month := 1; month <= 6; month++ {
+			for day := 1; day <= month; day++ {
+				path := fmt.Sprintf("%d/%02d/%02d/IMG_%d%02d%02d.JPG", year, month, day, year, month, day)
+				tree[path] = &fstest.MapFile{Data: []byte(path)}
+				files = append(files, path)
+			}
+			tree[fmt.Sprintf("%d/%02d/empty", year, month)] = &fstest.MapFile{Mode: fs.ModeDir}
+		}
+	}
+	sort.Strings(files)
+	return tree, files
+}
+
+// Function walkTestTree walks a file system with a number of walkers, failing the test if the walk does not finish.
+// Returns the sorted paths that were passed into the pipeline, the pipeline and the walk's error.
+func walkTestTree(t *testing.T, fileSystem fs.FS, walkers uint64, policy WalkErrorPolicy) ([]string, *Pipeline, error) {
+	p, err := New([]Source{DirectorySource{Path: "."}}, 4096, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetFileSystem(fileSystem); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetWalkerCount(walkers); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetWalkErrorPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+
+	pathsChannel := make(chan WorkItem, 1024)
+	errChannel := make(chan error, 1)
+	go func() {
+		errChannel <- p.walkPathToChannel(".", pathsChannel, nil)
+		close(pathsChannel)
+	}()
+
+	select {
+	case err = <-errChannel:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the walk with %d walkers did not finish", walkers)
+	}
+
+	var paths []string
+	for item := range pathsChannel {
+		paths = append(paths, item.Path)
+	}
+	sort.Strings(paths)
+	return paths, p, err
+}
+
+// Function TestWalkDirectoryInParallel verifies that any number of walkers passes each file exactly once.
+func TestWalkDirectoryInParallel(t *testing.T) {
+	tree, files := newTestTree()
+
+	for _, walkers := range []uint64{1, 2, 3, 16} {
+		paths, _, err := walkTestTree(t, tree, walkers, SkipOnWalkError)
+		if err != nil {
+			t.Errorf("%d walkers: %v", walkers, err)
+		}
+		if fmt.Sprint(paths) != fmt.Sprint(files) {
+			t.Errorf("%d walkers passed %d files, want %d", walkers, len(paths), len(files))
+		}
+	}
+}
+
+// Function TestWalkDirectoryInParallelUnreadable verifies that a directory which cannot be read is skipped,
+// or halts every walker, as the walk error policy requires.
+func TestWalkDirectoryInParallelUnreadable(t *testing.T) {
+	tree, files := newTestTree()
+	failing := unreadableFS{MapFS: tree, unreadable: "2016/03"}
+
+	var remaining []string
+	for _, path := range files {
+		if path[:8] != "2016/03/" {
+			remaining = append(remaining, path)
+		}
+	}
+
+	for _, walkers := range []uint64{1, 4} {
+		paths, p, err := walkTestTree(t, failing, walkers, SkipOnWalkError)
+		if err != nil || fmt.Sprint(paths) != fmt.Sprint(remaining) {
+			t.Errorf("%d walkers skipping: passed %d files, want %d: %v", walkers, len(paths), len(remaining), err)
+		}
+		walkErrors := p.Report().WalkErrors()
+		if len(walkErrors) != 1 || walkErrors[0].Path != "2016/03" || walkErrors[0].Kind != IOFailure {
+			t.Errorf("%d walkers skipping: the walk errors are %v", walkers, walkErrors)
+		}
+
+		paths, _, err = walkTestTree(t, failing, walkers, HaltOnWalkError)
+		var walkErr *WalkError
+		if !errors.As(err, &walkErr) || walkErr.Path != "2016/03" {
+			t.Errorf("%d walkers halting: the walk returned %v", walkers, err)
+		}
+
+		// A single walker reads the directories in order, so it halts before the later years.
+		if walkers == 1 && len(paths) >= len(remaining) {
+			t.Errorf("%d walker halting: passed all of the other files", walkers)
+		}
+	}
+}
+
+// Function TestDirectoryQueue verifies that the waiting walkers are released when the last directory is done,
+// and when the walk is halted, keeping the first error.
+func TestDirectoryQueue(t *testing.T) {
+	for _, halts := range []bool{false, true} {
+		queue := &directoryQueue{}
+		queue.ready = sync.NewCond(&queue.mutex)
+		queue.push("root")
+
+		directory, ok := queue.pop()
+		if !ok || directory != "root" {
+			t.Fatalf("the queue popped %q, %v", directory, ok)
+		}
+
+		// The walkers wait while the only directory is being read, since it may queue subdirectories.
+		released := make(chan bool, 3)
+		for i := 0; i < 3; i++ {
+			go func() {
+				_, ok := queue.pop()
+				released <- ok
+			}()
+		}
+		select {
+		case <-released:
+			t.Fatal("a walker was released while a directory was being read")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		if halts {
+			queue.halt(errStopped)
+			queue.halt(errors.New("a later error"))
+		}
+		queue.done()
+
+		for i := 0; i < 3; i++ {
+			select {
+			case ok := <-released:
+				if ok {
+					t.Error("a walker popped a directory from the finished queue")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("a walker was not released")
+			}
+		}
+		if halts && queue.err != errStopped {
+			t.Errorf("the queue kept the error %v", queue.err)
+		}
+	}
+}
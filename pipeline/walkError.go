@@ -0,0 +1,147 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Type WalkErrorKind classifies the errors that are encountered while discovering files.
+type WalkErrorKind int
+
+// Constants for the values of a WalkErrorKind.
+const (
+	// PermissionDenied is a file or directory that could not be accessed.
+	PermissionDenied WalkErrorKind = iota
+
+	// Vanished is a file or directory that was removed after it was found.
+	Vanished
+
+	// IOFailure is a device or file system that failed to read.
+	IOFailure
+
+	// OtherFailure is any other error.
+	OtherFailure
+)
+
+// Method String gets the description of the kind of error.
+func (k WalkErrorKind) String() string {
+	switch k {
+	case PermissionDenied:
+		return "permission denied"
+	case Vanished:
+		return "vanished"
+	case IOFailure:
+		return "i/o error"
+	default:
+		return "other"
+	}
+}
+
+// Type WalkError is an error that was encountered while discovering files.
+type WalkError struct {
+	// Field Path is the path to the file or directory that caused the error.
+	Path string
+
+	// Field Kind classifies the error.
+	Kind WalkErrorKind
+
+	// Field Err is the underlying error.
+	Err error
+}
+
+// Function newWalkError is a factory that creates a classified WalkError.
+// Parameter path is the path to the file or directory that caused the error.
+// Parameter err is the underlying error.
+// Returns an initialized instance.
+func newWalkError(path string, err error) *WalkError {
+	kind := OtherFailure
+
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		kind = PermissionDenied
+	case errors.Is(err, fs.ErrNotExist):
+		kind = Vanished
+	case errors.Is(err, syscall.EIO):
+		kind = IOFailure
+	}
+
+	return &WalkError{Path: path, Kind: kind, Err: err}
+}
+
+// Method Error gets the description of the error.
+func (e *WalkError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Kind, e.Path, e.Err)
+}
+
+// Method Unwrap gets the underlying error.
+func (e *WalkError) Unwrap() error {
+	return e.Err
+}
+
+// Type WalkErrorPolicy indicates what discovery does when it encounters an error.
+type WalkErrorPolicy int
+
+// Constants for the values of a WalkErrorPolicy.
+const (
+	// SkipOnWalkError skips the file or directory, and continues discovering the remaining files.
+	SkipOnWalkError WalkErrorPolicy = iota
+
+	// HaltOnWalkError stops discovering files, and the run fails with the error.
+	HaltOnWalkError
+)
+
+// Function ParseWalkErrorPolicy converts the name of a walk error policy to a WalkErrorPolicy.
+// Parameter name is one of "skip" or "halt".
+// Returns the WalkErrorPolicy or error.
+func ParseWalkErrorPolicy(name string) (WalkErrorPolicy, error) {
+	switch strings.ToLower(name) {
+	case "", "skip":
+		return SkipOnWalkError, nil
+	case "halt":
+		return HaltOnWalkError, nil
+	default:
+		return SkipOnWalkError, errors.New(fmt.Sprintf("%s%s", "the walk error policy must be skip or halt, not ", name))
+	}
+}
+
+// Method String gets the name of the walk error policy.
+func (w WalkErrorPolicy) String() string {
+	if w == HaltOnWalkError {
+		return "halt"
+	}
+	return "skip"
+}
+
+// Method walkError records an error that was encountered while discovering files in the run report.
+// Parameter path is the path to the file or directory that caused the error.
+// Parameter err is the underlying error.
+// Returns the *WalkError when the walk must halt, or nil to skip the file or directory and continue.
+func (p Pipeline) walkError(path string, err error) error {
+	walkErr := newWalkError(path, err)
+
+	p.Report().addWalkError(*walkErr)
+	fmt.Fprintf(os.Stderr, "ERROR: %s\n", walkErr)
+
+	if p.WalkErrorPolicy() == HaltOnWalkError {
+		return walkErr
+	}
+
+	return nil
+}
+
+// Function isHalt determines whether an error returned during discovery halts the walk.
+// The walk halts when the pipeline has been stopped, or when a recorded error must halt it.
+func isHalt(err error) bool {
+	var walkErr *WalkError
+	return err == errStopped || errors.As(err, &walkErr)
+}
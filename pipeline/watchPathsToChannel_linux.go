@@ -0,0 +1,234 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+//go:build linux
+// +build linux
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	godirwalk "github.com/karrick/godirwalk"
+	"os"
+	. "path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// The inotify events that are watched on each directory.
+	// IN_CLOSE_WRITE and IN_MOVED_TO signal that a file is complete, IN_CREATE signals a new subdirectory.
+	kWatchEvents = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE_SELF
+
+	// The number of bytes that are read from the inotify file descriptor at once.
+	kWatchBufferSize = 64 * (syscall.SizeofInotifyEvent + syscall.NAME_MAX + 1)
+)
+
+// Type directoryWatcher uses Linux inotify to watch a file system hierarchy for files that have been written.
+type directoryWatcher struct {
+	// Field file wraps the inotify file descriptor, so reads can be interrupted by closing it.
+	file *os.File
+
+	// Field mutex guards the directories, which are added by concurrent walkers.
+	mutex sync.Mutex
+
+	// Field directories maps each watch descriptor to the path of its directory.
+	directories map[int32]string
+
+	// Field excludes determines whether a new file is skipped, or a new directory is not watched.
+	excludes func(path string, isDirectory bool) bool
+
+	// Field walkError records an error that was encountered while walking a new directory,
+	// and returns it when the walk must halt.
+	walkError func(path string, err error) error
+}
+
+// Function newDirectoryWatcher is a factory that creates an initialized directoryWatcher.
+// Parameter excludes determines whether a new file is skipped, or a new directory is not watched.
+// Parameter walkError records an error that was encountered while walking a new directory,
+// and returns it when the walk must halt.
+// Returns an initialized instance or error.
+func newDirectoryWatcher(excludes func(path string, isDirectory bool) bool, walkError func(path string, err error) error) (*directoryWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s%v", "failed to initialize inotify: ", err))
+	}
+
+	watcher := &directoryWatcher{
+		file:        os.NewFile(uintptr(fd), "inotify"),
+		directories: make(map[int32]string),
+		excludes:    excludes,
+		walkError:   walkError,
+	}
+
+	return watcher, nil
+}
+
+// Method addDirectory begins watching a directory for new files and subdirectories.
+// Parameter path is the path to the directory to watch.
+// Returns nil if there are no errors.
+func (w *directoryWatcher) addDirectory(path string) error {
+	wd, err := syscall.InotifyAddWatch(int(w.file.Fd()), path, kWatchEvents|syscall.IN_ONLYDIR)
+	if err != nil {
+		return errors.New(fmt.Sprintf("failed to watch %s: %v", path, err))
+	}
+
+	w.mutex.Lock()
+	w.directories[int32(wd)] = path
+	w.mutex.Unlock()
+
+	return nil
+}
+
+// Method addHierarchy watches a new subdirectory and its descendants, and enqueues the files already within them.
+// Files can be written into a new subdirectory before its watch has been added, so they are found by walking it.
+// The errors are handled by the pipeline's walk error policy, as they are during the initial walk.
+// Parameter path is the path to the new subdirectory.
+// Parameter enqueue passes the path to a regular file into the pipeline.
+// Returns the error when the walk must halt, otherwise nil.
+func (w *directoryWatcher) addHierarchy(path string, enqueue func(path string) error) error {
+	if w.excludes(path, true) {
+		return nil
+	}
+
+	var haltErr error
+	err := godirwalk.Walk(path, &godirwalk.Options{
+
+		FollowSymbolicLinks: false,
+		Unsorted:            true,
+
+		Callback: func(path string, de *godirwalk.Dirent) error {
+			if de.IsDir() {
+				if w.excludes(path, true) {
+					return godirwalk.SkipThis
+				}
+				return w.addDirectory(path)
+			}
+
+			if de.IsRegular() && !w.excludes(path, false) {
+				return enqueue(path)
+			}
+
+			return nil
+		},
+
+		ErrorCallback: func(osPathname string, err error) godirwalk.ErrorAction {
+			// An error that halts the walk has already been recorded.
+			if !isHalt(err) {
+				err = w.walkError(osPathname, err)
+			}
+			if err != nil {
+				haltErr = err
+				return godirwalk.Halt
+			}
+			return godirwalk.SkipNode
+		},
+	})
+
+	// The subdirectory itself can vanish before it is walked, which is not passed to the error callback.
+	if err != nil && haltErr == nil {
+		return w.walkError(path, err)
+	}
+	return haltErr
+}
+
+// Method watch enqueues each file that is written or moved into the watched hierarchy, until the pipeline is stopped.
+// Parameter enqueue passes the path to a regular file into the pipeline.
+// Parameter stopChannel is closed to signal that watching should end.
+// Returns nil if there are no errors.
+func (w *directoryWatcher) watch(enqueue func(path string) error, stopChannel <-chan bool) error {
+
+	// Closing the inotify file unblocks the pending read. The goroutine also ends when watching fails.
+	doneChannel := make(chan bool)
+	defer close(doneChannel)
+	go func() {
+		select {
+		case <-stopChannel:
+			w.close()
+		case <-doneChannel:
+		}
+	}()
+
+	buffer := make([]byte, kWatchBufferSize)
+
+	for {
+		n, err := w.file.Read(buffer)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return errors.New(fmt.Sprintf("%s%v", "failed to read inotify events: ", err))
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buffer[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			err = w.handleEvent(event, name, enqueue)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Method handleEvent acts upon a single inotify event.
+// Parameter event is the inotify event that was read.
+// Parameter name is the name of the file or subdirectory within the watched directory.
+// Parameter enqueue passes the path to a regular file into the pipeline.
+// Returns the error when watching must halt, otherwise nil.
+func (w *directoryWatcher) handleEvent(event *syscall.InotifyEvent, name string, enqueue func(path string) error) error {
+	switch {
+
+	case event.Mask&syscall.IN_Q_OVERFLOW != 0:
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", "the inotify event queue overflowed, so some files may not have been enqueued")
+
+	case event.Mask&syscall.IN_IGNORED != 0:
+		// The directory was removed or unmounted, so the kernel has dropped its watch.
+		w.mutex.Lock()
+		delete(w.directories, event.Wd)
+		w.mutex.Unlock()
+
+	case event.Mask&syscall.IN_DELETE_SELF != 0:
+		// Wait for the IN_IGNORED event that follows to forget the directory.
+
+	default:
+		w.mutex.Lock()
+		directory, ok := w.directories[event.Wd]
+		w.mutex.Unlock()
+		if !ok || name == "" {
+			return nil
+		}
+		path := Join(directory, name)
+
+		if event.Mask&syscall.IN_ISDIR != 0 {
+			if event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				return w.addHierarchy(path, enqueue)
+			}
+			return nil
+		}
+
+		if event.Mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0 {
+			// Only regular files are processed; sockets, FIFOs and symbolic links are ignored.
+			info, err := os.Lstat(path)
+			if err == nil && info.Mode().IsRegular() && !w.excludes(path, false) {
+				return enqueue(path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Method close stops watching all of the directories and releases the inotify file descriptor.
+func (w *directoryWatcher) close() {
+	w.file.Close()
+}
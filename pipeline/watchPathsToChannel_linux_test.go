@@ -0,0 +1,119 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+//go:build linux
+// +build linux
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Function TestDirectoryWatcher verifies that the files written or moved into a watched hierarchy are enqueued,
+// including those within a subdirectory that is moved in, and that excluded and special files are not.
+func TestDirectoryWatcher(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	excludes := func(path string, isDirectory bool) bool {
+		if isDirectory {
+			return filepath.Base(path) == "private"
+		}
+		return strings.HasSuffix(path, ".part")
+	}
+	var walkErrors []string
+	watcher, err := newDirectoryWatcher(excludes, func(path string, err error) error {
+		walkErrors = append(walkErrors, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = watcher.addDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+
+	enqueued := make(chan string, 16)
+	stopChannel := make(chan bool)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- watcher.watch(func(path string) error {
+			enqueued <- path
+			return nil
+		}, stopChannel)
+	}()
+
+	// The events are read in order, so a path that is expected next proves that nothing was enqueued before it.
+	expect := func(path string) {
+		t.Helper()
+		select {
+		case got := <-enqueued:
+			if got != path {
+				t.Fatalf("the watcher enqueued %s, want %s", got, path)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the watcher did not enqueue %s", path)
+		}
+	}
+	write := func(path string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(filepath.Base(path)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A file that is written, and a file that is moved in.
+	write(filepath.Join(root, "IMG_0001.JPG"))
+	expect(filepath.Join(root, "IMG_0001.JPG"))
+	write(filepath.Join(outside, "IMG_0002.JPG"))
+	if err = os.Rename(filepath.Join(outside, "IMG_0002.JPG"), filepath.Join(root, "IMG_0002.JPG")); err != nil {
+		t.Fatal(err)
+	}
+	expect(filepath.Join(root, "IMG_0002.JPG"))
+
+	// A subdirectory that is moved in is walked for its files, then watched.
+	if err = os.MkdirAll(filepath.Join(outside, "trip", "day1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Join(outside, "trip", "day1", "beach.png"))
+	if err = os.Rename(filepath.Join(outside, "trip"), filepath.Join(root, "trip")); err != nil {
+		t.Fatal(err)
+	}
+	expect(filepath.Join(root, "trip", "day1", "beach.png"))
+	write(filepath.Join(root, "trip", "day1", "dunes.png"))
+	expect(filepath.Join(root, "trip", "day1", "dunes.png"))
+
+	// Excluded files, files in an excluded subdirectory, and special files are not enqueued.
+	write(filepath.Join(root, "upload.part"))
+	if err = os.Mkdir(filepath.Join(root, "private"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Join(root, "private", "secret.jpg"))
+	if err = syscall.Mkfifo(filepath.Join(root, "pipe"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Join(root, "last.jpg"))
+	expect(filepath.Join(root, "last.jpg"))
+
+	close(stopChannel)
+	select {
+	case err = <-watchErr:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watcher did not stop")
+	}
+	if len(walkErrors) != 0 {
+		t.Errorf("the watcher recorded walk errors for %v", walkErrors)
+	}
+}
@@ -0,0 +1,36 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+//go:build !linux
+// +build !linux
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+)
+
+// Type directoryWatcher is unavailable because watch mode relies upon Linux inotify.
+type directoryWatcher struct{}
+
+// Function newDirectoryWatcher always fails, because watch mode relies upon Linux inotify.
+func newDirectoryWatcher(excludes func(path string, isDirectory bool) bool, walkError func(path string, err error) error) (*directoryWatcher, error) {
+	return nil, errors.New("watch mode is only supported on Linux")
+}
+
+// Method addDirectory is never called, because a directoryWatcher cannot be created.
+func (w *directoryWatcher) addDirectory(path string) error {
+	return nil
+}
+
+// Method watch is never called, because a directoryWatcher cannot be created.
+func (w *directoryWatcher) watch(enqueue func(path string) error, stopChannel <-chan bool) error {
+	return nil
+}
+
+// Method close is never called, because a directoryWatcher cannot be created.
+func (w *directoryWatcher) close() {
+}
@@ -0,0 +1,296 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Type WorkItem is a unit of work that passes through the pipeline: a file to process, and its sidecar files.
+// It is described once, after the files that are not kept are dropped, so the steps do not need to examine the file again,
+// and it carries the results and errors of each step to the steps after it.
+type WorkItem struct {
+	// Field Path is the path to the primary file, such as an image, or the virtual path to a member within an archive.
+	Path string
+
+	// Field Sidecars are the paths to the files that accompany the primary file, such as IMG_0001.XMP and
+	// IMG_0001.json beside IMG_0001.JPG, so metadata steps can read them and persistence can move them together.
+	Sidecars []string
+
+	// Field Sequence is the order in which the work item was discovered, starting from 1.
+	Sequence uint64
+
+	// Field Size is the primary file's size in bytes, or the uncompressed size of a member within an archive.
+	Size int64
+
+	// Field ModTime is when the primary file was last modified, in UTC.
+	ModTime time.Time
+
+	// Field Device is the identifier of the device containing the primary file, or 0 when it is unknown.
+	Device uint64
+
+	// Field Inode is the primary file's inode number on its device, or 0 when it is unknown.
+	Inode uint64
+
+	// Field Format is the primary file's format, detected from its contents.
+	Format FileFormat
+
+	// Field Attributes are the results that each step has attached to the work item, by step and then by name.
+	Attributes map[string]map[string]interface{}
+
+	// Field Errors is the history of the errors that the steps encountered while processing the work item.
+	Errors []StepError
+}
+
+// Type StepError is an error that a step of the pipeline encountered while processing a work item.
+type StepError struct {
+	// Field Step is the name of the step.
+	Step string
+
+	// Field Err is the error.
+	Err error
+
+	// Field TimeUtc is the date/time in UTC when the error was encountered.
+	TimeUtc time.Time
+}
+
+// Method Error gets the description of the error.
+func (e StepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+// Method Paths gets the path to the primary file followed by the paths to its sidecar files.
+func (w WorkItem) Paths() []string {
+	return append([]string{w.Path}, w.Sidecars...)
+}
+
+// Method HasIdentity determines whether the primary file's device and inode numbers are known.
+func (w WorkItem) HasIdentity() bool {
+	return w.Inode != 0
+}
+
+// Method Attribute gets a result that a step attached to the work item.
+// Parameter step is the name of the step.
+// Parameter name is the name of the result.
+// Returns the result, or false if the step did not attach it.
+func (w WorkItem) Attribute(step string, name string) (interface{}, bool) {
+	value, ok := w.Attributes[step][name]
+	return value, ok
+}
+
+// Method SetAttribute attaches a step's result to the work item.
+// Parameter step is the name of the step.
+// Parameter name is the name of the result.
+// Parameter value is the result.
+func (w *WorkItem) SetAttribute(step string, name string, value interface{}) {
+	if w.Attributes == nil {
+		w.Attributes = make(map[string]map[string]interface{})
+	}
+	if w.Attributes[step] == nil {
+		w.Attributes[step] = make(map[string]interface{})
+	}
+	w.Attributes[step][name] = value
+}
+
+// Method AddError records an error that a step encountered while processing the work item.
+// Parameter step is the name of the step.
+// Parameter err is the error.
+func (w *WorkItem) AddError(step string, err error) {
+	w.Errors = append(w.Errors, StepError{Step: step, Err: err, TimeUtc: time.Now().UTC()})
+}
+
+// Method describe records a file's size, modification time and identity in the work item.
+// Parameter info is the information about the primary file.
+func (w *WorkItem) describe(info fs.FileInfo) {
+	w.Size = info.Size()
+	w.ModTime = info.ModTime().UTC()
+	if identity, ok := identifyFile(info); ok {
+		w.Device, w.Inode = identity.device, identity.inode
+	}
+}
+
+// Method describeEntry records a file's size, modification time and identity from its directory entry, when the entry
+// holds them, as the entries of a source file system other than the operating system's do, so the file does not need
+// to be examined again. The operating system's entries, which are read by godirwalk, only hold the file's type.
+// Parameter de is the directory entry for the primary file.
+func (w *WorkItem) describeEntry(de directoryEntry) {
+	if entry, ok := de.(sidecarEntry); ok {
+		de = entry.directoryEntry
+	}
+
+	if entry, ok := de.(fsDirectoryEntry); ok {
+		if info, err := entry.Info(); err == nil {
+			w.describe(info)
+		}
+	}
+}
+
+// Function isSidecarName determines whether a file is a kind of sidecar file, from its extension.
+// Parameter name is the name of the file.
+func isSidecarName(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xmp", ".json", ".aae":
+		return true
+	default:
+		return false
+	}
+}
+
+// Function groupSidecars associates the sidecar files in a directory with their primary files by stem.
+// A sidecar belongs to a primary file when their names are the same apart from the extension, as with
+// IMG_0001.JPG and IMG_0001.XMP, or when the sidecar's name is the primary's name with an extension appended,
+// as with IMG_0001.JPG and IMG_0001.JPG.json. A sidecar that matches several primary files, such as a RAW
+// and JPEG pair, belongs to each of them. The names are compared without regard to case.
+// A sidecar file that does not belong to any primary file is a primary file itself.
+// Parameter names are the names of the regular files in the directory, in the order that they were read.
+// Returns the names of each primary file's sidecars, and the primary files that each sidecar belongs to.
+func groupSidecars(names []string) (map[string][]string, map[string][]string) {
+	var (
+		primaries = make(map[string][]string)
+		sidecars  = make(map[string][]string)
+		owners    = make(map[string][]string)
+	)
+
+	for _, name := range names {
+		if isSidecarName(name) {
+			continue
+		}
+		lower := strings.ToLower(name)
+		primaries[lower] = append(primaries[lower], name)
+		if stem := strings.TrimSuffix(lower, filepath.Ext(lower)); stem != lower {
+			primaries[stem] = append(primaries[stem], name)
+		}
+	}
+
+	for _, name := range names {
+		if !isSidecarName(name) {
+			continue
+		}
+		lower := strings.ToLower(name)
+		for _, primary := range primaries[strings.TrimSuffix(lower, filepath.Ext(lower))] {
+			sidecars[primary] = append(sidecars[primary], name)
+			owners[name] = append(owners[name], primary)
+		}
+	}
+
+	return sidecars, owners
+}
+
+// Type sidecarEntry is a directory entry for a primary file, carrying the paths to its sidecar files.
+type sidecarEntry struct {
+	directoryEntry
+
+	// Field sidecars are the paths to the primary file's sidecar files.
+	sidecars []string
+}
+
+// Function sidecarsOf gets the paths to the sidecar files that were grouped with a directory entry.
+func sidecarsOf(de directoryEntry) []string {
+	if entry, ok := de.(sidecarEntry); ok {
+		return entry.sidecars
+	}
+	return nil
+}
+
+// Method groupSidecarEntries folds the sidecar files in a directory's entries into the entries of their primary files,
+// so each primary file is walked with its sidecars, and the sidecars are not walked on their own.
+// Parameter osDirname is the path to the directory.
+// Parameter dirents are the directory's entries, in the order that they are walked.
+// Returns the directory's entries without the grouped sidecar files.
+func (p Pipeline) groupSidecarEntries(osDirname string, dirents []directoryEntry) []directoryEntry {
+	if !p.Sidecars() {
+		return dirents
+	}
+
+	var names []string
+	for _, de := range dirents {
+		if de.IsRegular() && !p.excludes(p.joinPath(osDirname, de.Name()), false) {
+			names = append(names, de.Name())
+		}
+	}
+
+	sidecars, owners := groupSidecars(names)
+	if len(owners) == 0 {
+		return dirents
+	}
+
+	grouped := make([]directoryEntry, 0, len(dirents)-len(owners))
+	for _, de := range dirents {
+		if !de.IsRegular() {
+			grouped = append(grouped, de)
+			continue
+		}
+		if _, isSidecar := owners[de.Name()]; isSidecar {
+			continue
+		}
+		if names, ok := sidecars[de.Name()]; ok {
+			entry := sidecarEntry{directoryEntry: de}
+			for _, name := range names {
+				entry.sidecars = append(entry.sidecars, p.joinPath(osDirname, name))
+			}
+			de = entry
+		}
+		grouped = append(grouped, de)
+	}
+
+	return grouped
+}
+
+// Method findSidecars groups a single file with the sidecar files beside it, by reading the file's directory.
+// It is used for the files that are not discovered by walking a directory, such as those listed in a manifest
+// or created while watching.
+// Parameter path is the path to the file.
+// Returns the work items for the file: the file with its sidecars, or, when the file is itself the sidecar
+// of other files, those primary files with their sidecars.
+func (p Pipeline) findSidecars(path string) []WorkItem {
+	item := WorkItem{Path: path}
+	if !p.Sidecars() || IsArchiveMemberPath(path) {
+		return []WorkItem{item}
+	}
+
+	directory := p.parentPath(path)
+	entries, err := fs.ReadDir(p.sourceFileSystem(), directory)
+	if err != nil {
+		return []WorkItem{item}
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sidecars, owners := groupSidecars(names)
+	name := filepath.Base(path)
+
+	if primaries, isSidecar := owners[name]; isSidecar {
+		items := make([]WorkItem, 0, len(primaries))
+		for _, primary := range primaries {
+			items = append(items, p.sidecarWorkItem(directory, primary, sidecars[primary]))
+		}
+		return items
+	}
+
+	return []WorkItem{p.sidecarWorkItem(directory, name, sidecars[name])}
+}
+
+// Method sidecarWorkItem creates the work item for a primary file and its sidecar files.
+// Parameter directory is the path to the directory containing the files.
+// Parameter name is the name of the primary file.
+// Parameter sidecars are the names of the sidecar files.
+func (p Pipeline) sidecarWorkItem(directory string, name string, sidecars []string) WorkItem {
+	item := WorkItem{Path: p.joinPath(directory, name)}
+	for _, sidecar := range sidecars {
+		item.Sidecars = append(item.Sidecars, p.joinPath(directory, sidecar))
+	}
+	return item
+}
@@ -0,0 +1,277 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package pipeline implements a processing pipeline with multiple steps.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Function TestGroupSidecars verifies that the sidecars are matched to their primary files by stem or appended
+// extension regardless of case, that a sidecar may belong to several primary files, and that an unmatched sidecar
+// is left as a primary file.
+func TestGroupSidecars(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		sidecars map[string][]string
+		owners   map[string][]string
+	}{
+		{
+			"stem",
+			[]string{"IMG_0001.JPG", "IMG_0001.xmp", "IMG_0002.JPG"},
+			map[string][]string{"IMG_0001.JPG": {"IMG_0001.xmp"}},
+			map[string][]string{"IMG_0001.xmp": {"IMG_0001.JPG"}},
+		},
+		{
+			"appended extension",
+			[]string{"beach.heic.JSON", "beach.heic", "beach.heic.aae"},
+			map[string][]string{"beach.heic": {"beach.heic.JSON", "beach.heic.aae"}},
+			map[string][]string{"beach.heic.JSON": {"beach.heic"}, "beach.heic.aae": {"beach.heic"}},
+		},
+		{
+			"RAW and JPEG pair",
+			[]string{"DSC_7.NEF", "DSC_7.jpg", "dsc_7.XMP"},
+			map[string][]string{"DSC_7.NEF": {"dsc_7.XMP"}, "DSC_7.jpg": {"dsc_7.XMP"}},
+			map[string][]string{"dsc_7.XMP": {"DSC_7.NEF", "DSC_7.jpg"}},
+		},
+		{
+			"orphaned sidecars",
+			[]string{"notes.json", "IMG_0003.xmp", "IMG_0003"},
+			map[string][]string{"IMG_0003": {"IMG_0003.xmp"}},
+			map[string][]string{"IMG_0003.xmp": {"IMG_0003"}},
+		},
+	}
+
+	for _, test := range tests {
+		sidecars, owners := groupSidecars(test.names)
+		if fmt.Sprint(sidecars) != fmt.Sprint(test.sidecars) || fmt.Sprint(owners) != fmt.Sprint(test.owners) {
+			t.Errorf("%s: grouped %v and %v, want %v and %v", test.name, sidecars, owners, test.sidecars, test.owners)
+		}
+	}
+}
+
+// Function TestFindSidecars verifies that a single file is grouped with the sidecars beside it, and that a sidecar
+// is replaced by its primary files, unless sidecars are disabled or the file is within an archive.
+func TestFindSidecars(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"roll/F01.CR2":      {Data: []byte("raw")},
+		"roll/F01.JPG":      {Data: []byte("jpeg")},
+		"roll/F01.xmp":      {Data: []byte("<x:xmpmeta/>")},
+		"roll/F02.png":      {Data: []byte("png")},
+		"roll/F02.png.json": {Data: []byte("{}")},
+		"roll/index.json":   {Data: []byte("[]")},
+	}
+
+	tests := []struct {
+		path     string
+		sidecars bool
+		items    string
+	}{
+		{"roll/F01.JPG", true, "[{roll/F01.JPG [roll/F01.xmp]}]"},
+		{"roll/F01.xmp", true, "[{roll/F01.CR2 [roll/F01.xmp]} {roll/F01.JPG [roll/F01.xmp]}]"},
+		{"roll/F02.png.json", true, "[{roll/F02.png [roll/F02.png.json]}]"},
+		{"roll/index.json", true, "[{roll/index.json []}]"},
+		{"roll/F01.JPG", false, "[{roll/F01.JPG []}]"},
+		{"roll/F01.zip!/F01.JPG", true, "[{roll/F01.zip!/F01.JPG []}]"},
+	}
+
+	for _, test := range tests {
+		p, err := New([]Source{ManifestSource{Path: "roll.txt"}}, 4096, 8, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetFileSystem(fileSystem); err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetSidecars(test.sidecars); err != nil {
+			t.Fatal(err)
+		}
+
+		var items []string
+		for _, item := range p.findSidecars(test.path) {
+			items = append(items, fmt.Sprintf("{%s %v}", item.Path, item.Sidecars))
+		}
+		if found := fmt.Sprint(items); found != test.items {
+			t.Errorf("findSidecars(%q) with sidecars %v = %s, want %s", test.path, test.sidecars, found, test.items)
+		}
+	}
+}
+
+// Function TestGroupSidecarEntries verifies that a walk passes each primary file with its sidecars,
+// and does not pass the grouped sidecars on their own.
+func TestGroupSidecarEntries(t *testing.T) {
+	fileSystem := fstest.MapFS{
+		"a/IMG_1.JPG":   {Data: []byte("jpeg")},
+		"a/IMG_1.AAE":   {Data: []byte("<plist/>")},
+		"a/IMG_1.JSON":  {Data: []byte("{}")},
+		"a/orphan.xmp":  {Data: []byte("<x:xmpmeta/>")},
+		"a/IMG_2.xmp/x": {Data: []byte("a directory named like a sidecar")},
+	}
+
+	p, err := New([]Source{DirectorySource{Path: "a"}}, 4096, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetFileSystem(fileSystem); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetSidecars(true); err != nil {
+		t.Fatal(err)
+	}
+
+	pathsChannel := make(chan WorkItem, 8)
+	if err = p.walkPathToChannel("a", pathsChannel, nil); err != nil {
+		t.Fatal(err)
+	}
+	close(pathsChannel)
+
+	var items []string
+	for item := range pathsChannel {
+		items = append(items, fmt.Sprintf("{%s %v}", item.Path, item.Sidecars))
+	}
+	want := "[{a/IMG_1.JPG [a/IMG_1.AAE a/IMG_1.JSON]} {a/IMG_2.xmp/x []} {a/orphan.xmp []}]"
+	if found := fmt.Sprint(items); found != want {
+		t.Errorf("the walk passed %s, want %s", found, want)
+	}
+}
+
+// Function TestDetectFormat verifies the signatures of the formats, and that a signature must be complete.
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		header []byte
+		format FileFormat
+	}{
+		{[]byte("\xFF\xD8\xFF\xE1\x00\x10Exif"), JPEGFormat},
+		{[]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), PNGFormat},
+		{[]byte("GIF87a\x01\x00"), GIFFormat},
+		{[]byte("MM\x00*\x00\x00\x00\x08"), TIFFFormat},
+		{[]byte("RIFF\x24\x00\x00\x00WEBPVP8 "), WebPFormat},
+		{[]byte("RIFF\x24\x00\x00\x00WAVEfmt "), UnknownFormat},
+		{[]byte("RIFF\x24\x00"), UnknownFormat},
+		{[]byte("BM\x36\x00"), BMPFormat},
+		{[]byte("\x00\x00\x00\x18ftypheic\x00\x00"), HEICFormat},
+		{[]byte("\x00\x00\x00\x18ftypisom\x00\x00"), UnknownFormat},
+		{[]byte("\xFF\xD8"), UnknownFormat},
+		{nil, UnknownFormat},
+	}
+
+	for _, test := range tests {
+		if format := detectFormat(test.header); format != test.format {
+			t.Errorf("detectFormat(%q) = %v, want %v", test.header, format, test.format)
+		}
+	}
+}
+
+// Function TestDescribePaths verifies that each work item is numbered and described once, that a member within
+// an archive and a remote file are not examined, and that a file which cannot be described records the error.
+func TestDescribePaths(t *testing.T) {
+	directory := t.TempDir()
+	modified := time.Date(2018, 3, 14, 15, 9, 26, 0, time.Local)
+	gif := filepath.Join(directory, "anim.gif")
+	if err := os.WriteFile(gif, []byte("GIF89a\x02\x00\x02\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(gif, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(gif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, hasIdentity := identifyFile(info)
+
+	p, err := New([]Source{DirectorySource{Path: directory}}, 4096, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inChannel := make(chan WorkItem, 4)
+	inChannel <- WorkItem{Path: gif}
+	inChannel <- WorkItem{Path: filepath.Join(directory, "vanished.jpg")}
+	inChannel <- WorkItem{Path: filepath.Join(directory, "album.zip") + kArchiveSeparator + "a.png", Size: 7, Format: PNGFormat}
+	inChannel <- WorkItem{Path: "https://photos.example.com/remote.jpg"}
+	close(inChannel)
+	pathsChannel := make(chan WorkItem, 4)
+	p.describePaths(inChannel, pathsChannel)
+
+	var items []WorkItem
+	for item := range pathsChannel {
+		items = append(items, item)
+	}
+	if len(items) != 4 {
+		t.Fatalf("the step passed %d work items", len(items))
+	}
+	for index, item := range items {
+		if item.Sequence != uint64(index+1) {
+			t.Errorf("%s is numbered %d", item.Path, item.Sequence)
+		}
+	}
+
+	described := items[0]
+	if described.Size != 10 || !described.ModTime.Equal(modified) || described.ModTime.Location() != time.UTC ||
+		described.Format != GIFFormat || described.HasIdentity() != hasIdentity || len(described.Errors) != 0 {
+		t.Errorf("the file is described as %+v", described)
+	}
+
+	var pathErr *os.PathError
+	if errs := items[1].Errors; len(errs) != 1 || errs[0].Step != kDescribeStep || !errors.As(errs[0].Err, &pathErr) {
+		t.Errorf("the vanished file's errors are %v", errs)
+	}
+	if member := items[2]; member.Size != 7 || member.Format != PNGFormat || !member.ModTime.IsZero() || len(member.Errors) != 0 {
+		t.Errorf("the member is described as %+v", member)
+	}
+	if remote := items[3]; remote.Format != UnknownFormat || !remote.ModTime.IsZero() || len(remote.Errors) != 0 {
+		t.Errorf("the remote file is described as %+v", remote)
+	}
+}
+
+// Function TestWorkItemResults verifies that the steps' results are kept apart by step, that the errors are kept
+// in the order they were encountered, and that the paths begin with the primary file.
+func TestWorkItemResults(t *testing.T) {
+	item := WorkItem{Path: "/shoot/DSC_0042.NEF", Sidecars: make([]string, 1, 4)}
+	item.Sidecars[0] = "/shoot/DSC_0042.xmp"
+
+	if _, ok := item.Attribute(kDedupeStep, "key"); ok {
+		t.Error("a work item without results has an attribute")
+	}
+	item.SetAttribute(kDedupeStep, "key", "sha256:00ff")
+	item.SetAttribute(kStateStep, "key", 42)
+	item.SetAttribute(kDedupeStep, "canonical", true)
+	if value, ok := item.Attribute(kDedupeStep, "key"); !ok || value != "sha256:00ff" {
+		t.Errorf("the dedupe step's key is %v, %v", value, ok)
+	}
+	if value, ok := item.Attribute(kStateStep, "key"); !ok || value != 42 {
+		t.Errorf("the state step's key is %v, %v", value, ok)
+	}
+	if _, ok := item.Attribute(kStateStep, "canonical"); ok {
+		t.Error("an attribute of the dedupe step was attached to the state step")
+	}
+
+	before := time.Now().UTC()
+	item.AddError(kDescribeStep, errors.New("first"))
+	item.AddError(kStateStep, errors.New("second"))
+	if len(item.Errors) != 2 || item.Errors[0].Error() != "describe: first" || item.Errors[1].Step != kStateStep ||
+		item.Errors[0].TimeUtc.Before(before) || item.Errors[1].TimeUtc.Location() != time.UTC {
+		t.Errorf("the errors are %v", item.Errors)
+	}
+
+	// The paths are a new slice, so changing them does not change the sidecars.
+	paths := item.Paths()
+	paths[1] = "/elsewhere.xmp"
+	if fmt.Sprint(item.Paths()) != "[/shoot/DSC_0042.NEF /shoot/DSC_0042.xmp]" {
+		t.Errorf("the paths are %v", item.Paths())
+	}
+	if item.HasIdentity() {
+		t.Error("a work item without an inode has an identity")
+	}
+}
@@ -0,0 +1,216 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package timezone contains methods to infer the time zone of a location on the Earth without network access.
+package timezone
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// The time zone database is embedded, so the zones can be loaded on systems without one.
+	"github.com/abitofhelp/pipeline/geodesy"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	_ "time/tzdata"
+)
+
+// Variable boundariesText is the simplified boundaries of the time zones, one polygon per line.
+//
+//go:embed boundaries.txt
+var boundariesText string
+
+// Variable countriesText is the countries within the time zones, one zone per line.
+//
+//go:embed countries.txt
+var countriesText string
+
+// Type boundary is a polygon of a time zone.
+type boundary struct {
+	// Field name is the zone's IANA name, such as "America/Los_Angeles".
+	name string
+
+	// Field box is the smallest bounding box that contains the polygon, which rejects most locations quickly.
+	box geodesy.BoundingBox
+
+	// Field polygon is the area within the zone.
+	polygon geodesy.Polygon
+}
+
+var (
+	// Variable loadOnce parses the boundaries the first time that they are needed.
+	loadOnce sync.Once
+
+	// Variable boundaries are the parsed boundaries, in the order that they are searched.
+	boundaries []boundary
+
+	// Variable loadErr is the error that was encountered while parsing the boundaries.
+	loadErr error
+
+	// Variable countriesOnce parses the zones' countries the first time that they are needed.
+	countriesOnce sync.Once
+
+	// Variable countries are the ISO 3166-1 alpha-2 codes of the countries within the zones, by the zones' IANA names.
+	countries map[string][]string
+)
+
+// Function Lookup infers the IANA time zone of a location from the embedded, simplified boundaries.
+// A location outside of every boundary, such as at sea, is assigned the nautical time zone of its longitude,
+// such as "Etc/GMT+8", whose offset from UTC is the longitude divided by 15 degrees.
+// Parameter location is the latitude and longitude, in degrees.
+// Returns the zone's IANA name, such as "America/Los_Angeles", or error.
+func Lookup(location latlng.LatLng) (string, error) {
+	err := geodesy.ValidateLatLng(location)
+	if err != nil {
+		return "", err
+	}
+
+	loadOnce.Do(func() {
+		boundaries, loadErr = parseBoundaries(boundariesText)
+	})
+	if loadErr != nil {
+		return "", loadErr
+	}
+
+	for _, boundary := range boundaries {
+		if boundary.box.Contains(location) && boundary.polygon.Contains(location) {
+			return boundary.name, nil
+		}
+	}
+
+	return nauticalZone(location.Longitude), nil
+}
+
+// Function Countries gets the countries within a time zone of the embedded boundaries, so the country of a location
+// can be determined from its zone. Most zones are within a single country, but a zone such as "Africa/Lagos" also
+// covers its neighbors that share its offset.
+// Parameter name is the zone's IANA name, such as "Europe/Paris".
+// Returns the ISO 3166-1 alpha-2 codes of the countries, such as "FR" and "MC", or nil for a nautical or unknown zone.
+func Countries(name string) []string {
+	countriesOnce.Do(func() {
+		countries = parseCountries(countriesText)
+	})
+	return countries[name]
+}
+
+// Function LoadLocation infers the time zone of a location and loads it.
+// Parameter location is the latitude and longitude, in degrees.
+// Returns the time zone or error.
+func LoadLocation(location latlng.LatLng) (*time.Location, error) {
+	name, err := Lookup(location)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s%v", "failed to load the time zone: ", err))
+	}
+	return zone, nil
+}
+
+// Function ToUtc converts a wall-clock date/time, such as a camera's clock that did not record its offset,
+// from a time zone to UTC. The date/time's own location is ignored.
+// Parameter wallClock is the date/time as it was shown on the clock.
+// Parameter name is the IANA name of the clock's time zone.
+// Returns the date/time in UTC or error.
+func ToUtc(wallClock time.Time, name string) (time.Time, error) {
+	zone, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("%s%v", "failed to load the time zone: ", err))
+	}
+
+	local := time.Date(wallClock.Year(), wallClock.Month(), wallClock.Day(),
+		wallClock.Hour(), wallClock.Minute(), wallClock.Second(), wallClock.Nanosecond(), zone)
+	return local.UTC(), nil
+}
+
+// Function nauticalZone gets the nautical time zone of a longitude, as an IANA name in the Etc area.
+// The sign of the Etc zones is inverted, so "Etc/GMT+8" is eight hours behind UTC.
+// Parameter longitude is the longitude, in degrees.
+func nauticalZone(longitude float64) string {
+	hours := int(math.Round(longitude / 15))
+	switch {
+	case hours == 0:
+		return "Etc/GMT"
+	case hours < 0:
+		return fmt.Sprintf("Etc/GMT+%d", -hours)
+	default:
+		return fmt.Sprintf("Etc/GMT-%d", hours)
+	}
+}
+
+// Function parseBoundaries converts the boundaries' text to their polygons.
+// Each line that is not blank or a comment is a zone's IANA name followed by the polygon's vertices as latitude,longitude.
+// Parameter text is the boundaries' text.
+// Returns the boundaries or error.
+func parseBoundaries(text string) ([]boundary, error) {
+	var parsed []boundary
+
+	for number, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		vertices := make([]latlng.LatLng, 0, len(fields)-1)
+		for _, field := range fields[1:] {
+			latitude, longitude, ok := strings.Cut(field, ",")
+			lat, latErr := strconv.ParseFloat(latitude, 64)
+			lng, lngErr := strconv.ParseFloat(longitude, 64)
+			if !ok || latErr != nil || lngErr != nil {
+				return nil, errors.New(fmt.Sprintf("the time zone boundary on line %d has an invalid vertex: %s", number+1, field))
+			}
+			vertices = append(vertices, latlng.LatLng{Latitude: lat, Longitude: lng})
+		}
+
+		polygon, err := geodesy.NewPolygon(vertices)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("the time zone boundary on line %d is not valid: %v", number+1, err))
+		}
+
+		box, err := boundingBox(polygon)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, boundary{name: fields[0], box: *box, polygon: polygon})
+	}
+
+	return parsed, nil
+}
+
+// Function parseCountries converts the zones' countries' text to the countries by the zones' names.
+// Each line that is not blank or a comment is a zone's IANA name followed by its countries' codes, separated by commas.
+// Parameter text is the countries' text.
+func parseCountries(text string) map[string][]string {
+	parsed := make(map[string][]string)
+
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		parsed[fields[0]] = strings.Split(fields[1], ",")
+	}
+
+	return parsed
+}
+
+// Function boundingBox gets the smallest bounding box that contains a polygon.
+// Parameter polygon is the polygon, which does not cross the antimeridian.
+// Returns the bounding box or error.
+func boundingBox(polygon geodesy.Polygon) (*geodesy.BoundingBox, error) {
+	south, west, north, east := 90.0, 180.0, -90.0, -180.0
+	for _, vertex := range polygon {
+		south, north = math.Min(south, vertex.Latitude), math.Max(north, vertex.Latitude)
+		west, east = math.Min(west, vertex.Longitude), math.Max(east, vertex.Longitude)
+	}
+	return geodesy.NewBoundingBox(south, west, north, east)
+}
@@ -0,0 +1,89 @@
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// Copyright (c) 2018 A Bit of Help, Inc. - All Rights Reserved, Worldwide.
+// Use of this source code is governed by a MIT license that can be found in the LICENSE file.
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Package timezone contains methods to infer the time zone of a location on the Earth without network access.
+package timezone
+
+import (
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"testing"
+	"time"
+)
+
+// Function TestLookup verifies the zones of some cities, of locations at sea, and of invalid locations.
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		latitude  float64
+		longitude float64
+		zone      string
+		fails     bool
+	}{
+		{37.7749, -122.4194, "America/Los_Angeles", false},
+		{33.4484, -112.0740, "America/Phoenix", false},
+		{40.7128, -74.0060, "America/New_York", false},
+		{51.5074, -0.1278, "Europe/London", false},
+		{48.8566, 2.3522, "Europe/Paris", false},
+		{35.6762, 139.6503, "Asia/Tokyo", false},
+		{-33.8688, 151.2093, "Australia/Sydney", false},
+		{-36.8485, 174.7633, "Pacific/Auckland", false},
+		{0, -150, "Etc/GMT+10", false},
+		{0, 0, "Etc/GMT", false},
+		{-50, 100, "Etc/GMT-7", false},
+		{0, 180, "Etc/GMT-12", false},
+		{91, 0, "", true},
+		{0, -181, "", true},
+	}
+
+	for _, test := range tests {
+		zone, err := Lookup(latlng.LatLng{Latitude: test.latitude, Longitude: test.longitude})
+		if (err != nil) != test.fails || zone != test.zone {
+			t.Errorf("Lookup(%v, %v) = %q, %v, want %q", test.latitude, test.longitude, zone, err, test.zone)
+		}
+	}
+}
+
+// Function TestBoundaries verifies that every zone in the boundaries can be loaded and has its countries.
+func TestBoundaries(t *testing.T) {
+	parsed, err := parseBoundaries(boundariesText)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, boundary := range parsed {
+		if _, err := time.LoadLocation(boundary.name); err != nil {
+			t.Errorf("the zone %s cannot be loaded: %v", boundary.name, err)
+		}
+		if len(Countries(boundary.name)) == 0 {
+			t.Errorf("the zone %s has no countries", boundary.name)
+		}
+	}
+
+	if countries := Countries("Etc/GMT+8"); countries != nil {
+		t.Errorf("the nautical zone has the countries %v", countries)
+	}
+}
+
+// Function TestToUtc verifies that a wall-clock time is converted with the offset in effect at that time.
+func TestToUtc(t *testing.T) {
+	tests := []struct {
+		wallClock time.Time
+		zone      string
+		utc       time.Time
+		fails     bool
+	}{
+		{time.Date(2018, 1, 15, 12, 0, 0, 0, time.UTC), "America/Los_Angeles", time.Date(2018, 1, 15, 20, 0, 0, 0, time.UTC), false},
+		{time.Date(2018, 7, 15, 12, 0, 0, 0, time.UTC), "America/Los_Angeles", time.Date(2018, 7, 15, 19, 0, 0, 0, time.UTC), false},
+		{time.Date(2018, 7, 15, 12, 0, 0, 0, time.Local), "Asia/Kolkata", time.Date(2018, 7, 15, 6, 30, 0, 0, time.UTC), false},
+		{time.Date(2018, 7, 15, 12, 0, 0, 0, time.UTC), "Etc/GMT-12", time.Date(2018, 7, 15, 0, 0, 0, 0, time.UTC), false},
+		{time.Date(2018, 7, 15, 12, 0, 0, 0, time.UTC), "Nowhere/Invalid", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		utc, err := ToUtc(test.wallClock, test.zone)
+		if (err != nil) != test.fails || !utc.Equal(test.utc) {
+			t.Errorf("ToUtc(%v, %q) = %v, %v, want %v", test.wallClock, test.zone, utc, err, test.utc)
+		}
+	}
+}